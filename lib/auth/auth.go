@@ -24,8 +24,10 @@ limitations under the License.
 package auth
 
 import (
+	"context"
 	"crypto/x509"
 	"fmt"
+	"math/rand"
 	"net/url"
 	"sync"
 	"time"
@@ -93,6 +95,11 @@ func NewAuthServer(cfg *InitConfig, opts ...AuthServerOption) (*AuthServer, erro
 		oidcClients:          make(map[string]*oidcClient),
 		samlProviders:        make(map[string]*samlProvider),
 		githubClients:        make(map[string]*githubClient),
+		rotationCheckPeriod:  cfg.CARotationCheckPeriod,
+		rotationCheckJitter:  cfg.CARotationCheckJitter,
+	}
+	if as.rotationCheckPeriod == 0 {
+		as.rotationCheckPeriod = defaults.CARotationCheckPeriod
 	}
 	for _, o := range opts {
 		o(&as)
@@ -119,6 +126,54 @@ type AuthServer struct {
 	clock         clockwork.Clock
 	bk            backend.Backend
 
+	// keyEscrowHook is an optional hook that receives private key material
+	// of newly generated certificate authorities, see OnKeyGenerated
+	keyEscrowHook KeyEscrowFunc
+
+	// rotationNotifiers is a list of sinks receiving rotation lifecycle
+	// events, see RegisterRotationNotifier
+	rotationNotifiers []RotationNotifier
+
+	// rotationWatchers is a list of subscriptions to rotation state
+	// changes, see WatchRotation
+	rotationWatchers []*RotationWatcher
+
+	// rotationKeyStore is an optional key store generating the key
+	// material of rotated certificate authorities, e.g. backed by an
+	// HSM, see SetRotationKeyStore
+	rotationKeyStore RotationKeyStore
+
+	// rotationSkewMargin delays automatic rotation transitions past the
+	// schedule boundary to absorb clock skew between auth servers, see
+	// SetRotationSkewMargin
+	rotationSkewMargin time.Duration
+
+	// expectedReconnectDuration is how long nodes are expected to need to
+	// reconnect after a credential change, rotations with a shorter grace
+	// period are flagged with a warning, see SetExpectedReconnectDuration
+	expectedReconnectDuration time.Duration
+
+	// rotationPhaseHook is an optional hook invoked after every persisted
+	// rotation phase transition, see OnRotationPhase
+	rotationPhaseHook RotationPhaseHookFunc
+
+	// rotationWebhook is an optional HTTP callout fired when a rotation
+	// completes or finishes rolling back, see SetRotationWebhook
+	rotationWebhook *RotationWebhookConfig
+
+	// migrationCheck is an optional predicate reporting whether a backend
+	// migration is in progress, see SetMigrationCheck
+	migrationCheck func() bool
+
+	// rotationCheckPeriod is how often the periodic rotation checks run,
+	// see RunPeriodicOperations
+	rotationCheckPeriod time.Duration
+
+	// rotationCheckJitter is the upper bound of the random delay added
+	// to every rotation check period, so auth servers running in
+	// parallel desynchronize their checks
+	rotationCheckJitter time.Duration
+
 	sshca.Authority
 
 	// AuthServiceName is a human-readable name of this CA. If several Auth services are running
@@ -137,6 +192,42 @@ type AuthServer struct {
 	clusterName services.ClusterName
 }
 
+// RunPeriodicOperations runs periodic background operations of the auth
+// server, e.g. advances certificate authority rotations in automatic mode,
+// and exits once the context is closed. Every check is delayed by a random
+// jitter up to the configured bound, so auth servers running in parallel
+// drift apart and rarely hit the conflict retries in autoRotate at the
+// same time.
+func (a *AuthServer) RunPeriodicOperations(ctx context.Context) {
+	timer := time.NewTimer(a.rotationCheckInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if err := a.autoRotateCertAuthorities(ctx); err != nil {
+				if trace.IsCompareFailed(err) {
+					log.Debugf("Cert authority has been updated concurrently: %v.", err)
+				} else {
+					log.Errorf("Failed to perform cert rotation check: %v.", err)
+				}
+			}
+			timer.Reset(a.rotationCheckInterval())
+		}
+	}
+}
+
+// rotationCheckInterval returns the time until the next rotation check,
+// the configured period plus a random jitter below the configured bound.
+func (a *AuthServer) rotationCheckInterval() time.Duration {
+	interval := a.rotationCheckPeriod
+	if a.rotationCheckJitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(a.rotationCheckJitter)))
+	}
+	return interval
+}
+
 func (a *AuthServer) Close() error {
 	if a.bk != nil {
 		return trace.Wrap(a.bk.Close())
@@ -119,6 +119,33 @@ func (s *AuthSuite) TestSessions(c *C) {
 	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%#v", err))
 }
 
+func (s *AuthSuite) TestCompareTrustSet(c *C) {
+	ca := suite.NewTestCA(services.HostCA, "me.localhost")
+	c.Assert(s.a.UpsertCertAuthority(ca), IsNil)
+
+	baseline, err := TrustSetFingerprints(ca)
+	c.Assert(err, IsNil)
+	c.Assert(len(baseline), Equals, 2)
+
+	// baseline matches the current trust set
+	missing, unexpected, err := s.a.CompareTrustSet(services.HostCA, baseline)
+	c.Assert(err, IsNil)
+	c.Assert(missing, HasLen, 0)
+	c.Assert(unexpected, HasLen, 0)
+
+	// baseline references a key that is not trusted any more
+	missing, unexpected, err = s.a.CompareTrustSet(services.HostCA, append([]string{"SHA256:missing"}, baseline...))
+	c.Assert(err, IsNil)
+	c.Assert(missing, DeepEquals, []string{"SHA256:missing"})
+	c.Assert(unexpected, HasLen, 0)
+
+	// trust set has drifted from the baseline
+	missing, unexpected, err = s.a.CompareTrustSet(services.HostCA, baseline[:1])
+	c.Assert(err, IsNil)
+	c.Assert(missing, HasLen, 0)
+	c.Assert(unexpected, DeepEquals, baseline[1:])
+}
+
 func (s *AuthSuite) TestUserLock(c *C) {
 	c.Assert(s.a.UpsertCertAuthority(
 		suite.NewTestCA(services.UserCA, "me.localhost")), IsNil)
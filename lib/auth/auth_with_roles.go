@@ -144,6 +144,34 @@ func (a *AuthWithRoles) UpsertCertAuthority(ca services.CertAuthority) error {
 	return a.authServer.UpsertCertAuthority(ca)
 }
 
+// RotateCertAuthority starts or restarts certificate authority rotation process.
+func (a *AuthWithRoles) RotateCertAuthority(req RotateRequest) error {
+	if err := req.CheckAndSetDefaults(a.authServer.clock); err != nil {
+		return trace.Wrap(err)
+	}
+	// transitions of the rotation state machine are guarded by per-phase
+	// permissions, so that e.g. completing the rotation can be limited
+	// to a smaller group of operators than starting one or rolling it back
+	verb := services.RotateVerb(req.TargetPhase)
+	if err := a.action(defaults.Namespace, services.KindCertAuthority, verb); err != nil {
+		return trace.AccessDenied("rotation to phase %q is denied, it requires permission %q on %q",
+			req.TargetPhase, verb, services.KindCertAuthority)
+	}
+	// record who initiated the transition for rotation lifecycle events
+	req.Initiator = a.user.GetName()
+	return a.authServer.RotateCertAuthority(req)
+}
+
+// RotateExternalCertAuthority rotates external certificate authority,
+// this method is called by a remote trusted cluster and is used to update
+// only public keys and certificates of the certificate authority.
+func (a *AuthWithRoles) RotateExternalCertAuthority(ca services.CertAuthority) error {
+	if err := a.action(defaults.Namespace, services.KindCertAuthority, services.VerbRotate); err != nil {
+		return trace.Wrap(err)
+	}
+	return a.authServer.RotateExternalCertAuthority(ca)
+}
+
 func (a *AuthWithRoles) GetCertAuthorities(caType services.CertAuthType, loadKeys bool) ([]services.CertAuthority, error) {
 	if err := a.action(defaults.Namespace, services.KindCertAuthority, services.VerbList); err != nil {
 		return nil, trace.Wrap(err)
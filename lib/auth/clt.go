@@ -317,6 +317,32 @@ func (c *Client) UpsertCertAuthority(ca services.CertAuthority) error {
 	return trace.Wrap(err)
 }
 
+// RotateCertAuthority starts or restarts certificate authority rotation process.
+func (c *Client) RotateCertAuthority(req RotateRequest) error {
+	caType := "all"
+	if req.Type != "" {
+		caType = string(req.Type)
+	}
+	_, err := c.PostJSON(c.Endpoint("authorities", caType, "rotate"), req)
+	return trace.Wrap(err)
+}
+
+// RotateExternalCertAuthority rotates external certificate authority,
+// this method is used to update only public keys and certificates of the
+// the certificate authority of a remote trusted cluster.
+func (c *Client) RotateExternalCertAuthority(ca services.CertAuthority) error {
+	if err := ca.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	data, err := services.GetCertAuthorityMarshaler().MarshalCertAuthority(ca)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = c.PostJSON(c.Endpoint("authorities", string(ca.GetType()), "rotate", "external"),
+		&rotateExternalCertAuthorityRawReq{CA: data})
+	return trace.Wrap(err)
+}
+
 // GetCertAuthorities returns a list of certificate authorities
 func (c *Client) GetCertAuthorities(caType services.CertAuthType, loadKeys bool) ([]services.CertAuthority, error) {
 	if err := caType.Check(); err != nil {
@@ -2271,6 +2297,14 @@ type ClientI interface {
 	// adds remote cluster
 	ValidateTrustedCluster(*ValidateTrustedClusterRequest) (*ValidateTrustedClusterResponse, error)
 
+	// RotateCertAuthority starts or restarts certificate authority rotation process.
+	RotateCertAuthority(req RotateRequest) error
+
+	// RotateExternalCertAuthority rotates external certificate authority,
+	// this method is used to update only public keys and certificates of the
+	// the certificate authority of a remote trusted cluster.
+	RotateExternalCertAuthority(ca services.CertAuthority) error
+
 	GetDomainName() (string, error)
 	// GenerateServerKeys generates new host private keys and certificates (signed
 	// by the host certificate authority) for a node
@@ -120,6 +120,16 @@ type InitConfig struct {
 
 	// ClusterConfig holds cluster level configuration.
 	ClusterConfig services.ClusterConfig
+
+	// CARotationCheckPeriod is how often the periodic certificate
+	// authority rotation checks run, defaults to
+	// defaults.CARotationCheckPeriod.
+	CARotationCheckPeriod time.Duration
+
+	// CARotationCheckJitter is the upper bound of the random delay added
+	// to every rotation check period, so multiple auth servers do not
+	// run their checks in lockstep, zero disables the jitter.
+	CARotationCheckJitter time.Duration
 }
 
 // Init instantiates and configures an instance of AuthServer
@@ -350,6 +350,7 @@ func GetCheckerForBuiltinRole(clusterConfig services.ClusterConfig, role telepor
 					NodeLabels: map[string]string{services.Wildcard: services.Wildcard},
 					Rules: []services.Rule{
 						services.NewRule(services.Wildcard, services.RW()),
+						services.NewRule(services.KindCertAuthority, services.RotateVerbs()),
 					},
 				},
 			})
@@ -0,0 +1,3759 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	rotationWriteLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "ca_rotation_write_latency_microseconds",
+			Help: "Latency for certificate authority writes during rotation",
+			// Buckets in microsecond latencies
+			Buckets: prometheus.ExponentialBuckets(5000, 1.5, 15),
+		},
+		[]string{"type", "transition", "error"},
+	)
+	rotationPhaseGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ca_rotation_phase",
+			Help: "Current rotation phase per certificate authority type, 0 - standby, 1 - update_clients, 2 - update_servers, 3 - rollback",
+		},
+		[]string{"type"},
+	)
+	rotationsCompleted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rotations_completed_total",
+			Help: "Number of certificate authority rotations that completed",
+		},
+		[]string{"type", "mode"},
+	)
+	rotationsRolledBack = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ca_rotations_rolled_back_total",
+			Help: "Number of certificate authority rotations that ended via rollback",
+		},
+		[]string{"type", "mode"},
+	)
+	rotationDurations = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "ca_rotation_duration_seconds",
+			Help: "Wall clock duration of completed certificate authority rotations from start to standby",
+			// Buckets from a minute up to roughly two days, rotations
+			// are bounded by the maximum grace period
+			Buckets: prometheus.ExponentialBuckets(60, 2, 12),
+		},
+		[]string{"type", "mode"},
+	)
+)
+
+func init() {
+	// Metrics have to be registered to be exposed:
+	prometheus.MustRegister(rotationWriteLatencies)
+	prometheus.MustRegister(rotationPhaseGauge)
+	prometheus.MustRegister(rotationsCompleted)
+	prometheus.MustRegister(rotationsRolledBack)
+	prometheus.MustRegister(rotationDurations)
+}
+
+// rotationPhaseValue maps a rotation phase to the numeric value exposed
+// by the phase gauge, see rotationPhaseGauge.
+func rotationPhaseValue(phase string) float64 {
+	switch phase {
+	case services.RotationPhaseUpdateClients:
+		return 1
+	case services.RotationPhaseUpdateServers:
+		return 2
+	case services.RotationPhaseRollback:
+		return 3
+	}
+	return 0
+}
+
+// updateRotationMetrics updates the rotation metrics after a persisted
+// transition: the per-type phase gauge on every transition, the completion
+// and rollback counters and the duration histogram when a rotation
+// returns to standby.
+func updateRotationMetrics(caType services.CertAuthType, prev, current services.Rotation) {
+	rotationPhaseGauge.WithLabelValues(string(caType)).Set(rotationPhaseValue(current.Phase))
+	if current.State != services.RotationStateStandby {
+		return
+	}
+	// completing a rotation wipes the mode, label with the mode
+	// the rotation ran with, forced one-step rotations are manual
+	// by definition
+	mode := prev.Mode
+	if mode == "" {
+		mode = services.RotationModeManual
+	}
+	switch {
+	case prev.State == services.RotationStateInProgress && prev.Phase == services.RotationPhaseRollback:
+		rotationsRolledBack.WithLabelValues(string(caType), mode).Inc()
+	case prev.State == services.RotationStateInProgress:
+		rotationsCompleted.WithLabelValues(string(caType), mode).Inc()
+		if !prev.Started.IsZero() && !current.LastRotated.IsZero() {
+			rotationDurations.WithLabelValues(string(caType), mode).Observe(
+				current.LastRotated.Sub(prev.Started).Seconds())
+		}
+	case current.CurrentID != "" && current.CurrentID != prev.CurrentID && !current.LastRotated.IsZero():
+		// a forced rotation started and completed in one step
+		rotationsCompleted.WithLabelValues(string(caType), mode).Inc()
+	}
+}
+
+// upsertRotatedCertAuthority writes the rotated certificate authority to
+// the backend and records the write latency labeled by the certificate
+// authority type and the target transition. These writes are on the
+// critical path of the rotation, and the metric is emitted on failed
+// writes as well with the error label set. The key material is verified
+// for consistency before the write, so a key ordering bug in a rotation
+// transition surfaces as an error instead of a corrupted trust set on
+// the backend.
+func (a *AuthServer) upsertRotatedCertAuthority(ca services.CertAuthority, transition string) error {
+	if err := VerifyCertAuthorityConsistency(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	start := time.Now()
+	err := a.Trust.UpsertCertAuthority(ca)
+	rotationWriteLatencies.WithLabelValues(
+		string(ca.GetType()), transition, fmt.Sprintf("%v", err != nil)).Observe(
+		float64(time.Since(start) / time.Microsecond))
+	return trace.Wrap(err)
+}
+
+const (
+	// RotationScopeAll rotates both the SSH key pair and the TLS key pair
+	// of the certificate authority, the default.
+	RotationScopeAll = "all"
+	// RotationScopeSSH rotates only the SSH key pair, leaving the TLS
+	// key pair untouched.
+	RotationScopeSSH = "ssh"
+	// RotationScopeTLS rotates only the TLS key pair, leaving the SSH
+	// key pair untouched.
+	RotationScopeTLS = "tls"
+)
+
+// maxRotationIDLength caps the length of an externally supplied
+// rotation ID, see RotateRequest.RotationID.
+const maxRotationIDLength = 64
+
+// maxRotationReasonLength caps the length of an operator supplied
+// rotation reason, see RotateRequest.Reason.
+const maxRotationReasonLength = 256
+
+// RotateRequest is a request to start rotation of the certificate authority.
+type RotateRequest struct {
+	// Type is a certificate authority type, if omitted, both user and host CA
+	// will be rotated.
+	Type services.CertAuthType `json:"type"`
+	// GracePeriod is used to generate cert rotation schedule that defines
+	// times at which different rotation phases will be applied by the auth server
+	// in auto mode. It is not used in manual rotation mode.
+	// If specified, it is set to the value of the grace period duration.
+	GracePeriod *time.Duration `json:"grace_period,omitempty"`
+	// TargetPhase sets desired rotation phase to move to, if not set
+	// will be set automatically, it is a required argument
+	// for manual rotation.
+	TargetPhase string `json:"target_phase,omitempty"`
+	// Mode sets manual or auto rotation mode.
+	Mode string `json:"mode"`
+	// Schedule is an optional rotation schedule,
+	// autogenerated based on GracePeriod parameter if not set.
+	Schedule *services.RotationSchedule `json:"schedule"`
+	// ScheduleOffsets is an optional rotation schedule expressed as
+	// offsets relative to the start of the rotation, an alternative
+	// to the absolute timestamps of Schedule.
+	ScheduleOffsets *services.RotationScheduleOffsets `json:"schedule_offsets,omitempty"`
+	// MaintenanceWindows optionally restricts the automatic phase
+	// transitions to recurring windows of time, e.g. weekday nights,
+	// the generated schedule boundaries are snapped forward to the next
+	// open window, see services.GenerateScheduleInWindows. Snapping can
+	// stretch the rotation past the grace period.
+	MaintenanceWindows []services.MaintenanceWindow `json:"maintenance_windows,omitempty"`
+	// TypeOffset optionally delays the update_servers transition of the
+	// given certificate authority types by the given duration when
+	// rotating all types automatically, so the server reload waves of
+	// the types do not coincide. The offset shifts only the
+	// update_servers boundary of the generated schedule, the rotation
+	// still completes at the end of the grace period.
+	TypeOffset map[services.CertAuthType]time.Duration `json:"type_offset,omitempty"`
+	// PerType optionally overrides the grace period, schedule and mode
+	// for individual certificate authority types when all types are
+	// rotated in one request, e.g. host and user credentials have very
+	// different reconnection dynamics and may need different schedules.
+	// Types without an override use the top-level fields.
+	PerType map[services.CertAuthType]RotateRequest `json:"per_type,omitempty"`
+	// AcknowledgeForce must be set when the grace period is explicitly
+	// set to zero, as zero grace period rotates the certificate authority
+	// in one step, invalidating all previously issued credentials.
+	AcknowledgeForce bool `json:"acknowledge_force,omitempty"`
+	// KeepOldTrustOnForce keeps the checking keys and TLS certificates,
+	// never the private keys, of the old certificate authority trusted
+	// when rotating with grace period 0. The new key becomes the only
+	// signer right away, but verification of previously issued
+	// credentials does not break instantly. The trade-off is that the
+	// old trust lingers until a later rotation trims it, so a forced
+	// rotation responding to a key compromise should not set this - the
+	// compromised key would keep verifying.
+	KeepOldTrustOnForce bool `json:"keep_old_trust_on_force,omitempty"`
+	// StartAt schedules the rotation to start at the given future time
+	// instead of starting right away, e.g. to line up the rotation
+	// with an upcoming maintenance window.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// Initiator is the name of the user who initiated the rotation,
+	// set by the API layer, recorded on the rotation object and
+	// included in rotation lifecycle events.
+	Initiator string `json:"initiator,omitempty"`
+	// Reason is an optional operator supplied reason for the rotation,
+	// e.g. a ticket reference, recorded on the rotation object, in the
+	// rotation history and in audit events for change tracking. A
+	// rotation policy can make the reason mandatory, see
+	// RotationPolicy.RequireReason.
+	Reason string `json:"reason,omitempty"`
+	// KeyAlgorithm is the key algorithm of the newly generated certificate
+	// authority, "rsa" or "ecdsa-p256", an empty value defaults to RSA.
+	KeyAlgorithm string `json:"key_algorithm,omitempty"`
+	// KeyComment is an optional comment appended to the newly generated
+	// SSH public key in the authorized-keys format, so operators can
+	// trace which rotation a key came from in logs and known_hosts
+	// files. Defaults to "<cluster>-rotation-<rotation id>". The comment
+	// is purely informational and has no effect on imported key material.
+	KeyComment string `json:"key_comment,omitempty"`
+	// Scope limits the rotation to a subset of the certificate authority
+	// key material, "ssh" or "tls", defaults to "all" which rotates both
+	// key pairs. Scoped rotation reduces the blast radius when only one
+	// protocol's keys are suspected compromised.
+	Scope string `json:"scope,omitempty"`
+	// RotationID is an optional identifier to use as the CurrentID of
+	// the new rotation instead of a generated UUID, so an external
+	// workflow engine orchestrating the rotation can correlate it with
+	// its own records. Uniqueness is not enforced.
+	RotationID string `json:"rotation_id,omitempty"`
+	// CanarySelector is an optional node label selector in the
+	// "key=value,key2=value2" format starting the rotation in canary
+	// mode: only the matching nodes are instructed to switch to the
+	// new credentials and the update_servers phase stays blocked until
+	// the canary is confirmed healthy with ConfirmRotationCanary.
+	CanarySelector string `json:"canary_selector,omitempty"`
+	// RetainGenerations is the number of certificate authority
+	// generations kept in the trusted set once the rotation completes,
+	// the default of 1 trusts only the newly rotated certificate
+	// authority. Higher values keep the checking keys and TLS
+	// certificates, never the private keys, of older generations
+	// trusted, so very stale but still valid certificates keep working
+	// across frequent rotations.
+	RetainGenerations int `json:"retain_generations,omitempty"`
+	// PinnedCerts is an optional list of fingerprints of TLS certificates
+	// currently trusted by the certificate authority, in the
+	// "sha256:<hex>" format reported by GetTrustSetFingerprints. Pinned
+	// certificates stay trusted, certificate only, past the completion of
+	// the rotation until removed with UnpinCert, for external systems
+	// that pin a specific certificate and can not update instantly.
+	PinnedCerts []string `json:"pinned_certs,omitempty"`
+	// CATTL is an optional certificate lifetime of the newly generated
+	// TLS certificate authority, must cover at least the grace period so
+	// the new certificate authority outlives the rotation, defaults to
+	// defaults.CATTL.
+	CATTL *time.Duration `json:"ca_ttl,omitempty"`
+	// ImportedSSHKeyPair is an optional externally generated SSH key pair
+	// installed as the new certificate authority instead of generating
+	// one, e.g. produced by an offline key ceremony, see SSHKeyPair.
+	ImportedSSHKeyPair *SSHKeyPair `json:"imported_ssh_key_pair,omitempty"`
+	// ImportedTLSKeyPair is an optional externally generated TLS key pair
+	// installed as the new certificate authority instead of generating
+	// one, the certificate has to be a self signed certificate authority
+	// certificate matching the private key.
+	ImportedTLSKeyPair *services.TLSKeyPair `json:"imported_tls_key_pair,omitempty"`
+	// SkipPreflight skips the pre-transition safety checks: that all
+	// registered auth servers and proxies have observed the current
+	// rotation before advancing to the update_servers phase, and that
+	// no registered node has been offline for the whole rotation before
+	// completing it. Intended for emergencies when a dead peer can not
+	// acknowledge or a decommissioned node can not come back online.
+	SkipPreflight bool `json:"skip_preflight,omitempty"`
+	// Subject optionally customizes the subject of the newly generated
+	// TLS certificate authority beyond the common name, e.g. for
+	// integrations validating the organizational unit of the issuer,
+	// see RotationSubject.
+	Subject *RotationSubject `json:"subject,omitempty"`
+	// CommonNameTemplate is an optional template for the common name of
+	// the newly generated certificate authority, e.g.
+	// "ca.{{.cluster}}.us-west-2", with variables cluster - the name of
+	// the cluster, catype - the certificate authority type and
+	// generation - the ID of the new rotation. If not set, the common
+	// name defaults to the cluster name.
+	CommonNameTemplate string `json:"common_name_template,omitempty"`
+	// DryRun validates the request and computes the rotation state it
+	// would produce without persisting any changes, see
+	// DryRunRotateCertAuthority.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Revision is an optional revision token previously returned by
+	// GetRotationStatus, if set, the request fails with CompareFailed
+	// when the rotation has transitioned since the token was observed,
+	// giving callers optimistic concurrency without full CAS semantics.
+	Revision *int64 `json:"revision,omitempty"`
+	// scheduleGenerated is set when the schedule was generated by
+	// CheckAndSetDefaults rather than supplied by the caller, keeping
+	// repeated validation of an already defaulted request idempotent.
+	scheduleGenerated bool
+}
+
+// RotationSubject customizes the subject of the TLS certificate
+// authority generated by a rotation. The common name is controlled
+// separately via RotateRequest.CommonNameTemplate and defaults to the
+// cluster name, the cluster name also always stays the first
+// organization entry so existing integrations keep matching.
+type RotationSubject struct {
+	// Organization is appended to the default organization entry,
+	// the cluster name.
+	Organization []string `json:"organization,omitempty"`
+	// OrganizationalUnit is the organizational unit of the subject,
+	// empty by default.
+	OrganizationalUnit []string `json:"organizational_unit,omitempty"`
+}
+
+// Check validates the subject customization.
+func (s *RotationSubject) Check() error {
+	for _, entry := range s.Organization {
+		if entry == "" {
+			return trace.BadParameter("subject organization entries must not be empty")
+		}
+	}
+	for _, entry := range s.OrganizationalUnit {
+		if entry == "" {
+			return trace.BadParameter("subject organizational unit entries must not be empty")
+		}
+	}
+	return nil
+}
+
+// applyOverride replaces the rotation parameters the per-type override
+// sets, leaving the rest of the request untouched, see
+// RotateRequest.PerType.
+func (r *RotateRequest) applyOverride(o RotateRequest) {
+	if o.GracePeriod != nil {
+		r.GracePeriod = o.GracePeriod
+	}
+	if o.Schedule != nil {
+		r.Schedule = o.Schedule
+		r.scheduleGenerated = false
+	}
+	if o.ScheduleOffsets != nil {
+		r.ScheduleOffsets = o.ScheduleOffsets
+		r.Schedule = nil
+		r.scheduleGenerated = false
+	}
+	if len(o.MaintenanceWindows) != 0 {
+		r.MaintenanceWindows = o.MaintenanceWindows
+		r.Schedule = nil
+		r.scheduleGenerated = false
+	}
+	if o.Mode != "" {
+		r.Mode = o.Mode
+	}
+}
+
+// Types returns cert authority types requested to be rotated.
+func (r *RotateRequest) Types() []services.CertAuthType {
+	switch r.Type {
+	case "":
+		return services.CertAuthTypes
+	case services.HostCA, services.UserCA, services.DatabaseCA:
+		return []services.CertAuthType{r.Type}
+	}
+	return nil
+}
+
+// EffectiveGracePeriod resolves the tri-state grace period of the request:
+// a nil pointer means the default grace period, an explicit pointer to zero
+// forces the rotation through in one step and any other value is used as
+// is. Every consumer of the grace period goes through this helper, so the
+// subtle nil-vs-zero distinction lives in exactly one place and a refactor
+// can not accidentally turn an unset grace period into a forced rotation.
+func (r *RotateRequest) EffectiveGracePeriod() (gracePeriod time.Duration, isForce bool) {
+	if r.GracePeriod == nil {
+		return defaults.RotationGracePeriod, false
+	}
+	return *r.GracePeriod, *r.GracePeriod == 0
+}
+
+// CheckAndSetDefaults checks and sets default values.
+func (r *RotateRequest) CheckAndSetDefaults(clock clockwork.Clock) error {
+	if r.TargetPhase == "" {
+		// if phase is not set, imply that the first meaningful phase
+		// is set as a target phase
+		r.TargetPhase = services.RotationPhaseUpdateClients
+	}
+	// if rotation mode is not set, default to manual rotation
+	if r.Mode == "" {
+		r.Mode = services.RotationModeManual
+	}
+	switch r.Mode {
+	case services.RotationModeManual, services.RotationModeAuto:
+	default:
+		return trace.BadParameter("unsupported rotation mode %q, supported values are %q and %q",
+			r.Mode, services.RotationModeManual, services.RotationModeAuto)
+	}
+	switch r.Type {
+	case "", services.HostCA, services.UserCA, services.DatabaseCA:
+	default:
+		return trace.BadParameter("unsupported certificate authority type %q, supported types are %v", r.Type, services.CertAuthTypes)
+	}
+	if r.Revision != nil && r.Type == "" {
+		// revisions advance independently per certificate authority type,
+		// so a revision check is only meaningful for a single one
+		return trace.BadParameter("revision check requires an explicit certificate authority type")
+	}
+	if len(r.PerType) != 0 {
+		if r.Type != "" {
+			return trace.BadParameter(
+				"per-type overrides can only be combined with rotating all certificate authority types, drop the explicit type %q", r.Type)
+		}
+		for caType, override := range r.PerType {
+			if err := caType.Check(); err != nil {
+				return trace.Wrap(err)
+			}
+			if override.Type != "" {
+				return trace.BadParameter("the per-type override of %q certificate authority must not set a type itself", caType)
+			}
+			if len(override.PerType) != 0 {
+				return trace.BadParameter("the per-type override of %q certificate authority must not nest further overrides", caType)
+			}
+		}
+	}
+	if err := checkRotationKeyAlgorithm(r.KeyAlgorithm); err != nil {
+		return trace.Wrap(err)
+	}
+	if strings.ContainsAny(r.KeyComment, "\r\n") {
+		return trace.BadParameter("SSH key comment must be a single line")
+	}
+	if r.Scope == "" {
+		r.Scope = RotationScopeAll
+	}
+	switch r.Scope {
+	case RotationScopeAll, RotationScopeSSH, RotationScopeTLS:
+	default:
+		return trace.BadParameter("unsupported rotation scope %q, supported values are %q, %q and %q",
+			r.Scope, RotationScopeAll, RotationScopeSSH, RotationScopeTLS)
+	}
+	if r.CommonNameTemplate != "" {
+		// surface template syntax errors before the rotation starts
+		if _, err := template.New("common_name").Parse(r.CommonNameTemplate); err != nil {
+			return trace.BadParameter("failed to parse common name template: %v", err)
+		}
+	}
+	if r.Subject != nil {
+		if err := r.Subject.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if len(r.Reason) > maxRotationReasonLength {
+		return trace.BadParameter(
+			"rotation reason of %v characters exceeds the maximum of %v", len(r.Reason), maxRotationReasonLength)
+	}
+	if r.RotationID != "" {
+		if len(r.RotationID) > maxRotationIDLength {
+			return trace.BadParameter(
+				"rotation ID of %v characters exceeds the maximum of %v", len(r.RotationID), maxRotationIDLength)
+		}
+		if r.TargetPhase != services.RotationPhaseUpdateClients || r.StartAt != nil {
+			return trace.BadParameter(
+				"rotation ID can only be set when starting a rotation with target phase %q",
+				services.RotationPhaseUpdateClients)
+		}
+	}
+	if r.CanarySelector != "" {
+		if _, err := services.ParseCanarySelector(r.CanarySelector); err != nil {
+			return trace.Wrap(err)
+		}
+		if r.TargetPhase != services.RotationPhaseUpdateClients {
+			return trace.BadParameter(
+				"canary selector can only be set when starting a rotation with target phase %q",
+				services.RotationPhaseUpdateClients)
+		}
+	}
+	if r.ImportedSSHKeyPair != nil || r.ImportedTLSKeyPair != nil {
+		if r.TargetPhase != services.RotationPhaseUpdateClients || r.StartAt != nil {
+			return trace.BadParameter(
+				"imported key material can only be supplied when starting a rotation with target phase %q",
+				services.RotationPhaseUpdateClients)
+		}
+	}
+	if r.ImportedSSHKeyPair != nil {
+		if r.Scope == RotationScopeTLS {
+			return trace.BadParameter(
+				"an imported SSH key pair has no effect in a rotation scoped to %q", RotationScopeTLS)
+		}
+		if err := r.ImportedSSHKeyPair.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.ImportedTLSKeyPair != nil {
+		if r.Scope == RotationScopeSSH {
+			return trace.BadParameter(
+				"an imported TLS key pair has no effect in a rotation scoped to %q", RotationScopeSSH)
+		}
+		if r.Subject != nil || r.CommonNameTemplate != "" || r.CATTL != nil {
+			return trace.BadParameter(
+				"subject, common name template and CA TTL apply to generated certificates and have no effect on an imported TLS key pair")
+		}
+		if err := checkImportedTLSKeyPair(r.ImportedTLSKeyPair); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.RetainGenerations < 0 {
+		return trace.BadParameter("retain generations must be positive, got %v", r.RetainGenerations)
+	}
+	if r.RetainGenerations > 1 && r.TargetPhase != services.RotationPhaseUpdateClients {
+		return trace.BadParameter(
+			"retain generations can only be set when starting a rotation with target phase %q",
+			services.RotationPhaseUpdateClients)
+	}
+	if len(r.PinnedCerts) != 0 {
+		if r.TargetPhase != services.RotationPhaseUpdateClients || r.StartAt != nil {
+			return trace.BadParameter(
+				"pinned certificates can only be set when starting a rotation with target phase %q",
+				services.RotationPhaseUpdateClients)
+		}
+		seen := make(map[string]bool, len(r.PinnedCerts))
+		for _, fingerprint := range r.PinnedCerts {
+			if fingerprint == "" {
+				return trace.BadParameter("pinned certificate fingerprints must not be empty")
+			}
+			if seen[fingerprint] {
+				return trace.BadParameter("pinned certificate %v is listed more than once", fingerprint)
+			}
+			seen[fingerprint] = true
+		}
+	}
+	gracePeriod, isForce := r.EffectiveGracePeriod()
+	if r.GracePeriod == nil {
+		r.GracePeriod = &gracePeriod
+	}
+	// a grace period of exactly 0 keeps its special meaning of forcing
+	// the rotation through in one step, anything else has to be within
+	// sane bounds so a typo can not produce a nonsensical schedule
+	if gracePeriod < 0 {
+		return trace.BadParameter("grace period %v can not be negative", gracePeriod)
+	}
+	if gracePeriod > defaults.MaxRotationGracePeriod {
+		return trace.BadParameter("grace period %v exceeds the maximum of %v",
+			gracePeriod, defaults.MaxRotationGracePeriod)
+	}
+	if !isForce && gracePeriod < 3*defaults.MinPhaseDuration {
+		return trace.BadParameter(
+			"grace period %v would produce rotation phases shorter than the minimum of %v, use a grace period of at least %v or grace period 0 to force the rotation through in one step",
+			gracePeriod, defaults.MinPhaseDuration, 3*defaults.MinPhaseDuration)
+	}
+	if isForce {
+		// zero grace period forces the rotation through in one step,
+		// dropping the old certificate authority right away, so require
+		// an explicit acknowledgement to make sure a stray zero value
+		// can not silently force-rotate the cluster
+		if !r.AcknowledgeForce {
+			return trace.BadParameter(
+				"grace period 0 drops the old certificate authority with no trust overlap, invalidating all issued credentials and permanently disconnecting any node that is offline, set AcknowledgeForce to confirm")
+		}
+	}
+	if r.KeepOldTrustOnForce && !isForce {
+		return trace.BadParameter(
+			"KeepOldTrustOnForce only applies to forced rotation with grace period 0, a graceful rotation keeps the old certificate authority trusted already")
+	}
+	if r.CATTL != nil && *r.CATTL <= 0 {
+		return trace.BadParameter("CA TTL %v must be positive", *r.CATTL)
+	}
+	// the new certificate authority has to outlive the rotation with some
+	// margin, otherwise it could expire while the rotation is still in
+	// progress, leaving the cluster with an expired trust set
+	caTTL := defaults.CATTL
+	if r.CATTL != nil {
+		caTTL = *r.CATTL
+	}
+	if caTTL < gracePeriod+rotationCATTLMargin {
+		return trace.BadParameter(
+			"grace period %v is too close to the CA certificate TTL %v, the new certificate authority would expire before the rotation completes",
+			gracePeriod, caTTL)
+	}
+	if r.Schedule != nil && r.ScheduleOffsets != nil {
+		return trace.BadParameter("provide either an absolute schedule or schedule offsets, not both")
+	}
+	if len(r.TypeOffset) != 0 {
+		if r.Type != "" {
+			return trace.BadParameter(
+				"per-type schedule offsets can only be combined with rotating all certificate authority types, drop the explicit type %q", r.Type)
+		}
+		if r.Schedule != nil || r.ScheduleOffsets != nil || len(r.MaintenanceWindows) != 0 {
+			return trace.BadParameter(
+				"per-type schedule offsets shift the generated schedule and can not be combined with an explicit schedule, schedule offsets or maintenance windows")
+		}
+		if r.TargetPhase != services.RotationPhaseUpdateClients || r.StartAt != nil {
+			return trace.BadParameter(
+				"per-type schedule offsets can only be set when starting a rotation with target phase %q",
+				services.RotationPhaseUpdateClients)
+		}
+		if isForce {
+			return trace.BadParameter(
+				"per-type schedule offsets have no effect with grace period 0, the rotation completes in one step")
+		}
+		for caType, offset := range r.TypeOffset {
+			if err := caType.Check(); err != nil {
+				return trace.Wrap(err)
+			}
+			if offset < 0 {
+				return trace.BadParameter(
+					"per-type schedule offset of the %q certificate authority must not be negative, got %v", caType, offset)
+			}
+			// the offset delays only the update_servers flip, the shifted
+			// boundary still has to leave a meaningful update_servers phase
+			// before the rotation completes at the end of the grace period
+			if gracePeriod/3+offset+defaults.MinPhaseDuration > gracePeriod {
+				return trace.BadParameter(
+					"per-type schedule offset %v of the %q certificate authority pushes the update_servers transition too close to the end of the grace period %v, use a smaller offset or a longer grace period",
+					offset, caType, gracePeriod)
+			}
+		}
+	}
+	if len(r.MaintenanceWindows) != 0 && (r.Schedule != nil || r.ScheduleOffsets != nil) {
+		// the windows shape the generated schedule, an explicit schedule
+		// already pins the boundaries
+		return trace.BadParameter(
+			"maintenance windows shape the generated schedule and can not be combined with an explicit schedule or schedule offsets")
+	}
+	if r.Mode == services.RotationModeManual && !r.scheduleGenerated && (r.Schedule != nil || r.ScheduleOffsets != nil || len(r.MaintenanceWindows) != 0 || len(r.TypeOffset) != 0) {
+		// in manual mode phase transitions are driven by explicit
+		// requests, a schedule would be silently ignored
+		return trace.BadParameter(
+			"schedule has no effect in manual mode, phase transitions are driven by explicit requests, drop the schedule or use mode %q", services.RotationModeAuto)
+	}
+	if r.StartAt != nil {
+		if r.ScheduleOffsets != nil {
+			// the schedule of a scheduled rotation is generated when it
+			// starts, relative offsets have nothing to anchor to yet
+			return trace.BadParameter("schedule offsets can not be combined with a scheduled start time")
+		}
+		if len(r.MaintenanceWindows) != 0 {
+			// same as the offsets, the schedule the windows would shape
+			// is only generated when the scheduled rotation starts
+			return trace.BadParameter("maintenance windows can not be combined with a scheduled start time")
+		}
+		// a scheduled rotation persists only the grace period, mode,
+		// initiator and reason until the start time arrives, parameters
+		// shaping the generated keys or the rotation behavior would be
+		// silently dropped when it fires, reject them up front like
+		// imported key material and pinned certificates
+		dropped := []struct {
+			name string
+			set  bool
+		}{
+			{"a rotation scope", r.Scope != RotationScopeAll},
+			{"a key algorithm", r.KeyAlgorithm != "" && r.KeyAlgorithm != RotationKeyAlgorithmRSA},
+			{"a common name template", r.CommonNameTemplate != ""},
+			{"a subject", r.Subject != nil},
+			{"a CA TTL", r.CATTL != nil},
+			{"a key comment", r.KeyComment != ""},
+			{"retain generations", r.RetainGenerations > 1},
+			{"a canary selector", r.CanarySelector != ""},
+			{"keeping old trust on force", r.KeepOldTrustOnForce},
+		}
+		for _, field := range dropped {
+			if field.set {
+				return trace.BadParameter(
+					"%v can not be combined with a scheduled start time, it would not be preserved until the rotation starts", field.name)
+			}
+		}
+		// only a full rotation can be scheduled in advance,
+		// phase transitions of an ongoing rotation happen right away
+		if r.TargetPhase != services.RotationPhaseUpdateClients {
+			return trace.BadParameter(
+				"only rotation starting with phase %q can be scheduled in advance", services.RotationPhaseUpdateClients)
+		}
+		if !r.StartAt.After(clock.Now()) {
+			return trace.BadParameter("scheduled start time %v is not in the future", r.StartAt)
+		}
+		// the schedule is generated when the scheduled rotation
+		// actually starts
+		r.Schedule = &services.RotationSchedule{}
+		r.scheduleGenerated = true
+		return nil
+	}
+	if isForce {
+		if r.ScheduleOffsets != nil {
+			return trace.BadParameter("schedule offsets have no effect with grace period 0, the rotation completes in one step")
+		}
+		if len(r.MaintenanceWindows) != 0 {
+			return trace.BadParameter("maintenance windows have no effect with grace period 0, the rotation completes in one step")
+		}
+		// no schedule is needed for forced rotation as it
+		// completes in one step
+		r.Schedule = &services.RotationSchedule{}
+		r.scheduleGenerated = true
+		return nil
+	}
+	if r.Schedule == nil {
+		var err error
+		switch {
+		case r.ScheduleOffsets != nil:
+			r.Schedule, err = r.ScheduleOffsets.Schedule(clock, gracePeriod)
+		case len(r.MaintenanceWindows) != 0:
+			r.Schedule, err = services.GenerateScheduleInWindows(clock, gracePeriod, r.MaintenanceWindows)
+		default:
+			r.Schedule, err = services.GenerateSchedule(clock, gracePeriod)
+		}
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.scheduleGenerated = true
+	} else {
+		if err := r.Schedule.CheckAndSetDefaults(clock); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// rotationReq is an internal rotation request
+type rotationReq struct {
+	// clock implements test or real wall clock
+	clock clockwork.Clock
+	// ca is a certificate authority to rotate
+	ca services.CertAuthority
+	// targetPhase is a target rotation phase to set
+	targetPhase string
+	// mode is a rotation mode
+	mode string
+	// gracePeriod is a rotation grace period
+	gracePeriod time.Duration
+	// schedule is a schedule to set
+	schedule services.RotationSchedule
+	// startAt is an optional future time to schedule
+	// the rotation start at
+	startAt time.Time
+	// commonNameTemplate is an optional template for the common name
+	// of the newly generated certificate authority
+	commonNameTemplate string
+	// subject optionally customizes the subject of the new TLS
+	// certificate authority, see RotateRequest.Subject
+	subject *RotationSubject
+	// keyAlgorithm is the key algorithm of the newly generated
+	// certificate authority, an empty value defaults to RSA
+	keyAlgorithm string
+	// keyComment is an optional comment appended to the newly generated
+	// SSH public key, an empty value defaults to the cluster name and
+	// rotation ID, see RotateRequest.KeyComment
+	keyComment string
+	// scope limits the rotation to the SSH or TLS key material,
+	// an empty value rotates both
+	scope string
+	// initiator is the name of the user who initiated the rotation,
+	// recorded on the rotation object when a rotation starts or
+	// rolls back
+	initiator string
+	// reason is an optional operator supplied reason for the rotation,
+	// recorded on the rotation object when a rotation starts, see
+	// RotateRequest.Reason
+	reason string
+	// canarySelector is an optional node label selector starting
+	// the rotation in canary mode, see RotateRequest.CanarySelector
+	canarySelector string
+	// rotationID is an optional external identifier to use as the
+	// CurrentID of the new rotation, see RotateRequest.RotationID
+	rotationID string
+	// retainGenerations is the number of certificate authority
+	// generations kept trusted, zero defaults to one, see
+	// RotateRequest.RetainGenerations
+	retainGenerations int
+	// pinnedCerts is a list of fingerprints of trusted TLS certificates
+	// kept trusted past the completion of the rotation, see
+	// RotateRequest.PinnedCerts
+	pinnedCerts []string
+	// keepOldTrustOnForce keeps the old checking keys and certificates
+	// trusted when rotating with grace period 0, see
+	// RotateRequest.KeepOldTrustOnForce
+	keepOldTrustOnForce bool
+	// caTTL is the certificate lifetime of the newly generated TLS
+	// certificate authority, zero defaults to defaults.CATTL
+	caTTL time.Duration
+	// stockpiled is an optional pre-generated key set to use instead
+	// of generating a new one, see AuthServer.StockpileCAKeys
+	stockpiled *stockpiledKey
+	// importedSSH is an optional externally generated SSH key pair to
+	// install instead of generating one, see
+	// RotateRequest.ImportedSSHKeyPair
+	importedSSH *SSHKeyPair
+	// importedTLS is an optional externally generated TLS key pair to
+	// install instead of generating one, see
+	// RotateRequest.ImportedTLSKeyPair
+	importedTLS *services.TLSKeyPair
+	// keyStore generates the key material of the rotated certificate
+	// authority, see RotationKeyStore
+	keyStore RotationKeyStore
+	// onKeyGenerated is an optional hook called with newly generated
+	// private key material, see AuthServer.OnKeyGenerated
+	onKeyGenerated KeyEscrowFunc
+}
+
+// KeyEscrowFunc is a callback that receives private key material of the
+// newly generated certificate authority key pairs, used to support
+// escrow and backup workflows. Returning a non-nil error aborts
+// the rotation before any new keys are written to the backend.
+type KeyEscrowFunc func(caType services.CertAuthType, sshPrivPEM []byte, tlsKeyPEM []byte) error
+
+// OnKeyGenerated registers a hook that is handed the private key material of
+// newly generated certificate authority key pairs. The hook is invoked inside
+// rotation right after generation - this is the only time the generated
+// private keys are surfaced, afterwards they live only in the backend.
+// If the hook returns an error, the rotation is aborted before
+// the new certificate authority is persisted.
+func (a *AuthServer) OnKeyGenerated(fn KeyEscrowFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.keyEscrowHook = fn
+}
+
+// getKeyEscrowHook returns currently registered key escrow hook if any.
+func (a *AuthServer) getKeyEscrowHook() KeyEscrowFunc {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.keyEscrowHook
+}
+
+// RotationPhaseHookFunc is a hook invoked with the certificate authority
+// type and the full rotation after every successfully persisted phase
+// transition, e.g. to push targeted reload signals to servers when the
+// rotation enters the update_servers phase.
+type RotationPhaseHookFunc func(caType services.CertAuthType, rotation services.Rotation) error
+
+// OnRotationPhase registers a hook that fires once per certificate
+// authority type after every rotation phase transition has been written
+// to the backend, covering both manual and automatic transitions. A hook
+// error is logged and never blocks the rotation state machine.
+func (a *AuthServer) OnRotationPhase(fn RotationPhaseHookFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationPhaseHook = fn
+}
+
+// getRotationPhaseHook returns the currently registered rotation phase
+// hook if any.
+func (a *AuthServer) getRotationPhaseHook() RotationPhaseHookFunc {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.rotationPhaseHook
+}
+
+// RotationEvent is a rotation lifecycle event delivered to registered
+// rotation notifiers on rotation start, each phase transition,
+// completion and rollback.
+type RotationEvent struct {
+	// Type is a certificate authority type being rotated.
+	Type services.CertAuthType `json:"type"`
+	// State is the rotation state after the transition.
+	State string `json:"state"`
+	// Phase is the rotation phase after the transition.
+	Phase string `json:"phase"`
+	// CurrentID is the ID of the rotation operation.
+	CurrentID string `json:"current_id,omitempty"`
+	// Initiator identifies who initiated the transition - the name of
+	// the user for operator driven transitions, "auto" for transitions
+	// applied by the automatic rotation schedule.
+	Initiator string `json:"initiator,omitempty"`
+	// Time is the time of the transition.
+	Time time.Time `json:"time"`
+}
+
+// SetMigrationCheck injects a predicate reporting whether a backend
+// migration is in progress. Starting a rotation is refused and transitions
+// of ongoing rotations are paused while the predicate returns true,
+// so the two operations never overlap.
+func (a *AuthServer) SetMigrationCheck(fn func() bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.migrationCheck = fn
+}
+
+// migrationInProgress returns true if the injected migration predicate
+// reports an ongoing backend migration.
+func (a *AuthServer) migrationInProgress() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.migrationCheck != nil && a.migrationCheck()
+}
+
+// rotationInitiatorAuto marks rotation lifecycle events triggered
+// by the automatic rotation schedule rather than an operator.
+const rotationInitiatorAuto = "auto"
+
+// RotationNotifier delivers rotation lifecycle events to an external
+// sink, e.g. a chat or incident management system. Notifier failures
+// are logged and never block the rotation or other notifiers.
+type RotationNotifier interface {
+	// Notify is called with every rotation lifecycle event.
+	Notify(RotationEvent) error
+}
+
+// RegisterRotationNotifier registers a sink to receive rotation lifecycle
+// events, multiple notifiers can be registered and each one is invoked
+// for every event.
+func (a *AuthServer) RegisterRotationNotifier(notifier RotationNotifier) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationNotifiers = append(a.rotationNotifiers, notifier)
+}
+
+// notifyRotation delivers the rotation lifecycle event to all registered
+// notifiers, a failure of one notifier does not block the others.
+func (a *AuthServer) notifyRotation(caType services.CertAuthType, rotation services.Rotation, initiator string) {
+	a.lock.Lock()
+	notifiers := make([]RotationNotifier, len(a.rotationNotifiers))
+	copy(notifiers, a.rotationNotifiers)
+	a.lock.Unlock()
+	event := RotationEvent{
+		Type:      caType,
+		State:     rotation.State,
+		Phase:     rotation.Phase,
+		CurrentID: rotation.CurrentID,
+		Initiator: initiator,
+		Time:      a.clock.Now().UTC(),
+	}
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(event); err != nil {
+			log.Warningf("Rotation notifier failed: %v.", err)
+		}
+	}
+}
+
+// finishRotationTransition finalizes a successfully persisted rotation
+// transition: it records the transition in the rotation history, delivers
+// the lifecycle event to registered notifiers and emits a structured audit
+// event, covering manual and automatic transitions from a single place.
+func (a *AuthServer) finishRotationTransition(caType services.CertAuthType, prev, current services.Rotation, initiator, reason, attemptedFingerprint string) {
+	// transitions of an ongoing rotation carry the reason it was started
+	// with unless the caller supplies a more specific one
+	if reason == "" {
+		reason = current.Reason
+	}
+	a.recordRotationTransition(caType, current, initiator, reason, attemptedFingerprint)
+	a.recordRotationHistory(caType, prev, current)
+	updateRotationMetrics(caType, prev, current)
+	a.notifyRotation(caType, current, initiator)
+	a.broadcastRotationChange(caType, prev, current)
+	a.notifyRotationWebhook(caType, prev, current)
+	if hook := a.getRotationPhaseHook(); hook != nil {
+		if err := hook(caType, current); err != nil {
+			log.Warningf("Rotation phase hook failed: %v.", err)
+		}
+	}
+	err := a.EmitAuditEvent(events.CertAuthorityRotationEvent, events.EventFields{
+		events.RotationCertType:    string(caType),
+		events.RotationClusterName: a.clusterName.GetClusterName(),
+		events.RotationOldPhase:    prev.Phase,
+		events.RotationNewPhase:    current.Phase,
+		events.RotationMode:        current.Mode,
+		events.RotationID:          current.CurrentID,
+		events.RotationReason:      reason,
+		events.EventUser:           initiator,
+	})
+	if err != nil {
+		log.Warningf("Failed to emit rotation audit event: %v.", err)
+	}
+}
+
+// RotationStatus is a rotation status of a certificate authority
+// at a certain point in time.
+type RotationStatus struct {
+	// Type is a certificate authority type.
+	Type services.CertAuthType `json:"type"`
+	// State is a rotation state, e.g. "standby" or "in_progress".
+	State string `json:"state"`
+	// Phase is a rotation phase within the current state.
+	Phase string `json:"phase"`
+	// Mode is a rotation mode, "manual" or "auto".
+	Mode string `json:"mode,omitempty"`
+	// CurrentID is the ID of the rotation operation.
+	CurrentID string `json:"current_id,omitempty"`
+	// Started is the time the rotation was started.
+	Started time.Time `json:"started,omitempty"`
+	// GracePeriod is the grace period of the rotation.
+	GracePeriod services.Duration `json:"grace_period,omitempty"`
+	// Schedule holds the times of the automatic phase transitions.
+	Schedule services.RotationSchedule `json:"schedule,omitempty"`
+	// PhaseTimes is the time each phase of the rotation was actually
+	// entered, the observed counterpart of Schedule, see
+	// services.Rotation.PhaseTimes.
+	PhaseTimes map[string]time.Time `json:"phase_times,omitempty"`
+	// InitiatedBy is the name of the user who started or scheduled
+	// the rotation, "auto" for rotations started by the schedule.
+	InitiatedBy string `json:"initiated_by,omitempty"`
+	// RollbackInitiatedBy is the name of the user who triggered
+	// the rollback of the rotation, if it has been rolled back.
+	RollbackInitiatedBy string `json:"rollback_initiated_by,omitempty"`
+	// Reason is the operator supplied reason the rotation was started
+	// with, see RotateRequest.Reason.
+	Reason string `json:"reason,omitempty"`
+	// NextTransitionIn is the remaining time until the next automatic
+	// transition, set when the rotation advances on its own - in auto
+	// mode or while waiting for a scheduled start time.
+	NextTransitionIn time.Duration `json:"next_transition_in,omitempty"`
+	// NextTransition is the absolute time of the next automatic
+	// transition, the counterpart of NextTransitionIn for displaying
+	// an ETA.
+	NextTransition time.Time `json:"next_transition,omitempty"`
+	// PercentComplete estimates the progress of the rotation in percent
+	// for progress displays, see rotationPercentComplete for how it is
+	// derived.
+	PercentComplete int `json:"percent_complete"`
+	// Time is the time the status was recorded or observed.
+	Time time.Time `json:"time"`
+	// Revision is a token that increases on every rotation transition,
+	// pass it back on RotateRequest.Revision to detect whether the
+	// rotation has moved since the status was observed.
+	Revision int64 `json:"revision"`
+	// TLSKeyPairCount is the number of TLS key pairs currently set up
+	// on the certificate authority, a healthy standby authority has
+	// exactly one, during rotation it has two.
+	TLSKeyPairCount int `json:"tls_key_pair_count,omitempty"`
+	// CheckingKeyCount is the number of SSH checking keys currently
+	// trusted on the certificate authority, two during the rotation
+	// overlap window and one on standby, see TrustedKeyCount.
+	CheckingKeyCount int `json:"checking_key_count,omitempty"`
+	// DetectedClockSkew is set when the rotation start time is ahead of
+	// the local clock, indicating clock skew between the auth servers
+	// driving the rotation, see SetRotationSkewMargin.
+	DetectedClockSkew time.Duration `json:"detected_clock_skew,omitempty"`
+	// KeySetHealthy is true when the number of key pairs matches
+	// the number expected for the current rotation phase, any mismatch
+	// indicates e.g. an orphaned key pair left by an interrupted rotation.
+	KeySetHealthy bool `json:"key_set_healthy,omitempty"`
+}
+
+// GetRotationStatus returns the current rotation status of the certificate
+// authorities of the given type, or of all certificate authorities
+// if the type is empty. The status is read-only and never
+// loads private keys, so it is safe to expose to lower-privilege contexts.
+func (a *AuthServer) GetRotationStatus(caType services.CertAuthType) ([]RotationStatus, error) {
+	caTypes := []services.CertAuthType{caType}
+	implicit := caType == ""
+	if implicit {
+		caTypes = services.CertAuthTypes
+	} else if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out := make([]RotationStatus, 0, len(caTypes))
+	for _, caType := range caTypes {
+		status, err := a.rotationStatusForCA(caType)
+		if err != nil {
+			// not every cluster has all certificate authority types set
+			// up, e.g. the database one is optional, skip the missing
+			// ones when enumerating all types implicitly
+			if implicit && trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, *status)
+	}
+	return out, nil
+}
+
+// ListRotatingCertAuthorities returns every certificate authority known
+// to this auth server, including the ones of trusted clusters, whose
+// rotation is currently in progress, for fleet-wide health checks
+// flagging clusters stuck mid-rotation. The certificate authorities are
+// loaded without private keys and the returned slice is empty, not nil,
+// when everything is on standby.
+func (a *AuthServer) ListRotatingCertAuthorities() ([]services.CertAuthority, error) {
+	out := make([]services.CertAuthority, 0)
+	for _, caType := range services.CertAuthTypes {
+		cas, err := a.Trust.GetCertAuthorities(caType, false)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		for _, ca := range cas {
+			if ca.GetRotation().State == services.RotationStateInProgress {
+				out = append(out, ca)
+			}
+		}
+	}
+	return out, nil
+}
+
+// rotationStatusForCA collects the rotation status of the certificate
+// authority of the given type, including the key pair count health signal.
+func (a *AuthServer) rotationStatusForCA(caType services.CertAuthType) (*RotationStatus, error) {
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	revision, err := a.getRotationRevision(caType)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	now := a.clock.Now().UTC()
+	checkingKeys, tlsKeyPairs := TrustedKeyCount(ca)
+	status := &RotationStatus{
+		Type:                caType,
+		State:               rotation.State,
+		Phase:               rotation.Phase,
+		Mode:                rotation.Mode,
+		CurrentID:           rotation.CurrentID,
+		Started:             rotation.Started,
+		GracePeriod:         rotation.GracePeriod,
+		Schedule:            rotation.Schedule,
+		PhaseTimes:          rotation.PhaseTimes,
+		InitiatedBy:         rotation.InitiatedBy,
+		RollbackInitiatedBy: rotation.RollbackInitiatedBy,
+		Reason:              rotation.Reason,
+		Revision:            revision,
+		Time:                now,
+		TLSKeyPairCount:     tlsKeyPairs,
+		CheckingKeyCount:    checkingKeys,
+	}
+	// a rotation retaining extra generations keeps correspondingly more
+	// key pairs in the trusted set, the retained generations accumulate
+	// over successive rotations, so any count up to the configured
+	// retention is healthy, see RotateRequest.RetainGenerations
+	expectedPairs := expectedTLSKeyPairCount(rotation.Phase)
+	maxPairs := expectedPairs
+	if rotation.RetainGenerations > 1 {
+		maxPairs += rotation.RetainGenerations - 1
+	}
+	// pinned certificates keep extra certificate-only entries around,
+	// see RotateRequest.PinnedCerts
+	maxPairs += len(rotation.PinnedCerts)
+	status.KeySetHealthy = status.TLSKeyPairCount >= expectedPairs && status.TLSKeyPairCount <= maxPairs
+	// a rotation start time in the local future indicates clock skew
+	// between the auth servers driving the rotation
+	if rotation.Started.After(now) {
+		status.DetectedClockSkew = rotation.Started.Sub(now)
+	}
+	// report the time remaining until the rotation advances on its own
+	var next time.Time
+	switch {
+	case rotation.State == services.RotationStateScheduled:
+		next = rotation.StartAt
+	case rotation.Mode == services.RotationModeAuto && rotation.State == services.RotationStateInProgress:
+		switch rotation.Phase {
+		case services.RotationPhaseUpdateClients:
+			next = rotation.Schedule.UpdateServers
+		case services.RotationPhaseUpdateServers:
+			next = rotation.Schedule.Standby
+		}
+	}
+	if !next.IsZero() && next.After(now) {
+		status.NextTransitionIn = next.Sub(now)
+		status.NextTransition = next
+	}
+	status.PercentComplete = rotationPercentComplete(rotation, now)
+	return status, nil
+}
+
+// rotationPercentComplete estimates the progress of the rotation as a
+// percentage, clamped to [0, 100], for progress displays. An automatic
+// rotation progresses on the clock between its start time and the standby
+// boundary of its schedule, a manual rotation advances in discrete steps
+// per completed phase. A rollback reports zero - the rotation is headed
+// back to where it started - and a certificate authority on standby is
+// always fully complete, whether or not it was ever rotated.
+func rotationPercentComplete(rotation services.Rotation, now time.Time) int {
+	switch rotation.State {
+	case services.RotationStateScheduled:
+		return 0
+	case services.RotationStateInProgress:
+	default:
+		return 100
+	}
+	if rotation.Phase == services.RotationPhaseRollback {
+		return 0
+	}
+	if rotation.Mode == services.RotationModeAuto &&
+		rotation.Schedule.Standby.After(rotation.Started) {
+		total := rotation.Schedule.Standby.Sub(rotation.Started)
+		elapsed := now.Sub(rotation.Started)
+		percent := int(elapsed * 100 / total)
+		if percent < 0 {
+			return 0
+		}
+		if percent > 100 {
+			return 100
+		}
+		return percent
+	}
+	switch rotation.Phase {
+	case services.RotationPhaseUpdateClients:
+		return 33
+	case services.RotationPhaseUpdateServers:
+		return 66
+	}
+	return 0
+}
+
+// RotationPlanEntry is one step of a previewed rotation plan: the phase
+// the rotation switches to and the time it does so.
+type RotationPlanEntry struct {
+	// Phase is the rotation phase the plan switches to.
+	Phase string `json:"phase"`
+	// Time is the time the plan switches to the phase.
+	Time time.Time `json:"time"`
+}
+
+// PreviewRotationPlan returns the concrete transition times a rotation
+// started right now would follow, e.g. "update_clients now,
+// update_servers at T+8h, standby at T+16h", without touching any
+// certificate authority. The plan is derived through the same request
+// validation and schedule generation that starting the rotation would
+// run, so the preview matches the actual behavior. A grace period of
+// zero previews a forced rotation that completes in a single step. In
+// manual mode the returned times past the start are the advisory
+// schedule the rotation records - the transitions themselves happen
+// whenever the operator requests them.
+func PreviewRotationPlan(clock clockwork.Clock, gracePeriod time.Duration, mode string, schedule *services.RotationSchedule) ([]RotationPlanEntry, error) {
+	req := RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        mode,
+		Schedule:    schedule,
+		// nothing is dropped by previewing a forced rotation
+		AcknowledgeForce: gracePeriod == 0,
+	}
+	if err := req.CheckAndSetDefaults(clock); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	now := clock.Now().UTC()
+	if _, isForce := req.EffectiveGracePeriod(); isForce {
+		// a forced rotation drops the old certificate authority right
+		// away and completes in one step, see startNewRotation
+		return []RotationPlanEntry{
+			{Phase: services.RotationPhaseStandby, Time: now},
+		}, nil
+	}
+	return []RotationPlanEntry{
+		{Phase: services.RotationPhaseUpdateClients, Time: now},
+		{Phase: services.RotationPhaseUpdateServers, Time: req.Schedule.UpdateServers},
+		{Phase: services.RotationPhaseStandby, Time: req.Schedule.Standby},
+	}, nil
+}
+
+// rotationStatusVersion is the version of the stable JSON schema produced
+// by RotationStatusOutput, bump it when the schema changes incompatibly.
+const rotationStatusVersion = "v1"
+
+// RotationStatusOutput is a CLI oriented view of the rotation status of
+// a certificate authority. It serializes with a stable, versioned JSON
+// schema so scripts consuming e.g. "tctl get rotation --format=json"
+// keep working when internal types change.
+type RotationStatusOutput struct {
+	caType       services.CertAuthType
+	rotation     services.Rotation
+	fingerprints []string
+	keyPairCount int
+	initiator    string
+	reason       string
+}
+
+// GetRotationStatusOutput returns the full rotation status of the
+// certificate authority of the given type for CLI consumption.
+func (a *AuthServer) GetRotationStatusOutput(caType services.CertAuthType) (*RotationStatusOutput, error) {
+	if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	fingerprints, err := TrustSetFingerprints(ca)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out := &RotationStatusOutput{
+		caType:       caType,
+		rotation:     ca.GetRotation(),
+		fingerprints: fingerprints,
+		keyPairCount: len(ca.GetTLSKeyPairs()),
+	}
+	// the initiator and reason of the latest transition come
+	// from the recorded rotation history
+	transitions, err := a.getRotationTransitions(caType)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(transitions) != 0 {
+		last := transitions[len(transitions)-1]
+		out.initiator = last.Initiator
+		out.reason = last.Reason
+	}
+	return out, nil
+}
+
+// MarshalJSON serializes the rotation status with the stable JSON schema,
+// see rotationStatusVersion.
+func (o *RotationStatusOutput) MarshalJSON() ([]byte, error) {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	state := o.rotation.State
+	if state == "" {
+		state = services.RotationStateStandby
+	}
+	phase := o.rotation.Phase
+	if phase == "" {
+		phase = services.RotationPhaseStandby
+	}
+	var gracePeriod string
+	if o.rotation.GracePeriod.Duration != 0 {
+		gracePeriod = o.rotation.GracePeriod.Duration.String()
+	}
+	out := struct {
+		Version         string   `json:"version"`
+		Type            string   `json:"type"`
+		State           string   `json:"state"`
+		Phase           string   `json:"phase"`
+		Mode            string   `json:"mode,omitempty"`
+		CurrentID       string   `json:"current_id,omitempty"`
+		Started         string   `json:"started,omitempty"`
+		LastRotated     string   `json:"last_rotated,omitempty"`
+		GracePeriod     string   `json:"grace_period,omitempty"`
+		ScheduleServers string   `json:"schedule_update_servers,omitempty"`
+		ScheduleStandby string   `json:"schedule_standby,omitempty"`
+		KeyFingerprints []string `json:"key_fingerprints"`
+		KeyPairCount    int      `json:"key_pair_count"`
+		Initiator       string   `json:"initiator,omitempty"`
+		Reason          string   `json:"reason,omitempty"`
+	}{
+		Version:         rotationStatusVersion,
+		Type:            string(o.caType),
+		State:           state,
+		Phase:           phase,
+		Mode:            o.rotation.Mode,
+		CurrentID:       o.rotation.CurrentID,
+		Started:         formatTime(o.rotation.Started),
+		LastRotated:     formatTime(o.rotation.LastRotated),
+		GracePeriod:     gracePeriod,
+		ScheduleServers: formatTime(o.rotation.Schedule.UpdateServers),
+		ScheduleStandby: formatTime(o.rotation.Schedule.Standby),
+		KeyFingerprints: o.fingerprints,
+		KeyPairCount:    o.keyPairCount,
+		Initiator:       o.initiator,
+		Reason:          o.reason,
+	}
+	return json.Marshal(out)
+}
+
+// TrustedKeyCount returns the number of SSH checking keys and TLS key
+// pairs currently trusted on the certificate authority. During the
+// rotation overlap window both counts are two - the new and the old
+// generation - and drop back to one when the rotation completes, so the
+// counts make it easy to verify the overlap invariant at every phase.
+func TrustedKeyCount(ca services.CertAuthority) (checkingKeys, tlsKeyPairs int) {
+	return len(ca.GetCheckingKeys()), len(ca.GetTLSKeyPairs())
+}
+
+// expectedTLSKeyPairCount returns the number of TLS key pairs a healthy
+// certificate authority is expected to have in the given rotation phase:
+// one on standby, two while a rotation or rollback is in progress.
+func expectedTLSKeyPairCount(phase string) int {
+	switch phase {
+	case services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseRollback:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// rotationTransition is a recorded transition of the rotation state machine,
+// used to reconstruct rotation state at a certain point in time.
+type rotationTransition struct {
+	// Time is the time the transition took place.
+	Time time.Time `json:"time"`
+	// State is a rotation state set by the transition.
+	State string `json:"state"`
+	// Phase is a rotation phase set by the transition.
+	Phase string `json:"phase"`
+	// Mode is a rotation mode of the transition.
+	Mode string `json:"mode,omitempty"`
+	// CurrentID is the ID of the rotation operation.
+	CurrentID string `json:"current_id,omitempty"`
+	// Initiator identifies who initiated the transition.
+	Initiator string `json:"initiator,omitempty"`
+	// Reason is an optional operator supplied reason for the rotation.
+	Reason string `json:"reason,omitempty"`
+	// AttemptedFingerprint is the fingerprint of the key the rolled back
+	// rotation attempted to switch to, recorded when the rollback
+	// completes so the attempted public key is not lost to post-mortem
+	// analysis when it is dropped from the certificate authority.
+	AttemptedFingerprint string `json:"attempted_fingerprint,omitempty"`
+}
+
+// rotationTransitionsBucket is a backend bucket keeping the record
+// of rotation state machine transitions per certificate authority type.
+func rotationTransitionsBucket(caType services.CertAuthType) []string {
+	return []string{"rotations", string(caType)}
+}
+
+// recordRotationTransition records the transition of the rotation state
+// machine, the record is best-effort and does not fail the rotation.
+func (a *AuthServer) recordRotationTransition(caType services.CertAuthType, rotation services.Rotation, initiator string, reason string, attemptedFingerprint string) {
+	transition := rotationTransition{
+		Time:                 a.clock.Now().UTC(),
+		State:                rotation.State,
+		Phase:                rotation.Phase,
+		Mode:                 rotation.Mode,
+		CurrentID:            rotation.CurrentID,
+		Initiator:            initiator,
+		Reason:               reason,
+		AttemptedFingerprint: attemptedFingerprint,
+	}
+	data, err := json.Marshal(transition)
+	if err != nil {
+		log.Warningf("Failed to marshal rotation transition: %v.", err)
+		return
+	}
+	key := fmt.Sprintf("%020d", transition.Time.UnixNano())
+	if err := a.bk.UpsertVal(rotationTransitionsBucket(caType), key, data, backend.Forever); err != nil {
+		log.Warningf("Failed to record rotation transition: %v.", err)
+	}
+}
+
+// getRotationTransitions returns recorded transitions of the rotation state
+// machine for a certificate authority sorted by time.
+func (a *AuthServer) getRotationTransitions(caType services.CertAuthType) ([]rotationTransition, error) {
+	bucket := rotationTransitionsBucket(caType)
+	keys, err := a.bk.GetKeys(bucket)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	sort.Strings(keys)
+	out := make([]rotationTransition, 0, len(keys))
+	for _, key := range keys {
+		data, err := a.bk.GetVal(bucket, key)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		var transition rotationTransition
+		if err := json.Unmarshal(data, &transition); err != nil {
+			return nil, trace.BadParameter("failed to unmarshal rotation transition: %v", err)
+		}
+		out = append(out, transition)
+	}
+	return out, nil
+}
+
+// getRotationRevision returns the revision token of the rotation of the
+// certificate authority - the number of recorded transitions. Transitions
+// are only ever appended, so the revision is monotonically increasing and
+// changes on every phase transition.
+func (a *AuthServer) getRotationRevision(caType services.CertAuthType) (int64, error) {
+	keys, err := a.bk.GetKeys(rotationTransitionsBucket(caType))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, trace.Wrap(err)
+	}
+	return int64(len(keys)), nil
+}
+
+// RotationStateAt replays recorded rotation transitions and reconstructs
+// the rotation state of the certificate authority at the given point in
+// time, it returns an error if the recorded history does not cover
+// the requested time.
+func (a *AuthServer) RotationStateAt(caType services.CertAuthType, at time.Time) (*RotationStatus, error) {
+	if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	transitions, err := a.getRotationTransitions(caType)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(transitions) == 0 || at.Before(transitions[0].Time) {
+		return nil, trace.NotFound(
+			"rotation history of %q certificate authority does not cover %v", caType, at)
+	}
+	// replay transitions up to the requested time, the last one
+	// that happened before it defines the state
+	current := transitions[0]
+	for _, transition := range transitions[1:] {
+		if transition.Time.After(at) {
+			break
+		}
+		current = transition
+	}
+	return &RotationStatus{
+		Type:      caType,
+		State:     current.State,
+		Phase:     current.Phase,
+		Mode:      current.Mode,
+		CurrentID: current.CurrentID,
+		Time:      current.Time,
+	}, nil
+}
+
+// SimulateRollback computes the rotation state a rollback followed by its
+// completion would produce from the current state of the certificate
+// authority, without writing anything, and validates that the resulting
+// trust set is coherent. It gives operators assurance that the rollback
+// escape hatch works before advancing to the disruptive update_servers
+// phase.
+func (a *AuthServer) SimulateRollback(caType services.CertAuthType) (*RotationStatus, error) {
+	if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// the fetched copy is never written back, so it is safe to run
+	// the rollback transitions on it
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch ca.GetRotation().Phase {
+	case services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers:
+	default:
+		return nil, trace.BadParameter(
+			"rollback is only possible from %q or %q phase, current phase is %q",
+			services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers, ca.GetRotation().Phase)
+	}
+	if err := startRollingBackRotation(ca, ""); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := completeRollingBackRotation(a.clock, ca); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// the rolled back certificate authority has to end up with exactly
+	// one coherent key set - the old one
+	if err := CheckTrustSetUnique(ca); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(ca.GetSigningKeys()) != 1 || len(ca.GetCheckingKeys()) != 1 || len(ca.GetTLSKeyPairs()) != 1 {
+		return nil, trace.BadParameter(
+			"rollback of %q certificate authority would leave an incoherent key set", caType)
+	}
+	rotation := ca.GetRotation()
+	return &RotationStatus{
+		Type:            caType,
+		State:           rotation.State,
+		Phase:           rotation.Phase,
+		Mode:            rotation.Mode,
+		CurrentID:       rotation.CurrentID,
+		Time:            a.clock.Now().UTC(),
+		TLSKeyPairCount: len(ca.GetTLSKeyPairs()),
+		KeySetHealthy:   true,
+	}, nil
+}
+
+// NormalizeRotationFields enforces coherence rules on the rotation fields
+// of the certificate authority and repairs violations left behind by older
+// buggy versions: standby state implies empty mode, schedule and start
+// times, a scheduled rotation implies a pending start time. It refuses to
+// touch a rotation that is actively in progress and fails with
+// CompareFailed if the rotation state changes concurrently.
+func (a *AuthServer) NormalizeRotationFields(caType services.CertAuthType) (changed bool, err error) {
+	if err := caType.Check(); err != nil {
+		return false, trace.Wrap(err)
+	}
+	id := services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}
+	ca, err := a.Trust.GetCertAuthority(id, true)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	fixed := rotation
+	var fixes []string
+	switch rotation.State {
+	case services.RotationStateInProgress:
+		switch rotation.Phase {
+		case services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers, services.RotationPhaseRollback:
+			return false, trace.BadParameter(
+				"rotation of %q certificate authority is actively in progress, refusing to normalize", caType)
+		default:
+			return false, trace.BadParameter(
+				"rotation of %q certificate authority is in progress with invalid phase %q and can not be repaired automatically", caType, rotation.Phase)
+		}
+	case services.RotationStateScheduled:
+		if fixed.StartAt.IsZero() {
+			// a scheduled rotation without a start time would never
+			// fire, demote it back to standby
+			fixed.State = services.RotationStateStandby
+			fixes = append(fixes, "state: standby")
+		}
+		if fixed.Phase != "" && fixed.Phase != services.RotationPhaseStandby {
+			fixed.Phase = services.RotationPhaseStandby
+			fixes = append(fixes, "phase: standby")
+		}
+	case "", services.RotationStateStandby:
+		if fixed.Phase != "" && fixed.Phase != services.RotationPhaseStandby {
+			fixed.Phase = services.RotationPhaseStandby
+			fixes = append(fixes, "phase: standby")
+		}
+		if fixed.Mode != "" {
+			fixed.Mode = ""
+			fixes = append(fixes, "cleared mode")
+		}
+		if fixed.Schedule != (services.RotationSchedule{}) {
+			fixed.Schedule = services.RotationSchedule{}
+			fixes = append(fixes, "cleared schedule")
+		}
+		if !fixed.Started.IsZero() {
+			fixed.Started = time.Time{}
+			fixes = append(fixes, "cleared start time")
+		}
+		if !fixed.StartAt.IsZero() {
+			fixed.StartAt = time.Time{}
+			fixes = append(fixes, "cleared scheduled start time")
+		}
+		if fixed.Paused || !fixed.PausedAt.IsZero() {
+			fixed.Paused = false
+			fixed.PausedAt = time.Time{}
+			fixes = append(fixes, "cleared paused flag")
+		}
+	default:
+		return false, trace.BadParameter(
+			"rotation of %q certificate authority has unknown state %q and can not be repaired automatically", caType, rotation.State)
+	}
+	if len(fixes) == 0 {
+		return false, nil
+	}
+	// make sure the rotation state has not been changed concurrently,
+	// e.g. by an operator starting a rotation while the repair runs
+	fresh, err := a.Trust.GetCertAuthority(id, true)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	current := fresh.GetRotation()
+	if !current.Matches(rotation) {
+		return false, trace.CompareFailed(
+			"rotation of %q certificate authority changed concurrently, try again", caType)
+	}
+	fresh.SetRotation(fixed)
+	if err := a.Trust.UpsertCertAuthority(fresh); err != nil {
+		return false, trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Normalized incoherent rotation fields: %v.", strings.Join(fixes, ", "))
+	return true, nil
+}
+
+// RotateCertAuthority starts or restarts certificate authority rotation process.
+//
+// Rotation procedure is based on the state machine approach.
+//
+// Here are the supported rotation states:
+//
+//   - Standby - the cluster is in standby mode and ready to take action.
+//   - In-progress - rotation is in progress.
+//
+// In-progress state is split into multiple phases and the rotation
+// can be switched from one phase to another in this order:
+//
+//   - Standby - no action is taken.
+//   - UpdateClients - new certificate authority is issued, and is used
+//     to issue credentials to the clients, servers are still serving
+//     the certificates issued by the old certificate authority.
+//   - UpdateServers - servers reload and start serving certificates
+//     signed by the new certificate authority.
+//   - Standby - rotation is completed, old certificate authority is removed
+//     from the trusted list.
+//
+// Rotation can also be rolled back from UpdateClients or UpdateServers
+// phases by setting Rollback phase that reverts the cluster to the old
+// certificate authority, and completing the rollback with Standby phase.
+//
+// In automatic mode the phases are set automatically based
+// on the generated schedule, in manual mode the operator drives
+// every phase transition.
+func (a *AuthServer) RotateCertAuthority(req RotateRequest) error {
+	_, err := a.RotateCertAuthorityContext(context.TODO(), req)
+	return trace.Wrap(err)
+}
+
+// RotateCertAuthorityWithResult is RotateCertAuthority returning the
+// certificate authorities it has persisted, one per rotated type, so
+// callers can inspect the resulting rotation state without re-fetching
+// and racing with concurrent rotations. Dry runs persist nothing and
+// return no certificate authorities.
+func (a *AuthServer) RotateCertAuthorityWithResult(req RotateRequest) ([]services.CertAuthority, error) {
+	return a.RotateCertAuthorityContext(context.TODO(), req)
+}
+
+// RotateCertAuthorityContext is RotateCertAuthorityWithResult honoring
+// cancellation of the supplied context, checked before every certificate
+// authority type, so a shutdown signal does not leave the request hanging
+// on a slow backend longer than necessary.
+func (a *AuthServer) RotateCertAuthorityContext(ctx context.Context, req RotateRequest) ([]services.CertAuthority, error) {
+	outcome, err := a.RotateCertAuthorityOutcome(ctx, req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return outcome.Rotated, trace.Wrap(outcome.Err())
+}
+
+// RotationOutcome reports the outcome of a rotation request spanning
+// multiple certificate authority types. Every type is attempted
+// independently, so a failing type does not abandon the remaining ones
+// halfway through the list, and the per type errors tell the caller
+// exactly which types have moved and which have to be reconciled.
+type RotationOutcome struct {
+	// Rotated holds the certificate authorities that have been
+	// successfully rotated, one per type.
+	Rotated []services.CertAuthority
+	// Errors maps every certificate authority type that failed to
+	// rotate to the error that stopped it.
+	Errors map[services.CertAuthType]error
+}
+
+// Err folds the per type errors into a single error, nil when every
+// type rotated successfully. A single failure is returned as is so its
+// classification, e.g. trace.IsBadParameter, is preserved.
+func (o *RotationOutcome) Err() error {
+	errors := make([]error, 0, len(o.Errors))
+	for _, caType := range services.CertAuthTypes {
+		if err, ok := o.Errors[caType]; ok {
+			errors = append(errors, err)
+		}
+	}
+	switch len(errors) {
+	case 0:
+		return nil
+	case 1:
+		return trace.Wrap(errors[0])
+	default:
+		return trace.NewAggregate(errors...)
+	}
+}
+
+// RotateCertAuthorityOutcome rotates every requested certificate
+// authority type and reports the outcome of each type separately.
+// Unlike RotateCertAuthority it does not stop at the first failing
+// type, so when e.g. the host certificate authority advances and the
+// user one fails, the caller sees exactly which types diverged instead
+// of guessing how far the request got.
+func (a *AuthServer) RotateCertAuthorityOutcome(ctx context.Context, req RotateRequest) (*RotationOutcome, error) {
+	if req.DryRun {
+		if _, err := a.dryRunRotateCertAuthority(ctx, req); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &RotationOutcome{}, nil
+	}
+	// rotation and backend migration both touch the same critical data,
+	// never let them overlap
+	if a.migrationInProgress() {
+		return nil, trace.BadParameter(
+			"can not rotate certificate authorities while backend migration is in progress, retry after the migration completes")
+	}
+	caTypes := req.Types()
+	if len(caTypes) == 0 {
+		return nil, trace.BadParameter("unsupported certificate authority type: %q", req.Type)
+	}
+	// validate the request as a whole before attempting any type,
+	// a malformed request is rejected once instead of being reported
+	// as a separate error for every type, the per type validation
+	// still runs after the rotation policy has been applied
+	checkReq := req
+	if err := checkReq.CheckAndSetDefaults(a.clock); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	a.warnOnShortGracePeriod(checkReq)
+	outcome := &RotationOutcome{
+		Rotated: make([]services.CertAuthority, 0, len(caTypes)),
+		Errors:  make(map[services.CertAuthType]error),
+	}
+	for _, caType := range caTypes {
+		if err := ctx.Err(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		rotated, err := a.rotateCertAuthorityType(caType, req)
+		if err != nil {
+			outcome.Errors[caType] = err
+			continue
+		}
+		// optional types not set up in the cluster are skipped,
+		// see rotateCertAuthorityType
+		if rotated == nil {
+			continue
+		}
+		outcome.Rotated = append(outcome.Rotated, rotated)
+	}
+	return outcome, nil
+}
+
+// warnOnShortGracePeriod compares the grace period of a starting rotation
+// against the expected node reconnect duration and flags the risk of
+// orphaning nodes with a warning event and a log entry. The rotation is
+// not blocked - the operator may know the fleet better than the
+// configured expectation, see SetExpectedReconnectDuration.
+func (a *AuthServer) warnOnShortGracePeriod(req RotateRequest) {
+	expected := a.getExpectedReconnectDuration()
+	if expected == 0 || req.TargetPhase != services.RotationPhaseUpdateClients {
+		return
+	}
+	gracePeriod, isForce := req.EffectiveGracePeriod()
+	// a forced rotation has already been acknowledged as disruptive
+	if isForce || gracePeriod >= expected {
+		return
+	}
+	warning := fmt.Sprintf(
+		"rotation grace period %v is shorter than the expected node reconnect time %v, nodes that do not reconnect within the grace period will be orphaned, consider a longer grace period",
+		gracePeriod, expected)
+	log.WithFields(logrus.Fields{"type": req.Type}).Warningf("%v.", warning)
+	err := a.EmitAuditEvent(events.CertAuthorityRotationWarningEvent, events.EventFields{
+		events.RotationCertType:    string(req.Type),
+		events.RotationClusterName: a.clusterName.GetClusterName(),
+		events.RotationGracePeriod: gracePeriod.String(),
+		events.RotationWarning:     warning,
+	})
+	if err != nil {
+		log.Warningf("Failed to emit rotation warning audit event: %v.", err)
+	}
+}
+
+// rotateCertAuthorityType runs the rotation request against a single
+// certificate authority type and returns the persisted certificate
+// authority, or nil when all types are rotated implicitly and this
+// optional type is not set up in the cluster.
+func (a *AuthServer) rotateCertAuthorityType(caType services.CertAuthType, req RotateRequest) (services.CertAuthority, error) {
+	clusterName := a.clusterName.GetClusterName()
+	// the rotation policy of the certificate authority, if set,
+	// provides the baseline for the fields not set on the request
+	policy, err := a.GetRotationPolicy(caType)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	caReq := req
+	// the per-type override takes precedence over both the top-level
+	// request fields and the rotation policy baseline
+	if override, ok := req.PerType[caType]; ok {
+		caReq.applyOverride(override)
+	}
+	caReq.PerType = nil
+	if policy != nil {
+		policy.applyToRequest(&caReq)
+	}
+	if err := caReq.CheckAndSetDefaults(a.clock); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// stagger the generated schedule of this type so the server reload
+	// waves of concurrently rotated certificate authority types do not
+	// coincide, see RotateRequest.TypeOffset
+	if offset := caReq.TypeOffset[caType]; offset > 0 && caReq.scheduleGenerated && caReq.Schedule != nil && !caReq.Schedule.UpdateServers.IsZero() {
+		caReq.Schedule.UpdateServers = caReq.Schedule.UpdateServers.Add(offset)
+	}
+	if caReq.Revision != nil {
+		revision, err := a.getRotationRevision(caType)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if revision != *caReq.Revision {
+			return nil, trace.CompareFailed(
+				"rotation of %q certificate authority is at revision %v, not %v, refresh the rotation status and retry",
+				caType, revision, *caReq.Revision)
+		}
+	}
+	// serialize concurrent operator transitions of the same certificate
+	// authority type, a losing concurrent request gets a clean error
+	// naming the holder instead of a confusing partial failure
+	if err := a.acquireRotationLock(caType, caReq.Initiator); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer a.releaseRotationLock(caType)
+	existing, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: clusterName,
+	}, true)
+	if err != nil {
+		// not every cluster has all certificate authority types set
+		// up, e.g. the database one is optional, skip the missing
+		// ones when rotating all types implicitly
+		if req.Type == "" && trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	// a regulated cluster can require a documented reason before any
+	// rotation is started or scheduled, see RotationPolicy.RequireReason
+	if policy != nil && policy.RequireReason && caReq.TargetPhase == services.RotationPhaseUpdateClients && caReq.Reason == "" {
+		return nil, trace.BadParameter(
+			"rotation policy for %q requires a reason, set one on the rotation request", caType)
+	}
+	// policy cooldown and time windows only apply to starting
+	// a new rotation, not to phase transitions of an ongoing one
+	if policy != nil && caReq.TargetPhase == services.RotationPhaseUpdateClients && caReq.StartAt == nil {
+		if err := policy.checkCanStart(a.clock.Now(), existing.GetRotation()); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	// when completing a rollback, archive the fingerprint of the key
+	// the aborted rotation attempted to switch to before it is
+	// discarded, see completeRollingBackRotation, so the attempt
+	// remains visible in the rotation history
+	var attemptedFingerprint string
+	if caReq.TargetPhase == services.RotationPhaseStandby && existing.GetRotation().Phase == services.RotationPhaseRollback {
+		if keyPairs := existing.GetTLSKeyPairs(); len(keyPairs) > 1 {
+			attemptedFingerprint, err = tlsCertFingerprint(keyPairs[1].Cert)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+		}
+	}
+	// before switching servers to the new certificate authority, make
+	// sure all auth server and proxy peers have observed it, advancing
+	// with a lagging peer would make it reject already updated clients
+	if caReq.TargetPhase == services.RotationPhaseUpdateServers && !caReq.SkipPreflight &&
+		existing.GetRotation().Phase == services.RotationPhaseUpdateClients {
+		if err := a.checkRotationPreflight(caType, existing.GetRotation().CurrentID); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	// before completing the rotation and dropping the old certificate
+	// authority from the trusted set, make sure no node has slept
+	// through the whole rotation - it would be locked out with
+	// credentials nobody trusts anymore
+	if caReq.TargetPhase == services.RotationPhaseStandby && !caReq.SkipPreflight &&
+		existing.GetRotation().Phase == services.RotationPhaseUpdateServers {
+		if err := a.checkOrphanedNodes(caType, existing.GetRotation()); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	prevRotation := existing.GetRotation()
+	gracePeriod, _ := caReq.EffectiveGracePeriod()
+	internalReq := rotationReq{
+		ca:                  existing,
+		clock:               a.clock,
+		targetPhase:         caReq.TargetPhase,
+		schedule:            *caReq.Schedule,
+		gracePeriod:         gracePeriod,
+		mode:                caReq.Mode,
+		keyStore:            a.getRotationKeyStore(),
+		onKeyGenerated:      a.getKeyEscrowHook(),
+		commonNameTemplate:  caReq.CommonNameTemplate,
+		subject:             caReq.Subject,
+		keyAlgorithm:        caReq.KeyAlgorithm,
+		keyComment:          caReq.KeyComment,
+		scope:               caReq.Scope,
+		initiator:           caReq.Initiator,
+		reason:              caReq.Reason,
+		canarySelector:      caReq.CanarySelector,
+		rotationID:          caReq.RotationID,
+		retainGenerations:   caReq.RetainGenerations,
+		pinnedCerts:         caReq.PinnedCerts,
+		keepOldTrustOnForce: caReq.KeepOldTrustOnForce,
+		importedSSH:         caReq.ImportedSSHKeyPair,
+		importedTLS:         caReq.ImportedTLSKeyPair,
+	}
+	if caReq.CATTL != nil {
+		internalReq.caTTL = *caReq.CATTL
+	}
+	if caReq.StartAt != nil {
+		internalReq.startAt = caReq.StartAt.UTC()
+	}
+	// when starting a rotation, prefer a pre-generated key set if
+	// one has been stockpiled, unless a custom common name template,
+	// subject, a non-default key algorithm, CA TTL, a scoped rotation
+	// or imported key material requires generating or installing the
+	// keys on the spot - stockpiled keys are always full RSA key sets
+	// with the default TTL and subject
+	if caReq.TargetPhase == services.RotationPhaseUpdateClients && caReq.StartAt == nil && caReq.CommonNameTemplate == "" && caReq.CATTL == nil && caReq.Subject == nil &&
+		caReq.ImportedSSHKeyPair == nil && caReq.ImportedTLSKeyPair == nil &&
+		(caReq.KeyAlgorithm == "" || caReq.KeyAlgorithm == RotationKeyAlgorithmRSA) &&
+		(caReq.Scope == "" || caReq.Scope == RotationScopeAll) {
+		internalReq.stockpiled, err = a.peekStockpiledKey(caType)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	rotated, err := processRotationRequest(internalReq)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	rotation := rotated.GetRotation()
+	// a retry of the phase the rotation is already in leaves the
+	// certificate authority untouched, there is nothing to persist
+	// or record
+	if reflect.DeepEqual(rotation, prevRotation) {
+		log.WithFields(logrus.Fields{"type": caType}).Debugf(
+			"Rotation is already in phase %q, nothing to do.", caReq.TargetPhase)
+		return rotated, nil
+	}
+	if err := a.upsertRotatedCertAuthority(rotated, caReq.TargetPhase); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// a new rotation ID means a new rotation has started, drop
+	// acknowledgements recorded for the previous one and remove
+	// the stockpiled key set the rotation has consumed
+	if rotation.CurrentID != "" && rotation.CurrentID != prevRotation.CurrentID {
+		a.resetRotationAcks(caType)
+		if internalReq.stockpiled != nil {
+			a.consumeStockpiledKey(caType, internalReq.stockpiled.ID)
+		}
+	}
+	a.finishRotationTransition(caType, prevRotation, rotation, caReq.Initiator, caReq.Reason, attemptedFingerprint)
+	switch rotation.State {
+	case services.RotationStateInProgress:
+		log.WithFields(logrus.Fields{"type": caType}).Infof("Updated rotation state, set current phase to: %q.", rotation.Phase)
+	case services.RotationStateStandby:
+		log.WithFields(logrus.Fields{"type": caType}).Infof("Completed rotation.")
+	}
+	return rotated, nil
+}
+
+// DryRunRotateCertAuthority validates the rotation request and returns the
+// rotation state it would produce, one per requested certificate authority
+// type, without persisting anything. The state machine runs on a fetched
+// copy that is never written back, so operators and CI can vet manual
+// phase transitions before touching live certificate authorities.
+func (a *AuthServer) DryRunRotateCertAuthority(req RotateRequest) ([]services.Rotation, error) {
+	return a.dryRunRotateCertAuthority(context.TODO(), req)
+}
+
+// dryRunRotateCertAuthority implements DryRunRotateCertAuthority honoring
+// cancellation of the supplied context between certificate authority types.
+func (a *AuthServer) dryRunRotateCertAuthority(ctx context.Context, req RotateRequest) ([]services.Rotation, error) {
+	clusterName := a.clusterName.GetClusterName()
+	caTypes := req.Types()
+	if len(caTypes) == 0 {
+		return nil, trace.BadParameter("unsupported certificate authority type: %q", req.Type)
+	}
+	out := make([]services.Rotation, 0, len(caTypes))
+	for _, caType := range caTypes {
+		if err := ctx.Err(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		policy, err := a.GetRotationPolicy(caType)
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		caReq := req
+		if policy != nil {
+			policy.applyToRequest(&caReq)
+		}
+		if err := caReq.CheckAndSetDefaults(a.clock); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		existing, err := a.Trust.GetCertAuthority(services.CertAuthID{
+			Type:       caType,
+			DomainName: clusterName,
+		}, true)
+		if err != nil {
+			// skip certificate authority types the cluster does not
+			// have when dry running all types implicitly, matching
+			// RotateCertAuthority
+			if req.Type == "" && trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if policy != nil && policy.RequireReason && caReq.TargetPhase == services.RotationPhaseUpdateClients && caReq.Reason == "" {
+			return nil, trace.BadParameter(
+				"rotation policy for %q requires a reason, set one on the rotation request", caType)
+		}
+		if policy != nil && caReq.TargetPhase == services.RotationPhaseUpdateClients && caReq.StartAt == nil {
+			if err := policy.checkCanStart(a.clock.Now(), existing.GetRotation()); err != nil {
+				return nil, trace.Wrap(err)
+			}
+		}
+		// no stockpiled keys are consumed and no escrow hook is invoked,
+		// any keys generated by the dry run are discarded
+		gracePeriod, _ := caReq.EffectiveGracePeriod()
+		internalReq := rotationReq{
+			ca:                  existing,
+			clock:               a.clock,
+			targetPhase:         caReq.TargetPhase,
+			schedule:            *caReq.Schedule,
+			gracePeriod:         gracePeriod,
+			mode:                caReq.Mode,
+			keyStore:            a.getRotationKeyStore(),
+			commonNameTemplate:  caReq.CommonNameTemplate,
+			subject:             caReq.Subject,
+			keyAlgorithm:        caReq.KeyAlgorithm,
+			keyComment:          caReq.KeyComment,
+			scope:               caReq.Scope,
+			initiator:           caReq.Initiator,
+			reason:              caReq.Reason,
+			canarySelector:      caReq.CanarySelector,
+			rotationID:          caReq.RotationID,
+			retainGenerations:   caReq.RetainGenerations,
+			pinnedCerts:         caReq.PinnedCerts,
+			keepOldTrustOnForce: caReq.KeepOldTrustOnForce,
+			importedSSH:         caReq.ImportedSSHKeyPair,
+			importedTLS:         caReq.ImportedTLSKeyPair,
+		}
+		if caReq.CATTL != nil {
+			internalReq.caTTL = *caReq.CATTL
+		}
+		if caReq.StartAt != nil {
+			internalReq.startAt = caReq.StartAt.UTC()
+		}
+		rotated, err := processRotationRequest(internalReq)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		rotation := rotated.GetRotation()
+		log.WithFields(logrus.Fields{"type": caType}).Infof(
+			"Dry run rotation would set state to %q, phase to %q.", rotation.State, rotation.Phase)
+		out = append(out, rotation)
+	}
+	return out, nil
+}
+
+// RotateExternalCertAuthority rotates external certificate authority,
+// this method is called by a remote trusted cluster and is used to update
+// only public keys and certificates of the certificate authority.
+func (a *AuthServer) RotateExternalCertAuthority(ca services.CertAuthority) error {
+	if ca == nil {
+		return trace.BadParameter("missing certificate authority")
+	}
+	if err := ca.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	clusterName, err := a.GetDomainName()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// this is just an extra precaution against local admins,
+	// because this endpoint is only available to remote clusters
+	if ca.GetClusterName() == clusterName {
+		return trace.BadParameter(
+			"can not rotate local certificate authority %q via the external rotation endpoint, use a local rotation request instead", clusterName)
+	}
+	// an external update arriving while the local certificate authority
+	// of the same type is mid-rotation is legitimate - the rotation
+	// state of the remote certificate authority is owned by the remote
+	// cluster - but the interleaving is worth a trace when debugging
+	// trust issues between the clusters
+	local, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       ca.GetType(),
+		DomainName: clusterName,
+	}, false)
+	switch {
+	case err != nil && !trace.IsNotFound(err):
+		return trace.Wrap(err)
+	case err == nil && local.GetRotation().State == services.RotationStateInProgress:
+		log.WithFields(logrus.Fields{"type": ca.GetType()}).Infof(
+			"Accepting external certificate authority update from %q while the local rotation is in progress.", ca.GetClusterName())
+	}
+	existing, err := a.Trust.GetCertAuthority(ca.GetID(), false)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// the update swaps trusted keys of a remote cluster, leave a trail of
+	// which remote certificate authority rotated and to what phase, the
+	// only record of the propagation otherwise lives on the remote side
+	prev := existing.GetRotation()
+	incoming := ca.GetRotation()
+	logger := log.WithFields(logrus.Fields{
+		"type":           ca.GetType(),
+		"remote_cluster": ca.GetClusterName(),
+	})
+	logger.Infof("Updating external certificate authority, rotation %q in phase %q replaces %q in phase %q.",
+		incoming.CurrentID, incoming.Phase, prev.CurrentID, prev.Phase)
+	// a rotation state of a remote certificate authority is fully
+	// owned by the remote cluster, so only public keys and certificates
+	// are being updated
+	if err := existing.SetCheckingKeys(ca.GetCheckingKeys()); err != nil {
+		return trace.Wrap(err)
+	}
+	existing.SetTLSKeyPairs(ca.GetTLSKeyPairs())
+	existing.SetRotation(incoming)
+	if err := CheckTrustSetUnique(existing); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.Trust.UpsertCertAuthority(existing); err != nil {
+		return trace.Wrap(err)
+	}
+	err = a.EmitAuditEvent(events.CertAuthorityRotationEvent, events.EventFields{
+		events.RotationCertType:    string(ca.GetType()),
+		events.RotationClusterName: ca.GetClusterName(),
+		events.RotationOldPhase:    prev.Phase,
+		events.RotationNewPhase:    incoming.Phase,
+		events.RotationID:          incoming.CurrentID,
+	})
+	if err != nil {
+		log.Warningf("Failed to emit rotation audit event: %v.", err)
+	}
+	logger.Infof("Accepted external certificate authority update, rotation %q in phase %q.",
+		incoming.CurrentID, incoming.Phase)
+	return nil
+}
+
+// autoRotateCertAuthorities automatically rotates cert authorities,
+// does nothing if no rotation parameters were set up
+// or it is too early to rotate per schedule
+func (a *AuthServer) autoRotateCertAuthorities(ctx context.Context) error {
+	// pause rotation transitions while the backend is being migrated,
+	// the rotation resumes once the migration completes
+	if a.migrationInProgress() {
+		log.Debugf("Backend migration is in progress, pausing rotation checks.")
+		return nil
+	}
+	clusterName := a.clusterName.GetClusterName()
+	for _, caType := range services.CertAuthTypes {
+		// stop promptly on shutdown instead of grinding through
+		// the remaining certificate authority types
+		if err := ctx.Err(); err != nil {
+			return trace.Wrap(err)
+		}
+		id := services.CertAuthID{
+			Type:       caType,
+			DomainName: clusterName,
+		}
+		// the decision whether anything is due only needs the rotation
+		// metadata, so check it on a read without private keys first and
+		// keep the sensitive key material out of memory on the common
+		// idle tick
+		ca, err := a.Trust.GetCertAuthority(id, false)
+		if err != nil {
+			// not every cluster has all certificate authority types
+			// set up, e.g. the database one is optional
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return trace.Wrap(err)
+		}
+		if !a.pendingRotationTransition(caType, ca.GetRotation()) {
+			continue
+		}
+		ca, err = a.Trust.GetCertAuthority(id, true)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if err := a.autoRotate(ca); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+const (
+	// autoRotateMaxRetries caps how many times an automatic phase
+	// transition is retried when another auth server writes the
+	// certificate authority concurrently.
+	autoRotateMaxRetries = 3
+	// rotationLockTTL is how long the backend lock serializing rotation
+	// writes between auth servers is held at most, the lock is released
+	// as soon as the write completes.
+	rotationLockTTL = 30 * time.Second
+	// rotationCATTLMargin is how much longer than the grace period the
+	// certificate lifetime of the new certificate authority has to be,
+	// so the rotation can not complete with a nearly expired trust set.
+	rotationCATTLMargin = time.Hour
+)
+
+// SetRotationSkewMargin sets the safety margin automatic rotation waits
+// past a schedule boundary before advancing the phase. With multiple
+// auth servers the margin absorbs clock skew between them, so a server
+// with a slightly faster clock does not advance the phase early from
+// the point of view of its peers. The default margin is zero.
+func (a *AuthServer) SetRotationSkewMargin(margin time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationSkewMargin = margin
+}
+
+// getRotationSkewMargin returns the configured clock skew safety margin
+// of automatic rotation transitions.
+func (a *AuthServer) getRotationSkewMargin() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.rotationSkewMargin
+}
+
+// SetExpectedReconnectDuration sets how long nodes of the cluster are
+// expected to need to reconnect after a credential change, e.g. derived
+// from observed reconnect latencies. A rotation started with a grace
+// period shorter than this routinely orphans nodes, so it is flagged
+// with a warning event without blocking the rotation. The default of
+// zero disables the check.
+func (a *AuthServer) SetExpectedReconnectDuration(duration time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.expectedReconnectDuration = duration
+}
+
+// getExpectedReconnectDuration returns the configured expected node
+// reconnect duration.
+func (a *AuthServer) getExpectedReconnectDuration() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.expectedReconnectDuration
+}
+
+// autoRotate advances rotation of the certificate authority to the next
+// phase per configured schedule, does nothing if rotation is not in
+// automatic mode. With multiple auth servers running rotation checks on
+// a timer, a transition by another auth server surfaces as a CompareFailed
+// conflict - the certificate authority is refetched and re-evaluated, so
+// if the other server has already advanced the phase it counts as success
+// instead of delaying the schedule until the next tick. If the conflicts
+// persist through all the retries the rotation is switched to manual
+// mode and stops advancing, so a human investigates instead of the
+// schedule fighting whatever keeps rewriting the certificate authority.
+func (a *AuthServer) autoRotate(ca services.CertAuthority) error {
+	for attempt := 0; attempt < autoRotateMaxRetries; attempt++ {
+		err := a.tryAutoRotate(ca)
+		if err == nil {
+			return nil
+		}
+		if !trace.IsCompareFailed(err) {
+			return trace.Wrap(err)
+		}
+		ca, err = a.Trust.GetCertAuthority(services.CertAuthID{
+			Type:       ca.GetType(),
+			DomainName: ca.GetClusterName(),
+		}, true)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if err := a.fallbackToManualRotation(ca.GetType()); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.CompareFailed(
+		"giving up on rotation of %q certificate authority after %v conflicting transitions, the rotation was switched to manual mode",
+		ca.GetType(), autoRotateMaxRetries)
+}
+
+// fallbackToManualRotation switches an in-progress automatic rotation to
+// manual mode after its transitions have persistently failed, so the
+// rotation stops advancing on its schedule until a human picks it up
+// with explicit phase transitions or rolls it back. The switch is
+// recorded in the audit log.
+func (a *AuthServer) fallbackToManualRotation(caType services.CertAuthType) error {
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	// the rotation may have settled or been taken over in the meantime
+	if rotation.State != services.RotationStateInProgress || rotation.Mode != services.RotationModeAuto {
+		return nil
+	}
+	rotation.Mode = services.RotationModeManual
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "fallback_manual"); err != nil {
+		return trace.Wrap(err)
+	}
+	err = a.EmitAuditEvent(events.CertAuthorityRotationEvent, events.EventFields{
+		events.RotationCertType:    string(caType),
+		events.RotationClusterName: a.clusterName.GetClusterName(),
+		events.RotationOldPhase:    rotation.Phase,
+		events.RotationNewPhase:    rotation.Phase,
+		events.RotationMode:        rotation.Mode,
+		events.RotationID:          rotation.CurrentID,
+	})
+	if err != nil {
+		log.Warningf("Failed to emit rotation audit event: %v.", err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Warningf(
+		"Automatic rotation transitions keep failing, switched the rotation to manual mode in phase %q, advance it with explicit phase transitions or roll it back.",
+		rotation.Phase)
+	return nil
+}
+
+// pendingRotationTransition reports whether the automatic rotation checks
+// have a transition to make for the given rotation state. It only consults
+// the rotation metadata, so the periodic checks can run it on a certificate
+// authority fetched without private keys and load the sensitive key material
+// only when there is actually something to do.
+func (a *AuthServer) pendingRotationTransition(caType services.CertAuthType, rotation services.Rotation) bool {
+	logger := log.WithFields(logrus.Fields{"type": caType})
+	now := a.clock.Now()
+	// a freshly initialized certificate authority carries a zero-valued
+	// rotation, there is nothing to advance
+	if rotation.State == "" {
+		return false
+	}
+	// a scheduled rotation is started once its start time is reached,
+	// this applies to both manual and automatic rotation modes
+	if rotation.State == services.RotationStateScheduled {
+		if rotation.StartAt.After(now) {
+			logger.WithFields(logrus.Fields{
+				"start_at": rotation.StartAt,
+				"now":      now,
+			}).Debugf("Scheduled rotation starts in %v.", rotation.StartAt.Sub(now))
+			return false
+		}
+		return true
+	}
+	// an operator has frozen the rotation, do not advance
+	// through the schedule until it is resumed
+	if rotation.Paused {
+		logger.WithFields(logrus.Fields{
+			"phase":     rotation.Phase,
+			"paused_at": rotation.PausedAt,
+		}).Debugf("Rotation is paused, not advancing.")
+		return false
+	}
+	// rotation mode is not automatic, nothing to do
+	if rotation.Mode != services.RotationModeAuto {
+		return false
+	}
+	// rotation is not in progress, there is nothing to do
+	if rotation.State != services.RotationStateInProgress {
+		return false
+	}
+	// a rotation started by a peer whose clock is ahead of the local one
+	// shows up with a start time in the local future, warn as the skew
+	// makes timed transitions fire early or late from the peer's point
+	// of view, see also RotationStatus.DetectedClockSkew
+	if skew := rotation.Started.Sub(now); skew > time.Second {
+		logger.Warningf("Rotation started %v ahead of the local clock, the auth server clocks are skewed.", skew)
+	}
+	// the configured safety margin delays automatic transitions past the
+	// schedule boundary, so a peer with a slightly faster clock does not
+	// advance the phase early from the point of view of the others
+	margin := a.getRotationSkewMargin()
+	switch rotation.Phase {
+	case services.RotationPhaseUpdateClients:
+		// an unconfirmed canary blocks the switch to update_servers,
+		// hold the rotation at update_clients instead of requesting a
+		// transition that would fail and abort the rotation loop for
+		// the remaining certificate authority types
+		if rotation.CanarySelector != "" && !rotation.CanaryConfirmed {
+			logger.WithFields(logrus.Fields{
+				"phase":           rotation.Phase,
+				"canary_selector": rotation.CanarySelector,
+			}).Debugf("Not advancing rotation, the canary has not been confirmed healthy.")
+			return false
+		}
+		if boundary := rotation.Schedule.UpdateServers.Add(margin); boundary.After(now) {
+			logger.WithFields(logrus.Fields{
+				"phase":    rotation.Phase,
+				"boundary": boundary,
+				"now":      now,
+			}).Debugf("Not advancing rotation, %v until the transition to %q.",
+				boundary.Sub(now), services.RotationPhaseUpdateServers)
+			return false
+		}
+	case services.RotationPhaseUpdateServers:
+		if boundary := rotation.Schedule.Standby.Add(margin); boundary.After(now) {
+			logger.WithFields(logrus.Fields{
+				"phase":    rotation.Phase,
+				"boundary": boundary,
+				"now":      now,
+			}).Debugf("Not advancing rotation, %v until the transition to %q.",
+				boundary.Sub(now), services.RotationPhaseStandby)
+			return false
+		}
+	}
+	// an unsupported phase is left for tryAutoRotate to report
+	return true
+}
+
+// tryAutoRotate attempts a single pass of the automatic rotation checks,
+// returning CompareFailed if the certificate authority was changed
+// concurrently, see autoRotate for the retry logic around it.
+func (a *AuthServer) tryAutoRotate(ca services.CertAuthority) error {
+	rotation := ca.GetRotation()
+	logger := log.WithFields(logrus.Fields{"type": ca.GetType()})
+	if !a.pendingRotationTransition(ca.GetType(), rotation) {
+		return nil
+	}
+	// an operator transition in flight holds the advisory rotation lock,
+	// let it finish and pick up the result on the next tick
+	if err := a.acquireRotationLock(ca.GetType(), rotationInitiatorAuto); err != nil {
+		if trace.IsAlreadyExists(err) {
+			logger.Debugf("Skipping automatic rotation check: %v.", err)
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	defer a.releaseRotationLock(ca.GetType())
+	if rotation.State == services.RotationStateScheduled {
+		return a.startScheduledRotation(ca)
+	}
+	var req *rotationReq
+	switch rotation.Phase {
+	case services.RotationPhaseUpdateClients:
+		req = &rotationReq{
+			clock:       a.clock,
+			ca:          ca,
+			targetPhase: services.RotationPhaseUpdateServers,
+			mode:        rotation.Mode,
+			gracePeriod: rotation.GracePeriod.Duration,
+			schedule:    rotation.Schedule,
+		}
+	case services.RotationPhaseUpdateServers:
+		req = &rotationReq{
+			clock:       a.clock,
+			ca:          ca,
+			targetPhase: services.RotationPhaseStandby,
+			mode:        rotation.Mode,
+			gracePeriod: rotation.GracePeriod.Duration,
+			schedule:    rotation.Schedule,
+		}
+	default:
+		return trace.BadParameter("phase is not supported: %q", rotation.Phase)
+	}
+	logger.Infof("Setting rotation phase to %q.", req.targetPhase)
+	rotated, err := processRotationRequest(*req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.compareAndSwapRotatedCertAuthority(rotated, rotation, req.targetPhase); err != nil {
+		return trace.Wrap(err)
+	}
+	a.finishRotationTransition(ca.GetType(), rotation, rotated.GetRotation(), rotationInitiatorAuto, "", "")
+	logger.Infof("Completed rotation phase %q.", req.targetPhase)
+	return nil
+}
+
+// compareAndSwapRotatedCertAuthority persists the rotated certificate
+// authority only if the stored rotation still matches the snapshot the
+// transition was computed from, returning CompareFailed otherwise. The
+// backend does not expose a compare-and-swap primitive, so the check and
+// the write are serialized with a backend lock shared by all auth servers.
+func (a *AuthServer) compareAndSwapRotatedCertAuthority(rotated services.CertAuthority, prev services.Rotation, transition string) error {
+	lock := fmt.Sprintf("rotation-%v", rotated.GetType())
+	if err := a.bk.AcquireLock(lock, rotationLockTTL); err != nil {
+		return trace.Wrap(err)
+	}
+	defer a.bk.ReleaseLock(lock)
+	stored, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       rotated.GetType(),
+		DomainName: rotated.GetClusterName(),
+	}, false)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	storedRotation := stored.GetRotation()
+	if storedRotation.State != prev.State || storedRotation.Phase != prev.Phase || storedRotation.CurrentID != prev.CurrentID {
+		return trace.CompareFailed(
+			"rotation of %q certificate authority was changed concurrently to state %q, phase %q",
+			rotated.GetType(), storedRotation.State, storedRotation.Phase)
+	}
+	return trace.Wrap(a.upsertRotatedCertAuthority(rotated, transition))
+}
+
+// PauseRotation freezes an in-progress automatic rotation without rolling
+// it back, e.g. during an incident, the rotation keeps both certificate
+// authorities in place but stops advancing through its schedule until
+// it is resumed with ResumeRotation.
+func (a *AuthServer) PauseRotation(caType services.CertAuthType) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.State != services.RotationStateInProgress || rotation.Mode != services.RotationModeAuto {
+		return trace.BadParameter(
+			"only an in-progress automatic rotation can be paused, rotation of %q certificate authority is %q in %q mode",
+			caType, rotation.State, rotation.Mode)
+	}
+	if rotation.Paused {
+		return trace.BadParameter("rotation of %q certificate authority is already paused", caType)
+	}
+	rotation.Paused = true
+	rotation.PausedAt = a.clock.Now().UTC()
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "pause"); err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof("Paused rotation in phase %q.", rotation.Phase)
+	return nil
+}
+
+// ResumeRotation resumes a previously paused rotation and re-anchors the
+// remaining schedule relative to the current time, so the remaining phases
+// still get their full intervals. Resuming a rotation that is not paused
+// returns an error.
+func (a *AuthServer) ResumeRotation(caType services.CertAuthType) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if !rotation.Paused {
+		return trace.BadParameter("rotation of %q certificate authority is not paused", caType)
+	}
+	// shift the schedule forward by the time spent paused, phases that
+	// were still ahead when the rotation was paused keep their full
+	// remaining intervals
+	delta := a.clock.Now().UTC().Sub(rotation.PausedAt)
+	if delta > 0 {
+		rotation.Schedule.UpdateClients = rotation.Schedule.UpdateClients.Add(delta)
+		rotation.Schedule.UpdateServers = rotation.Schedule.UpdateServers.Add(delta)
+		rotation.Schedule.Standby = rotation.Schedule.Standby.Add(delta)
+	}
+	rotation.Paused = false
+	rotation.PausedAt = time.Time{}
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "resume"); err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof("Resumed rotation in phase %q.", rotation.Phase)
+	return nil
+}
+
+// CancelRotation cancels a rotation that is still in the update_clients
+// phase, discarding the newly generated certificate authority and
+// restoring the exact pre-rotation trust set. At this point only clients
+// have been instructed to re-issue their credentials and no server has
+// switched to the new certificate authority yet, so dropping it is safe.
+// Once the rotation has advanced to update_servers the new certificate
+// authority is in active use and the rotation has to go through the
+// rollback phase instead, where both trust sets stay in place until the
+// re-issued credentials have been returned.
+func (a *AuthServer) CancelRotation(caType services.CertAuthType) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.State != services.RotationStateInProgress || rotation.Phase != services.RotationPhaseUpdateClients {
+		return trace.BadParameter(
+			"only a rotation in phase %q can be canceled, rotation of %q certificate authority is %q in phase %q, use rollback instead",
+			services.RotationPhaseUpdateClients, caType, rotation.State, rotation.Phase)
+	}
+	// canceling drops the key at position 0 and keeps the rest, so the
+	// ordering convention has to hold before anything is touched
+	if err := checkRotationKeyOrder(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	prev := rotation
+
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	// a scoped rotation only doubled up the key material it regenerated,
+	// see startNewRotation, the new certificate authority is the first
+	// entry on the sides that rotated
+	cancelSSH := len(signingKeys) >= 2 && len(checkingKeys) >= 2
+	cancelTLS := len(keyPairs) >= 2
+	if cancelSSH {
+		if err := ca.SetSigningKeys(signingKeys[1:]); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := ca.SetCheckingKeys(checkingKeys[1:]); err != nil {
+			return trace.Wrap(err)
+		}
+		zeroKeyMaterial(signingKeys[0])
+	}
+	if cancelTLS {
+		ca.SetTLSKeyPairs(keyPairs[1:])
+		zeroKeyMaterial(keyPairs[0].Key)
+	}
+
+	rotation.State = services.RotationStateStandby
+	rotation.Phase = services.RotationPhaseStandby
+	rotation.Started = time.Time{}
+	rotation.Mode = ""
+	rotation.Schedule = services.RotationSchedule{}
+	rotation.Paused = false
+	rotation.PausedAt = time.Time{}
+	rotation.CanarySelector = ""
+	rotation.CanaryConfirmed = false
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "cancel"); err != nil {
+		return trace.Wrap(err)
+	}
+	a.finishRotationTransition(caType, prev, rotation, "", "", "")
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Canceled rotation %q, restored the pre-rotation certificate authority.", prev.CurrentID)
+	return nil
+}
+
+// RestartRotation abandons an in-progress rotation and immediately starts
+// a fresh one with newly generated keys in a single write, e.g. when the
+// first generated key was flagged and the rotation has to start over.
+// It is equivalent to rolling back, completing the rollback and starting
+// a new rotation, but the intermediate states are never visible to the
+// cluster and the whole restart is protected by the same
+// compare-and-swap as any other rotation write. The new rotation gets a
+// brand-new rotation ID and both the abandonment and the restart are
+// recorded in the rotation history and the audit log.
+func (a *AuthServer) RestartRotation(caType services.CertAuthType, req RotateRequest) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if req.Type != "" && req.Type != caType {
+		return trace.BadParameter(
+			"the restart request targets the %q certificate authority, not %q", req.Type, caType)
+	}
+	req.Type = caType
+	if err := req.CheckAndSetDefaults(a.clock); err != nil {
+		return trace.Wrap(err)
+	}
+	// the restart replaces the abandoned rotation with a fresh start,
+	// phase transitions or scheduled starts make no sense here
+	if req.TargetPhase != services.RotationPhaseUpdateClients || req.StartAt != nil {
+		return trace.BadParameter(
+			"a rotation can only be restarted into a fresh rotation with target phase %q",
+			services.RotationPhaseUpdateClients)
+	}
+	if err := a.acquireRotationLock(caType, req.Initiator); err != nil {
+		return trace.Wrap(err)
+	}
+	defer a.releaseRotationLock(caType)
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	prev := ca.GetRotation()
+	if prev.State != services.RotationStateInProgress {
+		return trace.BadParameter(
+			"only an in-progress rotation can be restarted, rotation of the %q certificate authority is %q in phase %q",
+			caType, prev.State, prev.Phase)
+	}
+	if prev.Phase == services.RotationPhaseRollback {
+		return trace.BadParameter(
+			"rotation of the %q certificate authority is already rolling back, complete the rollback and start a new rotation instead",
+			caType)
+	}
+	// abandon the rotation: the rollback restores the pre-rotation
+	// certificate authority and discards the flagged keys
+	if err := startRollingBackRotation(ca, req.Initiator); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := completeRollingBackRotation(a.clock, ca); err != nil {
+		return trace.Wrap(err)
+	}
+	abandoned := ca.GetRotation()
+	// start over with freshly generated keys, the ID check in
+	// startNewRotation guarantees the new rotation can not reuse the ID
+	// of the abandoned one
+	gracePeriod, _ := req.EffectiveGracePeriod()
+	internalReq := rotationReq{
+		ca:                  ca,
+		clock:               a.clock,
+		targetPhase:         services.RotationPhaseUpdateClients,
+		schedule:            *req.Schedule,
+		gracePeriod:         gracePeriod,
+		mode:                req.Mode,
+		keyStore:            a.getRotationKeyStore(),
+		onKeyGenerated:      a.getKeyEscrowHook(),
+		commonNameTemplate:  req.CommonNameTemplate,
+		subject:             req.Subject,
+		keyAlgorithm:        req.KeyAlgorithm,
+		keyComment:          req.KeyComment,
+		scope:               req.Scope,
+		initiator:           req.Initiator,
+		reason:              req.Reason,
+		canarySelector:      req.CanarySelector,
+		rotationID:          req.RotationID,
+		retainGenerations:   req.RetainGenerations,
+		pinnedCerts:         req.PinnedCerts,
+		keepOldTrustOnForce: req.KeepOldTrustOnForce,
+		importedSSH:         req.ImportedSSHKeyPair,
+		importedTLS:         req.ImportedTLSKeyPair,
+	}
+	if req.CATTL != nil {
+		internalReq.caTTL = *req.CATTL
+	}
+	if err := startNewRotation(internalReq, ca); err != nil {
+		return trace.Wrap(err)
+	}
+	recordPhaseTime(a.clock, ca)
+	if err := a.upsertRotatedCertAuthority(ca, "restart"); err != nil {
+		return trace.Wrap(err)
+	}
+	// both steps of the restart leave their own trail: the abandonment
+	// of the old rotation and the start of the new one
+	a.finishRotationTransition(caType, prev, abandoned, req.Initiator, req.Reason, "")
+	a.finishRotationTransition(caType, abandoned, ca.GetRotation(), req.Initiator, req.Reason, "")
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Restarted rotation, abandoned %q and started %q in phase %q.",
+		prev.CurrentID, ca.GetRotation().CurrentID, ca.GetRotation().Phase)
+	return nil
+}
+
+// UnpinCert removes a previously pinned certificate from the certificate
+// authority, see RotateRequest.PinnedCerts. The certificate-only entry
+// kept around for the pin is dropped from the trusted set, entries that
+// are still part of the regular trust set - the active certificate, the
+// rotation overlap and the retained generations - only lose the pin and
+// stay trusted until the usual trimming drops them.
+func (a *AuthServer) UnpinCert(caType services.CertAuthType, fingerprint string) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	pins := make([]string, 0, len(rotation.PinnedCerts))
+	found := false
+	for _, pin := range rotation.PinnedCerts {
+		if pin == fingerprint {
+			found = true
+			continue
+		}
+		pins = append(pins, pin)
+	}
+	if !found {
+		return trace.NotFound(
+			"certificate %v is not pinned on the %q certificate authority", fingerprint, caType)
+	}
+	if len(pins) == 0 {
+		pins = nil
+	}
+	rotation.PinnedCerts = pins
+	// entries up to this position are explained by the rotation phase and
+	// the retained generations, anything beyond is only kept by a pin
+	retain := rotation.RetainGenerations
+	if retain < 1 {
+		retain = 1
+	}
+	regular := expectedTLSKeyPairCount(rotation.Phase) + retain - 1
+	keyPairs := ca.GetTLSKeyPairs()
+	kept := make([]services.TLSKeyPair, 0, len(keyPairs))
+	for i, keyPair := range keyPairs {
+		pairFingerprint, err := tlsCertFingerprint(keyPair.Cert)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if pairFingerprint == fingerprint && i >= regular && len(keyPair.Key) == 0 {
+			continue
+		}
+		kept = append(kept, keyPair)
+	}
+	ca.SetTLSKeyPairs(kept)
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "unpin"); err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Removed pinned certificate %v from the trusted set.", fingerprint)
+	return nil
+}
+
+// ExtendRotationGracePeriod extends the grace period of an in-progress
+// automatic rotation by the additional duration, pushing the schedule
+// boundaries the rotation has not reached yet out by the same amount,
+// e.g. when a fleet turns out to need more time before update_servers
+// than the original grace period allowed. The grace period can only
+// grow - shrinking it below the already elapsed time would trigger an
+// immediate transition.
+func (a *AuthServer) ExtendRotationGracePeriod(caType services.CertAuthType, additional time.Duration) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if additional <= 0 {
+		return trace.BadParameter("the grace period can only be extended, got %v", additional)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.State != services.RotationStateInProgress || rotation.Mode != services.RotationModeAuto {
+		return trace.BadParameter(
+			"only the grace period of an in-progress automatic rotation can be extended, rotation of %q certificate authority is %q in %q mode",
+			caType, rotation.State, rotation.Mode)
+	}
+	// boundaries the rotation has already passed stay in the past,
+	// only the upcoming transitions move out
+	switch rotation.Phase {
+	case services.RotationPhaseUpdateClients:
+		rotation.Schedule.UpdateServers = rotation.Schedule.UpdateServers.Add(additional)
+	}
+	rotation.Schedule.Standby = rotation.Schedule.Standby.Add(additional)
+	rotation.GracePeriod = services.NewDuration(rotation.GracePeriod.Duration + additional)
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, "extend_grace"); err != nil {
+		return trace.Wrap(err)
+	}
+	err = a.EmitAuditEvent(events.CertAuthorityRotationEvent, events.EventFields{
+		events.RotationCertType:    string(caType),
+		events.RotationClusterName: a.clusterName.GetClusterName(),
+		events.RotationOldPhase:    rotation.Phase,
+		events.RotationNewPhase:    rotation.Phase,
+		events.RotationMode:        rotation.Mode,
+		events.RotationID:          rotation.CurrentID,
+	})
+	if err != nil {
+		log.Warningf("Failed to emit rotation audit event: %v.", err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Extended rotation grace period by %v to %v.", additional, rotation.GracePeriod.Duration)
+	return nil
+}
+
+// ConfirmRotationCanary records that the canary nodes of the ongoing
+// canary rotation are healthy on the new credentials, unblocking the
+// transition to the update_servers phase, see
+// RotateRequest.CanarySelector.
+func (a *AuthServer) ConfirmRotationCanary(caType services.CertAuthType) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.State != services.RotationStateInProgress || rotation.Phase != services.RotationPhaseUpdateClients {
+		return trace.BadParameter(
+			"rotation of %q certificate authority is not in phase %q, there is no canary to confirm",
+			caType, services.RotationPhaseUpdateClients)
+	}
+	if rotation.CanarySelector == "" {
+		return trace.BadParameter(
+			"rotation of %q certificate authority is not running a canary", caType)
+	}
+	// confirming an already confirmed canary is a no-op
+	if rotation.CanaryConfirmed {
+		return nil
+	}
+	rotation.CanaryConfirmed = true
+	ca.SetRotation(rotation)
+	if err := a.upsertRotatedCertAuthority(ca, rotation.Phase); err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Infof(
+		"Confirmed canary for nodes matching %q, the rotation can switch to phase %q.",
+		rotation.CanarySelector, services.RotationPhaseUpdateServers)
+	return nil
+}
+
+// ForceCompleteRotation transitions a stuck in-progress rotation straight
+// to standby via completeRotation, ignoring the schedule, e.g. when a
+// schedule timestamp was mistakenly set far in the future and automatic
+// mode will not advance. Completing from the update_clients phase is
+// refused unless acceptDataLoss is set, since servers may not have loaded
+// the new certificate authority yet and already re-issued client
+// credentials would be rejected.
+func (a *AuthServer) ForceCompleteRotation(caType services.CertAuthType, acceptDataLoss bool) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.State != services.RotationStateInProgress {
+		return trace.BadParameter(
+			"rotation of %q certificate authority is not in progress, nothing to force complete", caType)
+	}
+	switch rotation.Phase {
+	case services.RotationPhaseUpdateServers:
+	case services.RotationPhaseUpdateClients:
+		if !acceptDataLoss {
+			return trace.BadParameter(
+				"rotation of %q certificate authority is still in phase %q, servers may not have loaded the new certificate authority and clients that already re-issued credentials would be locked out, pass acceptDataLoss to force completion anyway",
+				caType, rotation.Phase)
+		}
+	case services.RotationPhaseRollback:
+		return trace.BadParameter(
+			"rotation of %q certificate authority is rolling back, finish the rollback with a standby rotation request instead", caType)
+	default:
+		return trace.BadParameter("unsupported rotation phase: %q", rotation.Phase)
+	}
+	if err := completeRotation(a.clock, ca); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.upsertRotatedCertAuthority(ca, services.RotationPhaseStandby); err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{"type": caType}).Warningf(
+		"Force completed rotation from phase %q, the schedule was overridden.", rotation.Phase)
+	a.finishRotationTransition(caType, rotation, ca.GetRotation(), "", "force completed, schedule overridden", "")
+	return nil
+}
+
+// startScheduledRotation starts the rotation that has been previously
+// scheduled once its start time has been reached, generating the schedule
+// off the grace period recorded when the rotation was scheduled.
+func (a *AuthServer) startScheduledRotation(ca services.CertAuthority) error {
+	rotation := ca.GetRotation()
+	logger := log.WithFields(logrus.Fields{"type": ca.GetType()})
+	// respect the policy time windows and cooldown, the scheduled
+	// rotation is deferred until it is allowed to start
+	policy, err := a.GetRotationPolicy(ca.GetType())
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	if policy != nil {
+		if err := policy.checkCanStart(a.clock.Now(), rotation); err != nil {
+			logger.Debugf("Deferring scheduled rotation: %v.", err)
+			return nil
+		}
+	}
+	logger.Infof("Starting rotation scheduled for %v.", rotation.StartAt)
+	gracePeriod := rotation.GracePeriod.Duration
+	schedule := services.RotationSchedule{}
+	if gracePeriod != 0 {
+		generated, err := services.GenerateSchedule(a.clock, gracePeriod)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		schedule = *generated
+	}
+	stockpiled, err := a.peekStockpiledKey(ca.GetType())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	rotated, err := processRotationRequest(rotationReq{
+		clock:          a.clock,
+		ca:             ca,
+		targetPhase:    services.RotationPhaseUpdateClients,
+		mode:           rotation.Mode,
+		gracePeriod:    gracePeriod,
+		schedule:       schedule,
+		keyStore:       a.getRotationKeyStore(),
+		onKeyGenerated: a.getKeyEscrowHook(),
+		stockpiled:     stockpiled,
+		// carry over who scheduled the rotation and why
+		initiator: rotation.InitiatedBy,
+		reason:    rotation.Reason,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// a peer auth server may have observed the same scheduled rotation
+	// and started it first with its own freshly generated keys - writing
+	// over them would break the trust of clients that already picked
+	// them up, so the write is guarded like every other automatic
+	// transition and the conflict is retried by autoRotate
+	if err := a.compareAndSwapRotatedCertAuthority(rotated, rotation, services.RotationPhaseUpdateClients); err != nil {
+		return trace.Wrap(err)
+	}
+	a.resetRotationAcks(ca.GetType())
+	if stockpiled != nil {
+		a.consumeStockpiledKey(ca.GetType(), stockpiled.ID)
+	}
+	a.finishRotationTransition(ca.GetType(), rotation, rotated.GetRotation(), rotationInitiatorAuto, "", "")
+	logger.Infof("Started scheduled rotation, set current phase to: %q.", rotated.GetRotation().Phase)
+	return nil
+}
+
+// processRotationRequest switches the rotation state machine of the
+// certificate authority to the next phase and returns the updated
+// certificate authority.
+func processRotationRequest(req rotationReq) (services.CertAuthority, error) {
+	rotation := req.ca.GetRotation()
+	ca := req.ca
+
+	// a certificate authority that has never rotated carries a
+	// zero-valued rotation, normalize it to standby up front so every
+	// branch below sees a consistent starting state
+	if rotation.State == "" {
+		rotation.State = services.RotationStateStandby
+	}
+	if rotation.Phase == "" {
+		rotation.Phase = services.RotationPhaseStandby
+	}
+
+	// automation retries requests, so re-requesting the phase the
+	// rotation is already in is treated as success without mutating
+	// the certificate authority, callers detect the unchanged rotation
+	// and skip the write
+	if req.startAt.IsZero() && req.targetPhase == rotation.Phase {
+		switch req.targetPhase {
+		case services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers, services.RotationPhaseRollback:
+			if rotation.State == services.RotationStateInProgress && req.mode == rotation.Mode {
+				return ca, nil
+			}
+		case services.RotationPhaseStandby:
+			// completing a rotation wipes the mode, so a standby retry
+			// matches on phase alone
+			if rotation.State == services.RotationStateStandby {
+				return ca, nil
+			}
+		}
+	}
+
+	switch req.targetPhase {
+	case services.RotationPhaseUpdateClients:
+		// stepping back from update_servers gives lagging clients more
+		// time on their old credentials without the weight of a full
+		// rollback: only the phase changes, the signing order and the
+		// trusted key set stay exactly as they are, the new certificate
+		// authority keeps signing and both remain trusted, so the step
+		// back is safe to repeat and to advance from again
+		if rotation.State == services.RotationStateInProgress && rotation.Phase == services.RotationPhaseUpdateServers {
+			rotation.Phase = services.RotationPhaseUpdateClients
+			rotation.Mode = req.mode
+			ca.SetRotation(rotation)
+			recordPhaseTime(req.clock, ca)
+			return ca, nil
+		}
+		// rotation can be started or scheduled only in standby state,
+		// starting a rotation while one is already scheduled replaces
+		// the pending schedule
+		switch rotation.State {
+		case services.RotationStateStandby, services.RotationStateScheduled:
+		default:
+			return nil, trace.Wrap(newRotationInProgressError(
+				"can not start rotation, rotation is in %q state, phase %q", rotation.State, rotation.Phase))
+		}
+		if !req.startAt.IsZero() {
+			scheduleRotation(req, ca)
+			return ca, nil
+		}
+		if err := startNewRotation(req, ca); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		recordPhaseTime(req.clock, ca)
+		return ca, nil
+	case services.RotationPhaseUpdateServers:
+		// this phase can only be set after update_clients
+		if rotation.Phase != services.RotationPhaseUpdateClients {
+			return nil, trace.Wrap(newIllegalPhaseTransitionError(
+				"can only switch to phase %v from %v, current phase is %v",
+				services.RotationPhaseUpdateServers,
+				services.RotationPhaseUpdateClients,
+				rotation.Phase))
+		}
+		// a canary rotation switches servers only after the canary
+		// nodes have been confirmed healthy on the new credentials
+		if rotation.CanarySelector != "" && !rotation.CanaryConfirmed {
+			return nil, trace.BadParameter(
+				"rotation is running a canary for nodes matching %q that has not been confirmed healthy, confirm the canary before switching to phase %q",
+				rotation.CanarySelector, services.RotationPhaseUpdateServers)
+		}
+		rotation.Phase = services.RotationPhaseUpdateServers
+		rotation.Mode = req.mode
+		ca.SetRotation(rotation)
+		recordPhaseTime(req.clock, ca)
+		return ca, nil
+	case services.RotationPhaseRollback:
+		// rollback is only possible when rotation is in progress
+		switch rotation.Phase {
+		case services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers:
+			if err := startRollingBackRotation(ca, req.initiator); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			recordPhaseTime(req.clock, ca)
+			return ca, nil
+		case services.RotationPhaseStandby:
+			// a user error deserving a clear message rather than the
+			// generic transition error: there is nothing to roll back,
+			// an empty phase has been normalized to standby above
+			return nil, trace.Wrap(newIllegalPhaseTransitionError(
+				"can not roll back: no rotation is in progress on the certificate authority"))
+		default:
+			return nil, trace.Wrap(newIllegalPhaseTransitionError("can not transition to phase %q from %q phase", req.targetPhase, rotation.Phase))
+		}
+	case services.RotationPhaseStandby:
+		// standby phase requested while the rotation is still pending
+		// cancels the scheduled rotation before it starts
+		if rotation.State == services.RotationStateScheduled {
+			cancelScheduledRotation(ca)
+			return ca, nil
+		}
+		// this phase completes the rotation or the rollback
+		switch rotation.Phase {
+		case services.RotationPhaseRollback:
+			if err := completeRollingBackRotation(req.clock, ca); err != nil {
+				return nil, trace.Wrap(err)
+			}
+		case services.RotationPhaseUpdateServers:
+			if err := completeRotation(req.clock, ca); err != nil {
+				return nil, trace.Wrap(err)
+			}
+		default:
+			return nil, trace.Wrap(newIllegalPhaseTransitionError("can not transition to phase %q from %q phase", req.targetPhase, rotation.Phase))
+		}
+		recordPhaseTime(req.clock, ca)
+		return ca, nil
+	default:
+		return nil, trace.Wrap(newUnsupportedPhaseError("unsupported phase: %q", req.targetPhase))
+	}
+}
+
+// recordPhaseTime stamps the time the certificate authority entered its
+// current rotation phase, see services.Rotation.PhaseTimes. The retry
+// short-circuit in processRotationRequest returns before any of the
+// callers run, so retried requests do not overwrite the original time.
+func recordPhaseTime(clock clockwork.Clock, ca services.CertAuthority) {
+	rotation := ca.GetRotation()
+	rotation.RecordPhaseTime(clock.Now())
+	ca.SetRotation(rotation)
+}
+
+// scheduleRotation records a pending rotation on the certificate authority
+// without generating any keys, the rotation is started by the periodic
+// rotation checks once its start time is reached.
+func scheduleRotation(req rotationReq, ca services.CertAuthority) {
+	rotation := ca.GetRotation()
+	rotation.State = services.RotationStateScheduled
+	rotation.Phase = services.RotationPhaseStandby
+	rotation.Mode = req.mode
+	rotation.GracePeriod = services.NewDuration(req.gracePeriod)
+	rotation.StartAt = req.startAt
+	rotation.InitiatedBy = req.initiator
+	rotation.Reason = req.reason
+	rotation.RollbackInitiatedBy = ""
+	rotation.CurrentID = ""
+	rotation.Started = time.Time{}
+	rotation.Schedule = services.RotationSchedule{}
+	ca.SetRotation(rotation)
+}
+
+// cancelScheduledRotation cancels a pending scheduled rotation and returns
+// the certificate authority back to standby, no keys have been generated
+// yet at this point so nothing else has to be cleaned up.
+func cancelScheduledRotation(ca services.CertAuthority) {
+	rotation := ca.GetRotation()
+	rotation.State = services.RotationStateStandby
+	rotation.Phase = services.RotationPhaseStandby
+	rotation.Mode = ""
+	rotation.GracePeriod = services.Duration{}
+	rotation.StartAt = time.Time{}
+	rotation.InitiatedBy = ""
+	rotation.Reason = ""
+	ca.SetRotation(rotation)
+}
+
+// startNewRotation starts new rotation and in case of successful generation
+// sets the new certificate authority keys as used for signing, while keeping
+// the old keys in the trusted list. In case if the grace period is set to
+// zero, old certificate authority is removed right away and the rotation
+// completes in one step.
+func startNewRotation(req rotationReq, ca services.CertAuthority) error {
+	clock := req.clock
+	gracePeriod := req.gracePeriod
+
+	rotation := ca.GetRotation()
+	// the new trust set is built around the existing keys, a certificate
+	// authority with no keys at all indicates corrupted state and would
+	// panic further down, refuse it before any new keys are generated
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	if len(signingKeys) == 0 || len(checkingKeys) == 0 || len(keyPairs) == 0 {
+		return trace.BadParameter("certificate authority %v has no existing keys to rotate from", ca.GetID())
+	}
+	// use the external correlation ID when the caller supplied one,
+	// see RotateRequest.RotationID
+	id := req.rotationID
+	if id == "" {
+		id = uuid.New()
+	}
+	// the certificate authority retains the ID of its last rotation, so
+	// reusing it for the next one would make correlation and audit
+	// ambiguous, for generated UUIDs this is effectively always satisfied
+	// and the check only realistically matters for external IDs
+	if id == rotation.CurrentID {
+		return trace.BadParameter(
+			"rotation ID %q was already used by the previous rotation of the certificate authority, use a fresh ID", id)
+	}
+
+	// pinned certificates have to refer to entries of the current trust
+	// set, a fingerprint with no matching certificate is a typo or an
+	// already dropped certificate, see RotateRequest.PinnedCerts
+	for _, fingerprint := range req.pinnedCerts {
+		found := false
+		for _, keyPair := range keyPairs {
+			pairFingerprint, err := tlsCertFingerprint(keyPair.Cert)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			if pairFingerprint == fingerprint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return trace.BadParameter(
+				"pinned certificate %v does not match any certificate trusted by the %v certificate authority",
+				fingerprint, ca.GetID())
+		}
+		// pins accumulate across rotations until removed with UnpinCert
+		if !utils.SliceContainsStr(rotation.PinnedCerts, fingerprint) {
+			rotation.PinnedCerts = append(rotation.PinnedCerts, fingerprint)
+		}
+	}
+
+	rotation.Mode = req.mode
+	rotation.Schedule = req.schedule
+	rotation.StartAt = time.Time{}
+	rotation.InitiatedBy = req.initiator
+	rotation.Reason = req.reason
+	rotation.RollbackInitiatedBy = ""
+	rotation.CanarySelector = req.canarySelector
+	rotation.CanaryConfirmed = false
+	rotation.RetainGenerations = req.retainGenerations
+	// phase times of the previous rotation are of no use to the new one
+	rotation.PhaseTimes = nil
+
+	// an empty scope rotates all key material, internal callers such
+	// as scheduled rotation do not set it
+	rotateSSH := req.scope == "" || req.scope == RotationScopeAll || req.scope == RotationScopeSSH
+	rotateTLS := req.scope == "" || req.scope == RotationScopeAll || req.scope == RotationScopeTLS
+
+	var sshPrivPEM, sshPubPEM, tlsKeyPEM, tlsCertPEM []byte
+	if req.stockpiled != nil {
+		// use a pre-generated key set, so the rotation does not depend
+		// on the availability of the key generation subsystem, the key
+		// escrow hook has already been invoked at stockpile time
+		sshPrivPEM, sshPubPEM = req.stockpiled.SSHPrivPEM, req.stockpiled.SSHPubPEM
+		tlsKeyPEM, tlsCertPEM = req.stockpiled.TLSKeyPEM, req.stockpiled.TLSCertPEM
+	} else {
+		// generate a new SSH key pair and a new TLS certificate authority
+		// with the requested key algorithm, skipping the key material
+		// the rotation scope leaves untouched and preferring externally
+		// generated key material when the caller imported some
+		var err error
+		if rotateSSH && req.importedSSH != nil {
+			sshPrivPEM, sshPubPEM = req.importedSSH.PrivateKey, req.importedSSH.PublicKey
+		} else if rotateSSH {
+			sshPrivPEM, sshPubPEM, err = req.keyStore.GenerateSSHKeyPair(req.keyAlgorithm)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		if rotateTLS && req.importedTLS != nil {
+			tlsKeyPEM, tlsCertPEM = req.importedTLS.Key, req.importedTLS.Cert
+		} else if rotateTLS {
+			commonName := ca.GetClusterName()
+			if req.commonNameTemplate != "" {
+				commonName, err = renderCommonName(req.commonNameTemplate, ca, id)
+				if err != nil {
+					return trace.Wrap(err)
+				}
+			}
+			caTTL := req.caTTL
+			if caTTL == 0 {
+				caTTL = defaults.CATTL
+			}
+			entity := pkix.Name{
+				CommonName:   commonName,
+				Organization: []string{ca.GetClusterName()},
+			}
+			// the cluster name always stays the first organization
+			// entry, the customization only adds to the subject
+			if req.subject != nil {
+				entity.Organization = append(entity.Organization, req.subject.Organization...)
+				entity.OrganizationalUnit = req.subject.OrganizationalUnit
+			}
+			tlsKeyPEM, tlsCertPEM, err = req.keyStore.GenerateTLSCA(req.keyAlgorithm, entity, caTTL)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		// hand the new private key material to the escrow hook if registered,
+		// abort the rotation before any of the new keys are persisted
+		// in case if the hook fails, keys skipped by the scope are nil
+		if req.onKeyGenerated != nil {
+			if err := req.onKeyGenerated(ca.GetType(), sshPrivPEM, tlsKeyPEM); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+	}
+	// tag the public key of the new SSH key pair with a comment naming
+	// the cluster and the rotation it came from, so the key can be traced
+	// in logs and known_hosts files, imported keys are installed verbatim
+	if rotateSSH && req.importedSSH == nil {
+		comment := req.keyComment
+		if comment == "" {
+			comment = fmt.Sprintf("%v-rotation-%v", ca.GetClusterName(), id)
+		}
+		sshPubPEM = sshPublicKeyWithComment(sshPubPEM, comment)
+	}
+	tlsKeyPair := services.TLSKeyPair{Cert: tlsCertPEM, Key: tlsKeyPEM}
+
+	if gracePeriod == 0 {
+		// in case of force rotation, drop old certificate authority
+		// without keeping it as trusted, unless the caller asked to keep
+		// the old trust: then the old checking keys and certificates,
+		// never the private keys, stay trusted like retained generations,
+		// so verification does not break instantly while the new key is
+		// the only signer right away
+		retain := rotation.RetainGenerations
+		if retain < 1 {
+			retain = 1
+		}
+		if rotateSSH {
+			signingKeys = [][]byte{sshPrivPEM}
+			if req.keepOldTrustOnForce {
+				old := checkingKeys
+				if len(old) > retain {
+					old = old[:retain]
+				}
+				checkingKeys = append([][]byte{sshPubPEM}, old...)
+			} else {
+				checkingKeys = [][]byte{sshPubPEM}
+			}
+		}
+		if rotateTLS {
+			var trusted []services.TLSKeyPair
+			dropped := keyPairs
+			if req.keepOldTrustOnForce {
+				oldPairs := keyPairs
+				if len(oldPairs) > retain {
+					oldPairs = oldPairs[:retain]
+				}
+				trusted = []services.TLSKeyPair{tlsKeyPair}
+				for _, keyPair := range oldPairs {
+					trusted = append(trusted, services.TLSKeyPair{Cert: keyPair.Cert})
+				}
+				dropped = keyPairs[len(oldPairs):]
+			} else {
+				trusted = []services.TLSKeyPair{tlsKeyPair}
+			}
+			// even a forced rotation keeps the pinned certificates
+			// trusted, see RotateRequest.PinnedCerts
+			trusted, err := appendPinnedCerts(rotation, trusted, dropped)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			keyPairs = trusted
+		}
+		if req.keepOldTrustOnForce && rotation.RetainGenerations < retain+1 {
+			// the lingering old trust counts as an extra retained
+			// generation, so the consistency checks and the trimming of
+			// the next rotation account for it
+			rotation.RetainGenerations = retain + 1
+		}
+
+		// in case of forced rotation, rotation has been started and completed
+		// in the same step moving it to standby state
+		rotation.State = services.RotationStateStandby
+		rotation.Phase = services.RotationPhaseStandby
+		rotation.LastRotated = clock.Now().UTC()
+		rotation.CurrentID = id
+		rotation.Started = time.Time{}
+		rotation.GracePeriod = services.NewDuration(gracePeriod)
+		rotation.Mode = ""
+		rotation.Schedule = services.RotationSchedule{}
+	} else {
+		// rotation sets up new keys to be used for signing, and keeps
+		// up to the configured number of old generations around as
+		// trusted, so certificates issued by the old certificate
+		// authorities are still valid until the rotation completes,
+		// key material outside of the rotation scope is kept as is,
+		// only the immediately preceding signing key is kept so the
+		// rotation can be rolled back
+		retain := rotation.RetainGenerations
+		if retain < 1 {
+			retain = 1
+		}
+		if rotateSSH {
+			signingKeys = [][]byte{sshPrivPEM, signingKeys[0]}
+			old := checkingKeys
+			if len(old) > retain {
+				old = old[:retain]
+			}
+			checkingKeys = append([][]byte{sshPubPEM}, old...)
+		}
+		if rotateTLS {
+			oldPairs := keyPairs
+			if len(oldPairs) > retain {
+				oldPairs = oldPairs[:retain]
+			}
+			trusted := append([]services.TLSKeyPair{tlsKeyPair}, oldPairs...)
+			// pinned certificates beyond the retained generations stay
+			// trusted, see RotateRequest.PinnedCerts
+			trusted, err := appendPinnedCerts(rotation, trusted, keyPairs[len(oldPairs):])
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			keyPairs = trusted
+		}
+
+		rotation.State = services.RotationStateInProgress
+		rotation.Phase = services.RotationPhaseUpdateClients
+		rotation.CurrentID = id
+		rotation.Started = clock.Now().UTC()
+		rotation.GracePeriod = services.NewDuration(gracePeriod)
+	}
+
+	if err := ca.SetSigningKeys(signingKeys); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := ca.SetCheckingKeys(checkingKeys); err != nil {
+		return trace.Wrap(err)
+	}
+	ca.SetTLSKeyPairs(keyPairs)
+	ca.SetRotation(rotation)
+	// guard against the newly generated key material matching the retained
+	// old keys, e.g. due to a bad import or a key generation bug, rolling
+	// back to an identical key would be meaningless
+	if err := CheckTrustSetUnique(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// renderCommonName renders the common name template of the new certificate
+// authority, see RotateRequest.CommonNameTemplate for supported variables.
+func renderCommonName(commonNameTemplate string, ca services.CertAuthority, rotationID string) (string, error) {
+	parsed, err := template.New("common_name").Parse(commonNameTemplate)
+	if err != nil {
+		return "", trace.BadParameter("failed to parse common name template: %v", err)
+	}
+	var out bytes.Buffer
+	err = parsed.Execute(&out, map[string]string{
+		"cluster":    ca.GetClusterName(),
+		"catype":     string(ca.GetType()),
+		"generation": rotationID,
+	})
+	if err != nil {
+		return "", trace.BadParameter("failed to render common name template: %v", err)
+	}
+	commonName := strings.TrimSpace(out.String())
+	if commonName == "" {
+		return "", trace.BadParameter(
+			"common name template %q rendered to an empty name", commonNameTemplate)
+	}
+	return commonName, nil
+}
+
+// appendPinnedCerts appends a certificate-only entry for every candidate
+// key pair whose certificate is pinned on the rotation, so pinned
+// certificates survive the trimming of the trusted set,
+// see RotateRequest.PinnedCerts.
+func appendPinnedCerts(rotation services.Rotation, kept, candidates []services.TLSKeyPair) ([]services.TLSKeyPair, error) {
+	if len(rotation.PinnedCerts) == 0 {
+		return kept, nil
+	}
+	pinned := make(map[string]bool, len(rotation.PinnedCerts))
+	for _, fingerprint := range rotation.PinnedCerts {
+		pinned[fingerprint] = true
+	}
+	out := append([]services.TLSKeyPair{}, kept...)
+	for _, keyPair := range candidates {
+		fingerprint, err := tlsCertFingerprint(keyPair.Cert)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if pinned[fingerprint] {
+			out = append(out, services.TLSKeyPair{Cert: keyPair.Cert})
+		}
+	}
+	return out, nil
+}
+
+// startRollingBackRotation starts roll back to the old certificate authority,
+// the old certificate authority is used for signing again, the new
+// certificate authority is still trusted so certificates issued during the
+// aborted rotation remain valid until the rollback completes.
+func startRollingBackRotation(ca services.CertAuthority, initiator string) error {
+	// the rollback keeps the key at position 1 and drops the key at
+	// position 0, so make sure the ordering convention still holds before
+	// touching anything
+	if err := checkRotationKeyOrder(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	// a scoped rotation only doubles up the key material it regenerated,
+	// see startNewRotation, so only the sides with old keys are rolled back
+	rollbackSSH := len(signingKeys) >= 2 && len(checkingKeys) >= 2
+	rollbackTLS := len(keyPairs) >= 2
+	if !rollbackSSH && !rollbackTLS {
+		return trace.BadParameter("certificate authority %v has no old keys to roll back to", ca.GetID())
+	}
+
+	// the old certificate authority is the second one in the list,
+	// see startNewRotation, and is set up to sign again, while
+	// the new certificate authority remains trusted until the rollback
+	// completes
+	if rollbackSSH {
+		if err := ca.SetSigningKeys([][]byte{signingKeys[1]}); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := ca.SetCheckingKeys([][]byte{checkingKeys[1], checkingKeys[0]}); err != nil {
+			return trace.Wrap(err)
+		}
+		// the new signing key is dropped right away, only its public
+		// counterpart stays trusted until the rollback completes
+		zeroKeyMaterial(signingKeys[0])
+	}
+	if rollbackTLS {
+		ca.SetTLSKeyPairs([]services.TLSKeyPair{keyPairs[1], keyPairs[0]})
+	}
+
+	rotation.State = services.RotationStateInProgress
+	rotation.Phase = services.RotationPhaseRollback
+	rotation.RollbackInitiatedBy = initiator
+	ca.SetRotation(rotation)
+	return nil
+}
+
+// completeRollingBackRotation completes rollback of the rotation and sets
+// the state to standby, the keys generated during the aborted rotation
+// are discarded.
+func completeRollingBackRotation(clock clockwork.Clock, ca services.CertAuthority) error {
+	rotation := ca.GetRotation()
+
+	// only the old certificate authority remains trusted after the rollback,
+	// see startRollingBackRotation for the order of the keys
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	if err := ca.SetCheckingKeys(checkingKeys[:1]); err != nil {
+		return trace.Wrap(err)
+	}
+	ca.SetTLSKeyPairs(keyPairs[:1])
+	// wipe the private key of the discarded TLS key pair, the dropped
+	// SSH signing key has already been wiped by startRollingBackRotation
+	for _, keyPair := range keyPairs[1:] {
+		zeroKeyMaterial(keyPair.Key)
+	}
+
+	rotation.State = services.RotationStateStandby
+	rotation.Phase = services.RotationPhaseStandby
+	rotation.Started = time.Time{}
+	rotation.Mode = ""
+	rotation.Schedule = services.RotationSchedule{}
+	rotation.Paused = false
+	rotation.PausedAt = time.Time{}
+	rotation.CanarySelector = ""
+	rotation.CanaryConfirmed = false
+	ca.SetRotation(rotation)
+	return nil
+}
+
+// completeRotation completes the rotation - removes the old certificate
+// authority from the trusted list and sets the rotation state to standby.
+func completeRotation(clock clockwork.Clock, ca services.CertAuthority) error {
+	// completion drops everything but the key at position 0, so make sure
+	// the ordering convention still holds before touching anything
+	if err := checkRotationKeyOrder(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	// the trusted set is trimmed to the configured number of generations,
+	// one by default, while the signing keys always collapse to the new
+	// certificate authority - retained old generations keep only their
+	// public material
+	retain := rotation.RetainGenerations
+	if retain < 1 {
+		retain = 1
+	}
+	if err := ca.SetSigningKeys(signingKeys[:1]); err != nil {
+		return trace.Wrap(err)
+	}
+	keepChecking := retain
+	if keepChecking > len(checkingKeys) {
+		keepChecking = len(checkingKeys)
+	}
+	if err := ca.SetCheckingKeys(checkingKeys[:keepChecking]); err != nil {
+		return trace.Wrap(err)
+	}
+	keepPairs := retain
+	if keepPairs > len(keyPairs) {
+		keepPairs = len(keyPairs)
+	}
+	// wipe the private key material of the old certificate authorities,
+	// both the dropped ones and the retained older generations that stay
+	// trusted with their certificates only
+	zeroKeyMaterial(signingKeys[1:]...)
+	for i := range keyPairs {
+		if i == 0 {
+			continue
+		}
+		zeroKeyMaterial(keyPairs[i].Key)
+		keyPairs[i].Key = nil
+	}
+	// certificates pinned for external systems stay trusted past the
+	// completion, certificate only, until removed with UnpinCert
+	keepTrusted, err := appendPinnedCerts(rotation, keyPairs[:keepPairs], keyPairs[keepPairs:])
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ca.SetTLSKeyPairs(keepTrusted)
+
+	rotation.State = services.RotationStateStandby
+	rotation.Phase = services.RotationPhaseStandby
+	rotation.LastRotated = clock.Now().UTC()
+	rotation.Started = time.Time{}
+	rotation.Mode = ""
+	rotation.Schedule = services.RotationSchedule{}
+	rotation.Paused = false
+	rotation.PausedAt = time.Time{}
+	rotation.CanarySelector = ""
+	rotation.CanaryConfirmed = false
+	ca.SetRotation(rotation)
+	return nil
+}
@@ -0,0 +1,5483 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/lib/auth/native"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/boltbk"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/teleport/lib/fixtures"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/services/suite"
+	"github.com/gravitational/teleport/lib/tlsca"
+	"github.com/gravitational/teleport/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type RotationSuite struct {
+	bk    backend.Backend
+	a     *AuthServer
+	clock clockwork.FakeClock
+}
+
+var _ = Suite(&RotationSuite{})
+
+func (s *RotationSuite) SetUpSuite(c *C) {
+	utils.InitLoggerForTests()
+}
+
+func (s *RotationSuite) SetUpTest(c *C) {
+	var err error
+	s.bk, err = boltbk.New(backend.Params{"path": c.MkDir()})
+	c.Assert(err, IsNil)
+
+	s.clock = clockwork.NewFakeClock()
+
+	clusterName, err := services.NewClusterName(services.ClusterNameSpecV2{
+		ClusterName: "me.localhost",
+	})
+	c.Assert(err, IsNil)
+	// rotations reject duplicate key material, so use the real key
+	// generator instead of the fixed pool of test keys
+	authConfig := &InitConfig{
+		ClusterName: clusterName,
+		Backend:     s.bk,
+		Authority:   native.New(),
+	}
+	s.a, err = NewAuthServer(authConfig)
+	c.Assert(err, IsNil)
+	s.a.SetClock(s.clock)
+
+	err = s.a.SetClusterName(clusterName)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.a.UpsertCertAuthority(
+		suite.NewTestCA(services.HostCA, "me.localhost")), IsNil)
+	c.Assert(s.a.UpsertCertAuthority(
+		suite.NewTestCA(services.UserCA, "me.localhost")), IsNil)
+}
+
+func (s *RotationSuite) TearDownTest(c *C) {
+	s.a.Authority.Close()
+	c.Assert(s.a.Close(), IsNil)
+}
+
+func (s *RotationSuite) ca(c *C, caType services.CertAuthType) services.CertAuthority {
+	ca, err := s.a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: "me.localhost",
+	}, true)
+	c.Assert(err, IsNil)
+	return ca
+}
+
+// runAutoRotation drives an automatic rotation of the given certificate
+// authority to completion by advancing the fake clock past every schedule
+// boundary and triggering autoRotateCertAuthorities, returning the
+// sequence of phases observed after each transition. It saves tests from
+// hand-rolling the advance/trigger loop for every scenario.
+func (s *RotationSuite) runAutoRotation(c *C, caType services.CertAuthType) []string {
+	var phases []string
+	// a full rotation has at most two timed transitions left at any
+	// point, leave some headroom for schedules that pause in between
+	for i := 0; i < 10; i++ {
+		rotation := s.ca(c, caType).GetRotation()
+		if len(phases) == 0 || phases[len(phases)-1] != rotation.Phase {
+			phases = append(phases, rotation.Phase)
+		}
+		if rotation.State != services.RotationStateInProgress {
+			return phases
+		}
+		var next time.Time
+		switch rotation.Phase {
+		case services.RotationPhaseUpdateClients:
+			next = rotation.Schedule.UpdateServers
+		case services.RotationPhaseUpdateServers:
+			next = rotation.Schedule.Standby
+		default:
+			c.Fatalf("unexpected phase %q in automatic rotation", rotation.Phase)
+		}
+		if diff := next.Sub(s.clock.Now()); diff > 0 {
+			s.clock.Advance(diff + time.Second)
+		}
+		c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	}
+	c.Fatalf("automatic rotation of %q did not complete", caType)
+	return nil
+}
+
+// TestManualRotation goes through the manual rotation
+// of the host certificate authority
+func (s *RotationSuite) TestManualRotation(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotation.CurrentID, Not(Equals), "")
+	// both old and new keys are trusted
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 2)
+
+	// can not complete rotation before switching servers
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	rotation = ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseStandby)
+	c.Assert(rotation.LastRotated.IsZero(), Equals, false)
+	// old certificate authority has been removed from the trusted list
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+}
+
+// TestRollback tests rollback of the rotation
+// back to the old certificate authority
+func (s *RotationSuite) TestRollback(c *C) {
+	gracePeriod := time.Hour
+	oldCA := s.ca(c, services.HostCA)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseRollback)
+	// the old key signs again, the new one remains trusted
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Equals, string(oldCA.GetSigningKeys()[0]))
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	rotation = ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	// keys generated during the aborted rotation are discarded and the
+	// surviving key material is byte-identical to the originals
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Equals, string(oldCA.GetSigningKeys()[0]))
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+	c.Assert(string(ca.GetCheckingKeys()[0]), Equals, string(oldCA.GetCheckingKeys()[0]))
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+	c.Assert(string(ca.GetTLSKeyPairs()[0].Cert), Equals, string(oldCA.GetTLSKeyPairs()[0].Cert))
+	c.Assert(string(ca.GetTLSKeyPairs()[0].Key), Equals, string(oldCA.GetTLSKeyPairs()[0].Key))
+
+	// a rollback from the update_servers phase restores the originals
+	// all the same
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseRollback,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Equals, string(oldCA.GetSigningKeys()[0]))
+	c.Assert(string(ca.GetCheckingKeys()[0]), Equals, string(oldCA.GetCheckingKeys()[0]))
+	c.Assert(string(ca.GetTLSKeyPairs()[0].Cert), Equals, string(oldCA.GetTLSKeyPairs()[0].Cert))
+	c.Assert(string(ca.GetTLSKeyPairs()[0].Key), Equals, string(oldCA.GetTLSKeyPairs()[0].Key))
+}
+
+// TestForceRotation tests rotation with grace period set to zero,
+// it completes in one step
+func (s *RotationSuite) TestForceRotation(c *C) {
+	gracePeriod := time.Duration(0)
+	oldCA := s.ca(c, services.HostCA)
+
+	// zero grace period has to be explicitly acknowledged
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:             services.HostCA,
+		GracePeriod:      &gracePeriod,
+		TargetPhase:      services.RotationPhaseUpdateClients,
+		Mode:             services.RotationModeManual,
+		AcknowledgeForce: true,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseStandby)
+	// the old certificate authority is dropped right away
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Not(Equals), string(oldCA.GetSigningKeys()[0]))
+}
+
+// TestAutoRotation tests automatic rotation driven by the schedule
+func (s *RotationSuite) TestAutoRotation(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// too early to advance
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// advance past the update_servers mark
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	// advance past the standby mark, rotation completes
+	s.clock.Advance(gracePeriod)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+}
+
+// TestRotationStateAt tests reconstruction of the rotation state
+// at a given point in time from the recorded transitions
+func (s *RotationSuite) TestRotationStateAt(c *C) {
+	// no rotation happened yet, there is no history to replay
+	_, err := s.a.RotationStateAt(services.HostCA, s.clock.Now())
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+
+	gracePeriod := time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	started := s.clock.Now()
+
+	s.clock.Advance(30 * time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// the history does not cover the time before the rotation started
+	_, err = s.a.RotationStateAt(services.HostCA, started.Add(-time.Minute))
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+
+	// between the two transitions the rotation was updating clients
+	status, err := s.a.RotationStateAt(services.HostCA, started.Add(10*time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(status.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(status.State, Equals, services.RotationStateInProgress)
+
+	// at the current time the rotation is updating servers
+	status, err = s.a.RotationStateAt(services.HostCA, s.clock.Now())
+	c.Assert(err, IsNil)
+	c.Assert(status.Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestRotatePermissions tests that transitions of the rotation state
+// machine are guarded by per-phase permissions
+func (s *RotationSuite) TestRotatePermissions(c *C) {
+	// junior operators can start rotations and roll them back,
+	// but can not complete the rotation
+	junior, err := services.FromSpec("junior", services.RoleSpecV3{
+		Allow: services.RoleConditions{
+			Namespaces: []string{defaults.Namespace},
+			Rules: []services.Rule{
+				services.NewRule(services.KindCertAuthority, []string{
+					services.RotateVerb(services.RotationPhaseUpdateClients),
+					services.RotateVerb(services.RotationPhaseRollback),
+				}),
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	user, err := services.NewUser("junior")
+	c.Assert(err, IsNil)
+	authz := &AuthWithRoles{authServer: s.a, checker: junior, user: user}
+
+	gracePeriod := time.Hour
+	err = authz.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	err = authz.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+
+	err = authz.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// completing the rollback requires the standby permission as well
+	err = authz.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+}
+
+// TestKeyEscrowHook tests that the key generation hook receives
+// new private key material and can abort the rotation
+func (s *RotationSuite) TestKeyEscrowHook(c *C) {
+	gracePeriod := time.Hour
+	var escrowedType services.CertAuthType
+	var escrowedSSHKey, escrowedTLSKey []byte
+	s.a.OnKeyGenerated(func(caType services.CertAuthType, sshPrivPEM, tlsKeyPEM []byte) error {
+		escrowedType = caType
+		escrowedSSHKey = sshPrivPEM
+		escrowedTLSKey = tlsKeyPEM
+		return nil
+	})
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	c.Assert(escrowedType, Equals, services.HostCA)
+	c.Assert(string(escrowedSSHKey), Equals, string(ca.GetSigningKeys()[0]))
+	c.Assert(string(escrowedTLSKey), Equals, string(ca.GetTLSKeyPairs()[0].Key))
+}
+
+// TestKeyEscrowHookAborts tests that a failed escrow hook
+// aborts the rotation before any changes are persisted
+func (s *RotationSuite) TestKeyEscrowHookAborts(c *C) {
+	gracePeriod := time.Hour
+	oldCA := s.ca(c, services.HostCA)
+	s.a.OnKeyGenerated(func(caType services.CertAuthType, sshPrivPEM, tlsKeyPEM []byte) error {
+		return trace.ConnectionProblem(nil, "escrow service is not available")
+	})
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, NotNil)
+
+	// rotation has not started
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Not(Equals), services.RotationStateInProgress)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Equals, string(oldCA.GetSigningKeys()[0]))
+}
+
+// TestRotationStatusHealth tests that the rotation status reports
+// the TLS key pair count and flags key sets that do not match
+// the expected count for the current phase
+func (s *RotationSuite) TestRotationStatusHealth(c *C) {
+	gracePeriod := time.Hour
+
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	status := statuses[0]
+	c.Assert(status.TLSKeyPairCount, Equals, 1)
+	c.Assert(status.KeySetHealthy, Equals, true)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	statuses, err = s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	status = statuses[0]
+	c.Assert(status.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(status.TLSKeyPairCount, Equals, 2)
+	c.Assert(status.KeySetHealthy, Equals, true)
+
+	// an extra key pair left on a standby authority is unhealthy
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	rotation.State = services.RotationStateStandby
+	rotation.Phase = services.RotationPhaseStandby
+	ca.SetRotation(rotation)
+	c.Assert(s.a.UpsertCertAuthority(ca), IsNil)
+
+	statuses, err = s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	status = statuses[0]
+	c.Assert(status.TLSKeyPairCount, Equals, 2)
+	c.Assert(status.KeySetHealthy, Equals, false)
+}
+
+// TestScheduledRotation tests that a rotation can be scheduled to start
+// at a future time, cancelled while pending, and is started by periodic
+// rotation checks once the start time is reached
+func (s *RotationSuite) TestScheduledRotation(c *C) {
+	gracePeriod := time.Hour
+	startAt := s.clock.Now().UTC().Add(time.Hour)
+
+	// scheduling a rotation in the past is not allowed
+	past := s.clock.Now().UTC().Add(-time.Minute)
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeManual,
+		StartAt:     &past,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// parameters that are not preserved until the scheduled rotation
+	// starts are rejected instead of being silently dropped
+	caTTL := 10 * 24 * time.Hour
+	forced := time.Duration(0)
+	for _, req := range []RotateRequest{
+		{Scope: RotationScopeTLS},
+		{KeyAlgorithm: RotationKeyAlgorithmECDSAP256},
+		{CommonNameTemplate: "ca.{{.cluster}}"},
+		{Subject: &RotationSubject{OrganizationalUnit: []string{"ops"}}},
+		{CATTL: &caTTL},
+		{KeyComment: "CHANGE-1234"},
+		{RetainGenerations: 2},
+		{CanarySelector: "group=canary"},
+		{GracePeriod: &forced, AcknowledgeForce: true, KeepOldTrustOnForce: true},
+	} {
+		req.Type = services.HostCA
+		if req.GracePeriod == nil {
+			req.GracePeriod = &gracePeriod
+		}
+		req.Mode = services.RotationModeManual
+		req.StartAt = &startAt
+		err = s.a.RotateCertAuthority(req)
+		fixtures.ExpectBadParameter(c, err)
+		c.Assert(err, ErrorMatches, ".*can not be combined with a scheduled start time.*", Commentf("%+v", req))
+	}
+	// an explicitly default scope and key algorithm stay allowed
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:         services.HostCA,
+		GracePeriod:  &gracePeriod,
+		Mode:         services.RotationModeManual,
+		StartAt:      &startAt,
+		Scope:        RotationScopeAll,
+		KeyAlgorithm: RotationKeyAlgorithmRSA,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeManual,
+		StartAt:     &startAt,
+	})
+	c.Assert(err, IsNil)
+
+	// the rotation is pending, no keys have been generated yet
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateScheduled)
+	c.Assert(ca.GetRotation().StartAt, Equals, startAt)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+
+	// a pending rotation can be cancelled by requesting standby phase
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+
+	// schedule again and let the periodic checks pick it up
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeManual,
+		StartAt:     &startAt,
+	})
+	c.Assert(err, IsNil)
+
+	// too early to start
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateScheduled)
+
+	s.clock.Advance(time.Hour + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateInProgress)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+}
+
+// TestDuplicateTrustSet tests that a certificate authority with
+// duplicate entries in the trust set is rejected
+func (s *RotationSuite) TestDuplicateTrustSet(c *C) {
+	ca := s.ca(c, services.HostCA)
+	c.Assert(CheckTrustSetUnique(ca), IsNil)
+
+	// duplicate the checking key as it could happen on a bad import
+	checkingKeys := ca.GetCheckingKeys()
+	c.Assert(ca.SetCheckingKeys([][]byte{checkingKeys[0], checkingKeys[0]}), IsNil)
+	fixtures.ExpectBadParameter(c, CheckTrustSetUnique(ca))
+}
+
+// TestRotationPolicy tests that a stored rotation policy provides
+// the baseline for rotation requests and enforces the cooldown
+func (s *RotationSuite) TestRotationPolicy(c *C) {
+	// policies are stored and retrieved per certificate authority type
+	_, err := s.a.GetRotationPolicy(services.HostCA)
+	fixtures.ExpectNotFound(c, err)
+
+	err = s.a.SetRotationPolicy(RotationPolicy{
+		Type:        services.HostCA,
+		GracePeriod: services.NewDuration(time.Hour),
+		Mode:        services.RotationModeAuto,
+		Cooldown:    services.NewDuration(24 * time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	policy, err := s.a.GetRotationPolicy(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(policy.Mode, Equals, services.RotationModeAuto)
+	c.Assert(policy.GracePeriod.Duration, Equals, time.Hour)
+
+	// a request without grace period and mode picks up the policy baseline
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type: services.HostCA,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Mode, Equals, services.RotationModeAuto)
+	c.Assert(rotation.GracePeriod.Duration, Equals, time.Hour)
+
+	// drive the automatic rotation to completion
+	s.clock.Advance(time.Hour + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+
+	// cooldown prevents starting a new rotation right away
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type: services.HostCA,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	s.clock.Advance(25 * time.Hour)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type: services.HostCA,
+	})
+	c.Assert(err, IsNil)
+}
+
+// testNotifier records rotation lifecycle events, returns
+// an error on every call if failing is set
+type testNotifier struct {
+	events  []RotationEvent
+	failing bool
+}
+
+func (n *testNotifier) Notify(event RotationEvent) error {
+	n.events = append(n.events, event)
+	if n.failing {
+		return trace.ConnectionProblem(nil, "sink is not available")
+	}
+	return nil
+}
+
+// TestRotationNotifiers tests that all registered notifiers receive
+// rotation lifecycle events and a failing notifier does not block
+// the others or the rotation itself
+func (s *RotationSuite) TestRotationNotifiers(c *C) {
+	gracePeriod := time.Hour
+	failing := &testNotifier{failing: true}
+	healthy := &testNotifier{}
+	s.a.RegisterRotationNotifier(failing)
+	s.a.RegisterRotationNotifier(healthy)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Initiator:   "admin",
+	})
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+		Initiator:   "admin",
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(healthy.events, HasLen, 2)
+	c.Assert(failing.events, HasLen, 2)
+	c.Assert(healthy.events[0].Type, Equals, services.HostCA)
+	c.Assert(healthy.events[0].Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(healthy.events[0].Initiator, Equals, "admin")
+	c.Assert(healthy.events[1].Phase, Equals, services.RotationPhaseUpdateServers)
+	c.Assert(healthy.events[0].CurrentID, Not(Equals), "")
+}
+
+// TestRotationStatusOutput tests the stable JSON serialization
+// of the rotation status
+func (s *RotationSuite) TestRotationStatusOutput(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Initiator:   "admin",
+		Reason:      "ops-1234",
+	})
+	c.Assert(err, IsNil)
+
+	output, err := s.a.GetRotationStatusOutput(services.HostCA)
+	c.Assert(err, IsNil)
+	data, err := json.Marshal(output)
+	c.Assert(err, IsNil)
+
+	var parsed map[string]interface{}
+	c.Assert(json.Unmarshal(data, &parsed), IsNil)
+	c.Assert(parsed["version"], Equals, "v1")
+	c.Assert(parsed["type"], Equals, string(services.HostCA))
+	c.Assert(parsed["state"], Equals, services.RotationStateInProgress)
+	c.Assert(parsed["phase"], Equals, services.RotationPhaseUpdateClients)
+	c.Assert(parsed["mode"], Equals, services.RotationModeManual)
+	c.Assert(parsed["grace_period"], Equals, "1h0m0s")
+	c.Assert(parsed["key_pair_count"], Equals, float64(2))
+	c.Assert(parsed["initiator"], Equals, "admin")
+	c.Assert(parsed["reason"], Equals, "ops-1234")
+	c.Assert(parsed["key_fingerprints"], HasLen, 4)
+}
+
+// TestRotationDuringMigration tests that rotation is refused
+// while a backend migration is reported to be in progress
+func (s *RotationSuite) TestRotationDuringMigration(c *C) {
+	gracePeriod := time.Hour
+	migrating := true
+	s.a.SetMigrationCheck(func() bool { return migrating })
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// periodic checks pause as well and touch nothing
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Not(Equals), services.RotationStateInProgress)
+
+	// once the migration completes, rotation proceeds as usual
+	migrating = false
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestTrustBundleForPhase tests that the trust bundle for provisioning
+// new nodes matches the current rotation phase
+func (s *RotationSuite) TestTrustBundleForPhase(c *C) {
+	gracePeriod := time.Hour
+
+	certs, signing, err := s.a.TrustBundleForPhase(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(certs, HasLen, 1)
+	c.Assert(signing, Not(Equals), "")
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// during rotation both certificates have to be trusted and the new
+	// certificate authority is the one actively signing
+	certs, signing, err = s.a.TrustBundleForPhase(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(certs, HasLen, 2)
+	newFingerprint, err := tlsCertFingerprint(s.ca(c, services.HostCA).GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+	c.Assert(signing, Equals, newFingerprint)
+}
+
+// TestRotationAckStatus tests tracking of per-node acknowledgements
+// of the current rotation
+func (s *RotationSuite) TestRotationAckStatus(c *C) {
+	gracePeriod := time.Hour
+	for _, name := range []string{"node-1", "node-2"} {
+		server := &services.ServerV2{
+			Kind:    services.KindNode,
+			Version: services.V2,
+			Metadata: services.Metadata{
+				Name:      name,
+				Namespace: defaults.Namespace,
+			},
+			Spec: services.ServerSpecV2{
+				Addr:     "127.0.0.1:3022",
+				Hostname: name,
+			},
+		}
+		c.Assert(s.a.Presence.UpsertNode(server), IsNil)
+	}
+
+	// no rotation has happened yet, there is nothing to acknowledge
+	_, _, _, err := s.a.RotationAckStatus(services.HostCA)
+	fixtures.ExpectNotFound(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	rotationID := s.ca(c, services.HostCA).GetRotation().CurrentID
+
+	acked, total, pending, err := s.a.RotationAckStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(acked, Equals, 0)
+	c.Assert(total, Equals, 2)
+	c.Assert(pending, DeepEquals, []string{"node-1", "node-2"})
+
+	c.Assert(s.a.AcknowledgeRotation(services.HostCA, rotationID, "node-1"), IsNil)
+	acked, total, pending, err = s.a.RotationAckStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(acked, Equals, 1)
+	c.Assert(total, Equals, 2)
+	c.Assert(pending, DeepEquals, []string{"node-2"})
+
+	// acks of the previous rotation do not count once a new one starts
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	acked, _, pending, err = s.a.RotationAckStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(acked, Equals, 0)
+	c.Assert(pending, HasLen, 2)
+}
+
+// TestCommonNameTemplate tests that the common name of the newly
+// generated certificate authority can be templated
+func (s *RotationSuite) TestCommonNameTemplate(c *C) {
+	gracePeriod := time.Hour
+
+	// template that renders to an empty name is rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		Mode:               services.RotationModeManual,
+		CommonNameTemplate: "  ",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		Mode:               services.RotationModeManual,
+		CommonNameTemplate: "ca.{{.cluster}}.{{.catype}}",
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	cert, err := tlsca.ParseCertificatePEM(ca.GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+	c.Assert(cert.Subject.CommonName, Equals, "ca.me.localhost.host")
+}
+
+// TestNormalizeRotationFields tests repair of incoherent rotation fields
+// left behind by older versions
+func (s *RotationSuite) TestNormalizeRotationFields(c *C) {
+	// a coherent standby rotation needs no repair
+	changed, err := s.a.NormalizeRotationFields(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(changed, Equals, false)
+
+	// standby state with leftover mode and schedule is repaired
+	ca := s.ca(c, services.HostCA)
+	ca.SetRotation(services.Rotation{
+		State: services.RotationStateStandby,
+		Mode:  services.RotationModeAuto,
+		Schedule: services.RotationSchedule{
+			Standby: s.clock.Now().Add(time.Hour),
+		},
+	})
+	c.Assert(s.a.UpsertCertAuthority(ca), IsNil)
+
+	changed, err = s.a.NormalizeRotationFields(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(changed, Equals, true)
+
+	ca = s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Mode, Equals, "")
+	c.Assert(rotation.Schedule, Equals, services.RotationSchedule{})
+
+	// an actively transitioning rotation is refused
+	gracePeriod := time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	_, err = s.a.NormalizeRotationFields(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestStockpiledRotation tests that rotation consumes pre-generated
+// stockpiled keys when available
+func (s *RotationSuite) TestStockpiledRotation(c *C) {
+	gracePeriod := time.Hour
+
+	c.Assert(s.a.StockpileCAKeys(services.HostCA, 2), IsNil)
+	depth, err := s.a.StockpileDepth(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(depth, Equals, 2)
+
+	stockpiled, err := s.a.peekStockpiledKey(services.HostCA)
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// the rotation used the stockpiled key and consumed it
+	ca := s.ca(c, services.HostCA)
+	c.Assert(string(ca.GetSigningKeys()[0]), Equals, string(stockpiled.SSHPrivPEM))
+	c.Assert(string(ca.GetTLSKeyPairs()[0].Key), Equals, string(stockpiled.TLSKeyPEM))
+	depth, err = s.a.StockpileDepth(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(depth, Equals, 1)
+
+	// phase transitions do not touch the stockpile
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	depth, err = s.a.StockpileDepth(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(depth, Equals, 1)
+}
+
+// TestSimulateRollback tests the rollback dry run that validates
+// the escape hatch without modifying anything
+func (s *RotationSuite) TestSimulateRollback(c *C) {
+	gracePeriod := time.Hour
+
+	// nothing to roll back on standby
+	_, err := s.a.SimulateRollback(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	before := s.ca(c, services.HostCA)
+
+	status, err := s.a.SimulateRollback(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(status.State, Equals, services.RotationStateStandby)
+	c.Assert(status.Phase, Equals, services.RotationPhaseStandby)
+	c.Assert(status.TLSKeyPairCount, Equals, 1)
+	c.Assert(status.KeySetHealthy, Equals, true)
+
+	// the dry run did not modify the certificate authority
+	after := s.ca(c, services.HostCA)
+	c.Assert(after.GetRotation(), DeepEquals, before.GetRotation())
+	c.Assert(after.GetSigningKeys(), HasLen, 2)
+}
+
+// TestShortGracePeriod tests that sub-minute grace periods produce
+// a valid, strictly increasing schedule and go through all phases
+func (s *RotationSuite) TestShortGracePeriod(c *C) {
+	gracePeriod := 30 * time.Second
+
+	schedule, err := services.GenerateSchedule(s.clock, gracePeriod)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers.After(s.clock.Now()), Equals, true)
+	c.Assert(schedule.UpdateServers.Before(schedule.Standby), Equals, true)
+
+	// grace periods too short for three distinct phases are rejected
+	_, err = services.GenerateSchedule(s.clock, 2*time.Nanosecond)
+	fixtures.ExpectBadParameter(c, err)
+
+	// a rapid graceful rotation still goes through all phases
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	s.clock.Advance(11 * time.Second)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	s.clock.Advance(20 * time.Second)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+}
+
+// TestRotationRevision tests the optimistic concurrency revision token
+// that detects concurrent rotation transitions
+func (s *RotationSuite) TestRotationRevision(c *C) {
+	gracePeriod := time.Hour
+
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	revision := statuses[0].Revision
+
+	// a revision check without an explicit type is ambiguous
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Revision:    &revision,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// rotating with the current revision succeeds
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Revision:    &revision,
+	})
+	c.Assert(err, IsNil)
+
+	// the transition has advanced the revision
+	statuses, err = s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses[0].Revision > revision, Equals, true)
+
+	// the stale revision is rejected with a conflict
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+		Revision:    &revision,
+	})
+	fixtures.ExpectCompareFailed(c, err)
+
+	// the fresh revision is accepted
+	revision = statuses[0].Revision
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+		Revision:    &revision,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestVerifyRotatedCA tests the post-rotation self test that confirms
+// the rotated certificate authority can issue valid certificates
+func (s *RotationSuite) TestVerifyRotatedCA(c *C) {
+	gracePeriod := time.Hour
+
+	// the initial key set passes the self test
+	err := s.a.VerifyRotatedCA(services.HostCA)
+	c.Assert(err, IsNil)
+
+	// rotate both certificate authorities all the way through
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+
+	// both rotated key sets pass the self test
+	err = s.a.VerifyRotatedCA(services.HostCA)
+	c.Assert(err, IsNil)
+	err = s.a.VerifyRotatedCA(services.UserCA)
+	c.Assert(err, IsNil)
+
+	// the database CA only issues TLS credentials and passes the
+	// TLS-only self test
+	c.Assert(s.a.UpsertCertAuthority(
+		suite.NewTestCA(services.DatabaseCA, "me.localhost")), IsNil)
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.DatabaseCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	err = s.a.VerifyRotatedCA(services.DatabaseCA)
+	c.Assert(err, IsNil)
+
+	// a certificate authority whose checking key has diverged from
+	// the signing key fails the self test
+	ca := s.ca(c, services.HostCA)
+	_, pubKey, err := s.a.Authority.GenerateKeyPair("")
+	c.Assert(err, IsNil)
+	c.Assert(ca.SetCheckingKeys([][]byte{pubKey}), IsNil)
+	c.Assert(s.a.Trust.UpsertCertAuthority(ca), IsNil)
+	err = s.a.VerifyRotatedCA(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestRollbackHistory tests that completing a rollback archives the
+// fingerprint of the attempted key and the rollback reason in history
+func (s *RotationSuite) TestRollbackHistory(c *C) {
+	gracePeriod := time.Hour
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// the attempted new key is the first one, see startNewRotation
+	ca := s.ca(c, services.HostCA)
+	attemptedFingerprint, err := tlsCertFingerprint(ca.GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+
+	// advance the clock so every transition gets a distinct history record
+	s.clock.Advance(time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+		Reason:      "new key failed validation",
+	})
+	c.Assert(err, IsNil)
+
+	s.clock.Advance(time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+		Reason:      "rollback confirmed",
+	})
+	c.Assert(err, IsNil)
+
+	// the attempted key is gone from the certificate authority but its
+	// fingerprint and the rollback reason are preserved in history
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+	transitions, err := s.a.getRotationTransitions(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(len(transitions) >= 3, Equals, true)
+	last := transitions[len(transitions)-1]
+	c.Assert(last.Phase, Equals, services.RotationPhaseStandby)
+	c.Assert(last.Reason, Equals, "rollback confirmed")
+	c.Assert(last.AttemptedFingerprint, Equals, attemptedFingerprint)
+}
+
+// TestRotationStatus tests the read-only rotation status view including
+// the schedule and the time remaining until the next automatic transition
+func (s *RotationSuite) TestRotationStatus(c *C) {
+	gracePeriod := time.Hour
+
+	// an unknown type is rejected
+	_, err := s.a.GetRotationStatus("unknown")
+	fixtures.ExpectBadParameter(c, err)
+
+	// an empty type returns statuses for both certificate authorities
+	statuses, err := s.a.GetRotationStatus("")
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 2)
+	c.Assert(statuses[0].Type, Equals, services.HostCA)
+	c.Assert(statuses[1].Type, Equals, services.UserCA)
+	for _, status := range statuses {
+		c.Assert(status.NextTransitionIn, Equals, time.Duration(0))
+	}
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	statuses, err = s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	status := statuses[0]
+	c.Assert(status.State, Equals, services.RotationStateInProgress)
+	c.Assert(status.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(status.Mode, Equals, services.RotationModeAuto)
+	c.Assert(status.Started, Equals, s.clock.Now().UTC())
+	c.Assert(status.GracePeriod, Equals, services.NewDuration(gracePeriod))
+	c.Assert(status.Schedule.UpdateServers.After(s.clock.Now()), Equals, true)
+	c.Assert(status.Schedule.Standby.After(status.Schedule.UpdateServers), Equals, true)
+	// the next automatic transition is the switch to update_servers
+	c.Assert(status.NextTransitionIn, Equals, status.Schedule.UpdateServers.Sub(s.clock.Now()))
+}
+
+// TestKeyAlgorithm tests that rotation can generate the new certificate
+// authority with a non-default key algorithm
+func (s *RotationSuite) TestKeyAlgorithm(c *C) {
+	gracePeriod := time.Hour
+
+	// unknown algorithms are rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:         services.HostCA,
+		GracePeriod:  &gracePeriod,
+		Mode:         services.RotationModeManual,
+		KeyAlgorithm: "dsa",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// rotate the host certificate authority to ECDSA keys
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:         services.HostCA,
+			GracePeriod:  &gracePeriod,
+			TargetPhase:  targetPhase,
+			Mode:         services.RotationModeManual,
+			KeyAlgorithm: RotationKeyAlgorithmECDSAP256,
+		})
+		c.Assert(err, IsNil)
+	}
+
+	ca := s.ca(c, services.HostCA)
+	signer, err := ssh.ParsePrivateKey(ca.GetSigningKeys()[0])
+	c.Assert(err, IsNil)
+	c.Assert(signer.PublicKey().Type(), Equals, ssh.KeyAlgoECDSA256)
+	tlsCert, err := tlsca.ParseCertificatePEM(ca.GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+	_, ok := tlsCert.PublicKey.(*ecdsa.PublicKey)
+	c.Assert(ok, Equals, true)
+
+	// the rotated ECDSA certificate authority can issue valid certificates
+	c.Assert(s.a.VerifyRotatedCA(services.HostCA), IsNil)
+}
+
+// TestDryRunRotation tests that a dry run computes the would-be rotation
+// state without persisting any changes
+func (s *RotationSuite) TestDryRunRotation(c *C) {
+	gracePeriod := time.Hour
+
+	// an invalid request fails validation on the dry run as well
+	_, err := s.a.DryRunRotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: "invalid",
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	rotations, err := s.a.DryRunRotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rotations, HasLen, 2)
+	for _, rotation := range rotations {
+		c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+		c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+		c.Assert(rotation.Schedule.UpdateServers.After(s.clock.Now()), Equals, true)
+	}
+
+	// the certificate authorities have not been touched
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Not(Equals), services.RotationStateInProgress)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+
+	// the DryRun flag on the request takes the same path
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		DryRun:      true,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Not(Equals), services.RotationStateInProgress)
+
+	// a dry run of a phase transition that is not allowed from the
+	// current state fails without modifying anything
+	_, err = s.a.DryRunRotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// testAuditLog captures audit events emitted during rotation
+type testAuditLog struct {
+	events.IAuditLog
+	emitted []testAuditEvent
+}
+
+// testAuditEvent is a single captured audit event
+type testAuditEvent struct {
+	eventType string
+	fields    events.EventFields
+}
+
+func (l *testAuditLog) EmitAuditEvent(eventType string, fields events.EventFields) error {
+	l.emitted = append(l.emitted, testAuditEvent{eventType: eventType, fields: fields})
+	return nil
+}
+
+// TestRotationAuditEvents tests that manual and automatic phase
+// transitions emit structured audit events
+func (s *RotationSuite) TestRotationAuditEvents(c *C) {
+	gracePeriod := time.Hour
+	alog := &testAuditLog{IAuditLog: s.a.IAuditLog}
+	s.a.IAuditLog = alog
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeAuto,
+		Initiator:   "admin",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(alog.emitted, HasLen, 1)
+	event := alog.emitted[0]
+	c.Assert(event.eventType, Equals, events.CertAuthorityRotationEvent)
+	c.Assert(event.fields[events.RotationCertType], Equals, string(services.HostCA))
+	c.Assert(event.fields[events.RotationClusterName], Equals, s.a.clusterName.GetClusterName())
+	c.Assert(event.fields[events.RotationOldPhase], Equals, "")
+	c.Assert(event.fields[events.RotationNewPhase], Equals, services.RotationPhaseUpdateClients)
+	c.Assert(event.fields[events.RotationMode], Equals, services.RotationModeAuto)
+	c.Assert(event.fields[events.RotationID], Not(Equals), "")
+	c.Assert(event.fields[events.EventUser], Equals, "admin")
+
+	// automatic transitions emit events as well
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(alog.emitted, HasLen, 2)
+	event = alog.emitted[1]
+	c.Assert(event.fields[events.RotationOldPhase], Equals, services.RotationPhaseUpdateClients)
+	c.Assert(event.fields[events.RotationNewPhase], Equals, services.RotationPhaseUpdateServers)
+	c.Assert(event.fields[events.EventUser], Equals, rotationInitiatorAuto)
+}
+
+// TestGracePeriodBounds tests that grace periods outside of sane bounds
+// are rejected while zero keeps its force rotation meaning
+func (s *RotationSuite) TestGracePeriodBounds(c *C) {
+	negative := -time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &negative,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	tooLong := defaults.MaxRotationGracePeriod + time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &tooLong,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// zero still force rotates in one step with an acknowledgement
+	zero := time.Duration(0)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:             services.HostCA,
+		GracePeriod:      &zero,
+		Mode:             services.RotationModeManual,
+		AcknowledgeForce: true,
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+
+	// the policy validation enforces the same bounds
+	err = s.a.SetRotationPolicy(RotationPolicy{
+		Type:        services.HostCA,
+		GracePeriod: services.NewDuration(tooLong),
+	})
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestPauseResumeRotation tests freezing an in-progress automatic rotation
+// and resuming it with the remaining schedule re-anchored
+func (s *RotationSuite) TestPauseResumeRotation(c *C) {
+	gracePeriod := time.Hour
+
+	// nothing to pause or resume on standby
+	err := s.a.PauseRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+	err = s.a.ResumeRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.a.PauseRotation(services.HostCA), IsNil)
+	err = s.a.PauseRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+
+	// the paused rotation does not advance past its schedule
+	s.clock.Advance(2 * gracePeriod)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// resuming re-anchors the schedule, the next phase still gets
+	// its full remaining interval
+	c.Assert(s.a.ResumeRotation(services.HostCA), IsNil)
+	ca = s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Paused, Equals, false)
+	c.Assert(rotation.Schedule.UpdateServers.After(s.clock.Now()), Equals, true)
+
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// once the re-anchored schedule is reached the rotation advances
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestScheduleOffsets tests supplying the rotation schedule as relative
+// offsets instead of absolute timestamps
+func (s *RotationSuite) TestScheduleOffsets(c *C) {
+	gracePeriod := time.Hour
+
+	// offsets have to be increasing and fit within the grace period
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:            services.HostCA,
+		GracePeriod:     &gracePeriod,
+		Mode:            services.RotationModeAuto,
+		ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: 30 * time.Minute, Standby: 20 * time.Minute},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:            services.HostCA,
+		GracePeriod:     &gracePeriod,
+		Mode:            services.RotationModeAuto,
+		ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: 10 * time.Minute, Standby: 2 * time.Hour},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// offsets can not be combined with an absolute schedule
+	schedule, err := services.GenerateSchedule(s.clock, gracePeriod)
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:            services.HostCA,
+		GracePeriod:     &gracePeriod,
+		Mode:            services.RotationModeAuto,
+		Schedule:        schedule,
+		ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: 10 * time.Minute, Standby: 30 * time.Minute},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// a rotation with uneven per phase intervals
+	started := s.clock.Now().UTC()
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:            services.HostCA,
+		GracePeriod:     &gracePeriod,
+		Mode:            services.RotationModeAuto,
+		ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: 10 * time.Minute, Standby: 50 * time.Minute},
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Schedule.UpdateServers, Equals, started.Add(10*time.Minute))
+	c.Assert(rotation.Schedule.Standby, Equals, started.Add(50*time.Minute))
+
+	// the slow phase gets its long interval
+	s.clock.Advance(11 * time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+	s.clock.Advance(40 * time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+}
+
+// TestRollbackSingleKeyCA tests that rolling back a certificate authority
+// that only has a single key set fails with an error instead of panicking,
+// e.g. when the rotation state got corrupted
+func (s *RotationSuite) TestRollbackSingleKeyCA(c *C) {
+	ca := suite.NewTestCA(services.HostCA, "me.localhost")
+	// a corrupted in-progress rotation on a single key certificate
+	// authority, there is no previous key to restore
+	rotation := ca.GetRotation()
+	rotation.State = services.RotationStateInProgress
+	rotation.Phase = services.RotationPhaseUpdateClients
+	ca.SetRotation(rotation)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+
+	_, err := processRotationRequest(rotationReq{
+		clock:       s.clock,
+		ca:          ca,
+		targetPhase: services.RotationPhaseRollback,
+		mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestRotationPhaseHook tests that the phase hook fires once per
+// certificate authority transition and its errors never block the rotation
+func (s *RotationSuite) TestRotationPhaseHook(c *C) {
+	gracePeriod := time.Hour
+	type hookCall struct {
+		caType   services.CertAuthType
+		rotation services.Rotation
+	}
+	var calls []hookCall
+	s.a.OnRotationPhase(func(caType services.CertAuthType, rotation services.Rotation) error {
+		calls = append(calls, hookCall{caType: caType, rotation: rotation})
+		return trace.BadParameter("hook errors are logged and ignored")
+	})
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(calls, HasLen, 1)
+	c.Assert(calls[0].caType, Equals, services.HostCA)
+	c.Assert(calls[0].rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(calls[0].rotation.CurrentID, Not(Equals), "")
+
+	// the hook error did not block the rotation
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateInProgress)
+
+	// automatic transitions fire the hook as well
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(calls, HasLen, 2)
+	c.Assert(calls[1].rotation.Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestRotationScope verifies that a rotation scoped to the TLS key pair
+// regenerates only the TLS material, keeps the SSH keys byte for byte
+// identical through the full cycle and still supports rollback.
+func (s *RotationSuite) TestRotationScope(c *C) {
+	gracePeriod := time.Hour
+	before := s.ca(c, services.HostCA)
+
+	// an unknown scope is rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Scope:       "sshtls",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Scope:       RotationScopeTLS,
+	})
+	c.Assert(err, IsNil)
+
+	// only the TLS key pair has been doubled up, the SSH keys
+	// are untouched
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 2)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+	c.Assert(ca.GetSigningKeys()[0], DeepEquals, before.GetSigningKeys()[0])
+	c.Assert(ca.GetCheckingKeys()[0], DeepEquals, before.GetCheckingKeys()[0])
+
+	// a scoped rotation can be rolled back, restoring the old TLS key
+	// pair while the SSH keys remain as they were
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+	c.Assert(ca.GetTLSKeyPairs()[0], DeepEquals, before.GetTLSKeyPairs()[0])
+	c.Assert(ca.GetSigningKeys()[0], DeepEquals, before.GetSigningKeys()[0])
+
+	// an SSH scoped rotation taken through the full cycle replaces
+	// the SSH keys and keeps the TLS key pair
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Scope:       RotationScopeSSH,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(ca.GetSigningKeys()[0], Not(DeepEquals), before.GetSigningKeys()[0])
+	c.Assert(ca.GetTLSKeyPairs()[0], DeepEquals, before.GetTLSKeyPairs()[0])
+}
+
+// TestRotationPreflight verifies that advancing to the update_servers
+// phase is blocked until all registered auth servers and proxies have
+// acknowledged the current rotation, and that SkipPreflight overrides
+// the check.
+func (s *RotationSuite) TestRotationPreflight(c *C) {
+	gracePeriod := time.Hour
+	server := &services.ServerV2{
+		Kind:    services.KindAuthServer,
+		Version: services.V2,
+		Metadata: services.Metadata{
+			Name:      "auth-1",
+			Namespace: defaults.Namespace,
+		},
+		Spec: services.ServerSpecV2{
+			Addr:     "127.0.0.1:3025",
+			Hostname: "auth-1",
+		},
+	}
+	c.Assert(s.a.Presence.UpsertAuthServer(server), IsNil)
+	proxy := &services.ServerV2{
+		Kind:    services.KindProxy,
+		Version: services.V2,
+		Metadata: services.Metadata{
+			Name:      "proxy-1",
+			Namespace: defaults.Namespace,
+		},
+		Spec: services.ServerSpecV2{
+			Addr:     "127.0.0.1:3023",
+			Hostname: "proxy-1",
+		},
+	}
+	c.Assert(s.a.Presence.UpsertProxy(proxy), IsNil)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	rotationID := s.ca(c, services.HostCA).GetRotation().CurrentID
+
+	// neither peer has observed the rotation yet, the transition is
+	// rejected with an error naming both of them
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*auth-1, proxy-1.*")
+
+	// one peer catching up is not enough
+	c.Assert(s.a.AcknowledgeRotation(services.HostCA, rotationID, "auth-1"), IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*proxy-1.*")
+
+	// SkipPreflight overrides the check for emergencies
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:          services.HostCA,
+		GracePeriod:   &gracePeriod,
+		TargetPhase:   services.RotationPhaseUpdateServers,
+		Mode:          services.RotationModeManual,
+		SkipPreflight: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	// roll back and retry with all peers caught up, the preflight passes
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	rotationID = s.ca(c, services.HostCA).GetRotation().CurrentID
+	c.Assert(s.a.AcknowledgeRotation(services.HostCA, rotationID, "auth-1"), IsNil)
+	c.Assert(s.a.AcknowledgeRotation(services.HostCA, rotationID, "proxy-1"), IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestAutoRotateConflict verifies that an automatic phase transition
+// computed from a stale certificate authority is detected as a conflict
+// and, after refetching, a phase already advanced by another auth server
+// is treated as success rather than advanced twice.
+func (s *RotationSuite) TestAutoRotateConflict(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// capture a stale copy of the certificate authority before
+	// the rotation advances
+	stale := s.ca(c, services.HostCA)
+	staleRotation := stale.GetRotation()
+	c.Assert(staleRotation.Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// another auth server advances the rotation to update_servers
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	// a pass over the stale copy detects the conflict, refetches and
+	// finds the transition already done, the phase is not advanced again
+	c.Assert(s.a.autoRotate(stale), IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+
+	// a direct compare and swap against a stale snapshot fails without
+	// touching the stored certificate authority
+	err = s.a.compareAndSwapRotatedCertAuthority(ca, staleRotation, services.RotationPhaseStandby)
+	fixtures.ExpectCompareFailed(c, err)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestScheduledStartConflict verifies that two auth servers observing
+// the same scheduled rotation can not both start it - the second start
+// fails its compare and swap and picks up the keys the first one wrote
+// instead of overwriting them.
+func (s *RotationSuite) TestScheduledStartConflict(c *C) {
+	gracePeriod := time.Hour
+	startAt := s.clock.Now().UTC().Add(time.Hour)
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		StartAt:     &startAt,
+	})
+	c.Assert(err, IsNil)
+
+	// capture a stale copy of the certificate authority while the
+	// rotation is still pending
+	stale := s.ca(c, services.HostCA)
+	c.Assert(stale.GetRotation().State, Equals, services.RotationStateScheduled)
+
+	// another auth server reaches the start time first and starts the
+	// rotation with its own freshly generated keys
+	s.clock.Advance(time.Hour + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateInProgress)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+	firstID := ca.GetRotation().CurrentID
+	firstKey := ca.GetCheckingKeys()[0]
+
+	// a pass over the stale copy fails the compare and swap, refetches
+	// and finds the rotation already started, the keys the first start
+	// generated stay in place
+	c.Assert(s.a.autoRotate(stale), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().CurrentID, Equals, firstID)
+	c.Assert(string(ca.GetCheckingKeys()[0]), Equals, string(firstKey))
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+}
+
+// TestRotationHistory verifies that finished rotations are recorded in
+// the bounded per-CA history with the rollback flag set appropriately.
+func (s *RotationSuite) TestRotationHistory(c *C) {
+	gracePeriod := time.Hour
+
+	// no rotations have finished yet
+	entries, err := s.a.GetRotationHistory(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	// a full manual rotation cycle
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	firstID := s.ca(c, services.HostCA).GetRotation().CurrentID
+
+	entries, err = s.a.GetRotationHistory(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].CurrentID, Equals, firstID)
+	c.Assert(entries[0].RolledBack, Equals, false)
+	c.Assert(entries[0].Mode, Equals, services.RotationModeManual)
+	c.Assert(entries[0].GracePeriod.Duration, Equals, gracePeriod)
+	c.Assert(entries[0].Started.IsZero(), Equals, false)
+
+	// a rotation that is rolled back
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseRollback,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+
+	entries, err = s.a.GetRotationHistory(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[1].RolledBack, Equals, true)
+
+	// a forced rotation completes in one step and is recorded as well
+	zeroGracePeriod := time.Duration(0)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:             services.HostCA,
+		GracePeriod:      &zeroGracePeriod,
+		AcknowledgeForce: true,
+	})
+	c.Assert(err, IsNil)
+
+	entries, err = s.a.GetRotationHistory(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[2].CurrentID, Equals, s.ca(c, services.HostCA).GetRotation().CurrentID)
+	c.Assert(entries[2].RolledBack, Equals, false)
+
+	// the user CA history is tracked separately
+	entries, err = s.a.GetRotationHistory(services.UserCA)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+// TestRotationCATTL verifies that the certificate lifetime of the newly
+// generated TLS certificate authority can be overridden per rotation
+// and that nonsensical values are rejected.
+func (s *RotationSuite) TestRotationCATTL(c *C) {
+	gracePeriod := time.Hour
+
+	// the TTL has to be positive and cover the grace period
+	negativeTTL := -time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		CATTL:       &negativeTTL,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	shortTTL := time.Minute
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		CATTL:       &shortTTL,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	caTTL := 24 * time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		CATTL:       &caTTL,
+	})
+	c.Assert(err, IsNil)
+
+	// the newly generated certificate reflects the override, the
+	// certificate is generated with the wall clock rather than the
+	// fake test clock
+	ca := s.ca(c, services.HostCA)
+	cert, err := tlsca.ParseCertificatePEM(ca.GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+	remaining := cert.NotAfter.Sub(time.Now())
+	c.Assert(remaining > caTTL-time.Hour, Equals, true, Commentf("remaining %v", remaining))
+	c.Assert(remaining < caTTL+time.Hour, Equals, true, Commentf("remaining %v", remaining))
+}
+
+// TestGracePeriodVsCATTL verifies that a rotation whose grace period
+// does not leave enough of the new CA certificate lifetime is rejected
+// with an error naming both durations.
+func (s *RotationSuite) TestGracePeriodVsCATTL(c *C) {
+	gracePeriod := 10 * time.Hour
+
+	// a TTL equal to the grace period leaves no margin
+	caTTL := gracePeriod
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		CATTL:       &caTTL,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*10h0m0s.*")
+
+	// a TTL covering the grace period plus the margin is accepted
+	caTTL = gracePeriod + 2*time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		CATTL:       &caTTL,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestDatabaseCARotation verifies that the database certificate authority
+// participates in the rotation state machine, and that rotating all types
+// implicitly skips certificate authority types the cluster does not have.
+func (s *RotationSuite) TestDatabaseCARotation(c *C) {
+	gracePeriod := time.Hour
+
+	// the cluster has no database CA yet, rotating all types implicitly
+	// rotates the host and user CAs and skips the missing one
+	err := s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// rotating the missing type explicitly is an error
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.DatabaseCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectNotFound(c, err)
+
+	c.Assert(s.a.UpsertCertAuthority(
+		suite.NewTestCA(services.DatabaseCA, "me.localhost")), IsNil)
+
+	// the database CA goes through the same full manual cycle
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.DatabaseCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+
+	ca := s.ca(c, services.DatabaseCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	c.Assert(rotation.LastRotated.IsZero(), Equals, false)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+
+	// the rotation status enumerates the database CA once it exists
+	statuses, err := s.a.GetRotationStatus("")
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 3)
+}
+
+// TestRotateWithResult verifies that RotateCertAuthorityWithResult returns
+// the persisted certificate authorities so callers do not have to re-fetch
+// them to inspect the new rotation state.
+func (s *RotationSuite) TestRotateWithResult(c *C) {
+	gracePeriod := time.Hour
+	rotated, err := s.a.RotateCertAuthorityWithResult(RotateRequest{
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rotated, HasLen, 2)
+
+	for _, ca := range rotated {
+		rotation := ca.GetRotation()
+		c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+		c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+		// the returned object matches what was persisted
+		stored := s.ca(c, ca.GetType())
+		c.Assert(stored.GetRotation(), DeepEquals, rotation)
+		c.Assert(stored.GetTLSKeyPairs(), DeepEquals, ca.GetTLSKeyPairs())
+	}
+
+	// a failed rotation returns no certificate authorities
+	rotated, err = s.a.RotateCertAuthorityWithResult(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(rotated, HasLen, 0)
+}
+
+// TestIdempotentPhaseRetry verifies that re-requesting the phase the
+// rotation is already in succeeds without changing the certificate
+// authority, while genuinely illegal transitions still fail.
+func (s *RotationSuite) TestIdempotentPhaseRetry(c *C) {
+	gracePeriod := time.Hour
+
+	// standby to standby with no rotation in progress is a no-op
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	rotationID := ca.GetRotation().CurrentID
+
+	// retrying the start does not generate another key set
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().CurrentID, Equals, rotationID)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 2)
+
+	// skipping ahead is still illegal
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	revisionBefore, err := s.a.getRotationRevision(services.HostCA)
+	c.Assert(err, IsNil)
+
+	// a retry of update_servers is a no-op and records no transition
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	revisionAfter, err := s.a.getRotationRevision(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(revisionAfter, Equals, revisionBefore)
+}
+
+// TestRotationCheckInterval verifies the cadence of the periodic rotation
+// checks: without jitter the configured period is used as is, with jitter
+// every interval lands within the configured bound above the period.
+func (s *RotationSuite) TestRotationCheckInterval(c *C) {
+	// the default cadence is unchanged when nothing is configured
+	c.Assert(s.a.rotationCheckInterval(), Equals, defaults.CARotationCheckPeriod)
+
+	s.a.rotationCheckPeriod = time.Minute
+	c.Assert(s.a.rotationCheckInterval(), Equals, time.Minute)
+
+	s.a.rotationCheckJitter = 10 * time.Second
+	for i := 0; i < 100; i++ {
+		interval := s.a.rotationCheckInterval()
+		c.Assert(interval >= time.Minute, Equals, true, Commentf("interval %v", interval))
+		c.Assert(interval < time.Minute+10*time.Second, Equals, true, Commentf("interval %v", interval))
+	}
+}
+
+// TestForceCompleteRotation verifies the escape hatch for stuck rotations:
+// completing from update_servers ignores the schedule, completing from
+// update_clients requires explicitly accepting the data loss.
+func (s *RotationSuite) TestForceCompleteRotation(c *C) {
+	// nothing to force complete while in standby
+	err := s.a.ForceCompleteRotation(services.HostCA, false)
+	fixtures.ExpectBadParameter(c, err)
+
+	gracePeriod := time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// servers have not switched yet, forcing completion requires
+	// accepting the fallout
+	err = s.a.ForceCompleteRotation(services.HostCA, false)
+	fixtures.ExpectBadParameter(c, err)
+
+	c.Assert(s.a.ForceCompleteRotation(services.HostCA, true), IsNil)
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	c.Assert(rotation.LastRotated.IsZero(), Equals, false)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+
+	// from update_servers no acknowledgement is needed, even with
+	// a schedule stuck far in the future
+	farFuture := s.clock.Now().Add(1000 * time.Hour)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeAuto,
+		Schedule: &services.RotationSchedule{
+			UpdateClients: s.clock.Now().Add(time.Minute),
+			UpdateServers: s.clock.Now().Add(2 * time.Minute),
+			Standby:       farFuture,
+		},
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.a.ForceCompleteRotation(services.HostCA, false), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+}
+
+// TestRotationContextCancel verifies that rotation requests honor context
+// cancellation and leave the certificate authorities untouched when the
+// context is already done.
+func (s *RotationSuite) TestRotationContextCancel(c *C) {
+	gracePeriod := time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rotated, err := s.a.RotateCertAuthorityContext(ctx, RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, NotNil)
+	c.Assert(rotated, HasLen, 0)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, "")
+
+	// the automatic rotation checks stop promptly as well
+	c.Assert(s.a.autoRotateCertAuthorities(ctx), NotNil)
+
+	// an active context works as before
+	rotated, err = s.a.RotateCertAuthorityContext(context.Background(), RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rotated, HasLen, 1)
+}
+
+// TestRotationMetrics verifies that finished rotations update the
+// completion and rollback counters and the per-type phase gauge.
+func (s *RotationSuite) TestRotationMetrics(c *C) {
+	gather := func(name string, labels map[string]string) float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		c.Assert(err, IsNil)
+		for _, family := range families {
+			if family.GetName() != name {
+				continue
+			}
+		metrics:
+			for _, metric := range family.GetMetric() {
+				for key, val := range labels {
+					found := false
+					for _, pair := range metric.GetLabel() {
+						if pair.GetName() == key && pair.GetValue() == val {
+							found = true
+							break
+						}
+					}
+					if !found {
+						continue metrics
+					}
+				}
+				if metric.GetCounter() != nil {
+					return metric.GetCounter().GetValue()
+				}
+				return metric.GetGauge().GetValue()
+			}
+		}
+		return 0
+	}
+	labels := map[string]string{"type": string(services.HostCA), "mode": services.RotationModeManual}
+	completedBefore := gather("ca_rotations_completed_total", labels)
+	rolledBackBefore := gather("ca_rotations_rolled_back_total", labels)
+
+	gracePeriod := time.Hour
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Assert(gather("ca_rotations_completed_total", labels), Equals, completedBefore+1)
+	c.Assert(gather("ca_rotation_phase", map[string]string{"type": string(services.HostCA)}), Equals, 0.0)
+
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseRollback,
+		services.RotationPhaseStandby,
+	} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Assert(gather("ca_rotations_rolled_back_total", labels), Equals, rolledBackBefore+1)
+
+	// the gauge tracks the phase of an in-progress rotation
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(gather("ca_rotation_phase", map[string]string{"type": string(services.HostCA)}), Equals, 1.0)
+}
+
+// TestForceRotationGuard verifies that a zero grace period is rejected
+// unless the destructive one-step rotation is explicitly acknowledged,
+// a stray zero value must not silently drop the old certificate authority.
+func (s *RotationSuite) TestForceRotationGuard(c *C) {
+	zeroGracePeriod := time.Duration(0)
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &zeroGracePeriod,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*AcknowledgeForce.*")
+
+	// nothing has been rotated
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().CurrentID, Equals, "")
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+
+	// with the acknowledgement the rotation goes through in one step
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:             services.HostCA,
+		GracePeriod:      &zeroGracePeriod,
+		AcknowledgeForce: true,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().CurrentID, Not(Equals), "")
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+}
+
+// TestStepBackToUpdateClients verifies that a rotation in update_servers
+// can step back to update_clients without touching any key material,
+// giving lagging clients more time without a full rollback.
+func (s *RotationSuite) TestStepBackToUpdateClients(c *C) {
+	gracePeriod := time.Hour
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+	} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	before := s.ca(c, services.HostCA)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// only the phase changed, the key material and signing order
+	// are untouched
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.CurrentID, Equals, before.GetRotation().CurrentID)
+	c.Assert(ca.GetSigningKeys(), DeepEquals, before.GetSigningKeys())
+	c.Assert(ca.GetCheckingKeys(), DeepEquals, before.GetCheckingKeys())
+	c.Assert(ca.GetTLSKeyPairs(), DeepEquals, before.GetTLSKeyPairs())
+
+	// the rotation can advance and complete normally afterwards
+	for _, phase := range []string{
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+}
+
+// TestRotationInitiator verifies that the identity of whoever started
+// a rotation is persisted on the rotation object and that a rollback
+// records its own initiator separately.
+func (s *RotationSuite) TestRotationInitiator(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Initiator:   "admin",
+	})
+	c.Assert(err, IsNil)
+
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.InitiatedBy, Equals, "admin")
+	c.Assert(rotation.RollbackInitiatedBy, Equals, "")
+
+	// the initiator is surfaced in the rotation status
+	status, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status[0].InitiatedBy, Equals, "admin")
+
+	// the rollback initiator is recorded separately, the initiator
+	// of the rotation being rolled back is kept
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+		Initiator:   "ops",
+	})
+	c.Assert(err, IsNil)
+
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.InitiatedBy, Equals, "admin")
+	c.Assert(rotation.RollbackInitiatedBy, Equals, "ops")
+
+	// both initiators remain visible after the rollback completes,
+	// like the last rotated timestamp
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+		Initiator:   "ops",
+	})
+	c.Assert(err, IsNil)
+
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.InitiatedBy, Equals, "admin")
+	c.Assert(rotation.RollbackInitiatedBy, Equals, "ops")
+
+	// starting a new rotation resets the recorded initiators
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Initiator:   "release-bot",
+	})
+	c.Assert(err, IsNil)
+
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.InitiatedBy, Equals, "release-bot")
+	c.Assert(rotation.RollbackInitiatedBy, Equals, "")
+}
+
+// TestRotationOutcome verifies that a rotation spanning multiple
+// certificate authority types attempts every type and reports per type
+// errors, instead of abandoning the remaining types at the first
+// failure and leaving the caller to guess how far the request got.
+func (s *RotationSuite) TestRotationOutcome(c *C) {
+	gracePeriod := time.Hour
+
+	// only the host certificate authority enters the rotation
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// advancing all types to update_servers succeeds for the host
+	// certificate authority and fails for the user one still on standby
+	outcome, err := s.a.RotateCertAuthorityOutcome(context.TODO(), RotateRequest{
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(outcome.Rotated, HasLen, 1)
+	c.Assert(outcome.Rotated[0].GetType(), Equals, services.HostCA)
+	c.Assert(outcome.Errors, HasLen, 1)
+	fixtures.ExpectBadParameter(c, outcome.Errors[services.UserCA])
+	fixtures.ExpectBadParameter(c, outcome.Err())
+
+	// the host certificate authority has advanced despite the failure,
+	// the user one is untouched
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+	c.Assert(s.ca(c, services.UserCA).GetRotation().Phase, Equals, "")
+
+	// completing the rotation of the host certificate authority alone
+	// reconciles the divergence and reports no errors
+	outcome, err = s.a.RotateCertAuthorityOutcome(context.TODO(), RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(outcome.Err(), IsNil)
+	c.Assert(outcome.Rotated, HasLen, 1)
+	c.Assert(outcome.Errors, HasLen, 0)
+}
+
+// TestRotationWatch verifies that rotation watchers receive an event
+// with the old and new phase for every rotation state change, filtered
+// to the subscribed certificate authority type.
+func (s *RotationSuite) TestRotationWatch(c *C) {
+	gracePeriod := time.Hour
+
+	watcher, err := s.a.WatchRotation(services.HostCA)
+	c.Assert(err, IsNil)
+	defer watcher.Close()
+
+	// watching an unsupported type is rejected
+	_, err = s.a.WatchRotation("unknown")
+	fixtures.ExpectBadParameter(c, err)
+
+	nextChange := func() RotationStateChange {
+		select {
+		case change := <-watcher.Events():
+			return change
+		default:
+			c.Fatalf("expected a rotation state change event")
+			return RotationStateChange{}
+		}
+	}
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	rotationID := s.ca(c, services.HostCA).GetRotation().CurrentID
+	change := nextChange()
+	c.Assert(change.Type, Equals, services.HostCA)
+	c.Assert(change.OldPhase, Equals, "")
+	c.Assert(change.NewPhase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(change.NewState, Equals, services.RotationStateInProgress)
+	c.Assert(change.CurrentID, Equals, rotationID)
+
+	// the user certificate authority is not watched, its transitions
+	// are filtered out
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	select {
+	case change := <-watcher.Events():
+		c.Fatalf("unexpected event for type %q", change.Type)
+	default:
+	}
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	change = nextChange()
+	c.Assert(change.OldPhase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(change.NewPhase, Equals, services.RotationPhaseUpdateServers)
+
+	// a closed watcher receives no further events
+	c.Assert(watcher.Close(), IsNil)
+	select {
+	case <-watcher.Done():
+	default:
+		c.Fatalf("expected the watcher to be done after Close")
+	}
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	select {
+	case <-watcher.Events():
+		c.Fatalf("unexpected event after the watcher was closed")
+	default:
+	}
+}
+
+// TestRotateExternalCertAuthority verifies that external certificate
+// authority updates pushed by trusted clusters only ever touch the
+// remote certificate authority, and are accepted while a local rotation
+// of the same type is in progress.
+func (s *RotationSuite) TestRotateExternalCertAuthority(c *C) {
+	gracePeriod := time.Hour
+
+	// the local certificate authority can not be updated through
+	// the external rotation endpoint
+	err := s.a.RotateExternalCertAuthority(suite.NewTestCA(services.HostCA, "me.localhost"))
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*local certificate authority.*")
+
+	remote := suite.NewTestCA(services.HostCA, "remote.localhost")
+	c.Assert(s.a.Trust.UpsertCertAuthority(remote), IsNil)
+
+	localBefore := s.ca(c, services.HostCA)
+
+	// an update of the remote certificate authority is accepted while
+	// the local one of the same type is mid-rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	update := suite.NewTestCA(services.HostCA, "remote.localhost")
+	update.Spec.Rotation = &services.Rotation{
+		State:     services.RotationStateInProgress,
+		Phase:     services.RotationPhaseUpdateClients,
+		CurrentID: "remote-rotation",
+	}
+	alog := &testAuditLog{IAuditLog: s.a.IAuditLog}
+	s.a.IAuditLog = alog
+	c.Assert(s.a.RotateExternalCertAuthority(update), IsNil)
+
+	// the accepted update leaves an audit trail naming the remote
+	// cluster and the phase it rotated to
+	c.Assert(alog.emitted, HasLen, 1)
+	event := alog.emitted[0]
+	c.Assert(event.eventType, Equals, events.CertAuthorityRotationEvent)
+	c.Assert(event.fields[events.RotationCertType], Equals, string(services.HostCA))
+	c.Assert(event.fields[events.RotationClusterName], Equals, "remote.localhost")
+	c.Assert(event.fields[events.RotationOldPhase], Equals, "")
+	c.Assert(event.fields[events.RotationNewPhase], Equals, services.RotationPhaseUpdateClients)
+	c.Assert(event.fields[events.RotationID], Equals, "remote-rotation")
+
+	// the remote certificate authority carries the pushed rotation state
+	stored, err := s.a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       services.HostCA,
+		DomainName: "remote.localhost",
+	}, false)
+	c.Assert(err, IsNil)
+	c.Assert(stored.GetRotation().CurrentID, Equals, "remote-rotation")
+	c.Assert(stored.GetCheckingKeys(), DeepEquals, update.GetCheckingKeys())
+	// the stored copy is fetched without private keys, compare
+	// the certificates only
+	c.Assert(stored.GetTLSKeyPairs()[0].Cert, DeepEquals, update.GetTLSKeyPairs()[0].Cert)
+
+	// the local certificate authority is untouched by the external
+	// update - it is still mid-rotation with its own key material,
+	// the old key sits at the second position, see startNewRotation
+	local := s.ca(c, services.HostCA)
+	c.Assert(local.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(local.GetRotation().CurrentID, Not(Equals), "remote-rotation")
+	c.Assert(local.GetCheckingKeys(), HasLen, 2)
+	c.Assert(local.GetCheckingKeys()[1], DeepEquals, localBefore.GetCheckingKeys()[0])
+}
+
+// TestCanaryRotation verifies that a rotation started with a canary
+// selector blocks the update_servers phase until the canary is
+// confirmed healthy, and that the selector matching nodes can use
+// to pick the canary group behaves as expected.
+func (s *RotationSuite) TestCanaryRotation(c *C) {
+	gracePeriod := time.Hour
+
+	// a malformed selector is rejected before the rotation starts
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:           services.HostCA,
+		GracePeriod:    &gracePeriod,
+		TargetPhase:    services.RotationPhaseUpdateClients,
+		Mode:           services.RotationModeManual,
+		CanarySelector: "not-a-selector",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// a canary can only be set when starting a rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:           services.HostCA,
+		GracePeriod:    &gracePeriod,
+		TargetPhase:    services.RotationPhaseUpdateServers,
+		Mode:           services.RotationModeManual,
+		CanarySelector: "group=canary",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:           services.HostCA,
+		GracePeriod:    &gracePeriod,
+		TargetPhase:    services.RotationPhaseUpdateClients,
+		Mode:           services.RotationModeManual,
+		CanarySelector: "group=canary",
+	})
+	c.Assert(err, IsNil)
+
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.CanarySelector, Equals, "group=canary")
+	c.Assert(rotation.CanaryConfirmed, Equals, false)
+	c.Assert(rotation.MatchesCanary(map[string]string{"group": "canary", "env": "prod"}), Equals, true)
+	c.Assert(rotation.MatchesCanary(map[string]string{"group": "stable"}), Equals, false)
+	c.Assert(rotation.MatchesCanary(nil), Equals, false)
+
+	// switching servers is blocked until the canary is confirmed
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*canary.*")
+
+	// there is no canary to confirm on the user certificate authority
+	err = s.a.ConfirmRotationCanary(services.UserCA)
+	fixtures.ExpectBadParameter(c, err)
+
+	c.Assert(s.a.ConfirmRotationCanary(services.HostCA), IsNil)
+	// confirming again is a no-op
+	c.Assert(s.a.ConfirmRotationCanary(services.HostCA), IsNil)
+
+	// the confirmed canary unblocks the rest of the rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// the canary state does not outlive the rotation
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.CanarySelector, Equals, "")
+	c.Assert(rotation.CanaryConfirmed, Equals, false)
+}
+
+// TestCanaryRotationAuto verifies that an automatic rotation with an
+// unconfirmed canary holds at update_clients once the schedule boundary
+// passes instead of failing the periodic checks, and that the other
+// certificate authorities keep progressing meanwhile.
+func (s *RotationSuite) TestCanaryRotationAuto(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:           services.HostCA,
+		GracePeriod:    &gracePeriod,
+		TargetPhase:    services.RotationPhaseUpdateClients,
+		Mode:           services.RotationModeAuto,
+		CanarySelector: "group=canary",
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// past the update_servers boundary the canary rotation holds while
+	// the plain one advances, and the periodic checks keep passing
+	s.clock.Advance(gracePeriod/3 + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(s.ca(c, services.UserCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	// holding is stable across ticks
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// the confirmed canary lets the next tick advance past the boundary
+	c.Assert(s.a.ConfirmRotationCanary(services.HostCA), IsNil)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestRotateRequestJSON verifies that a rotation request survives
+// a JSON round trip the way API clients send it: an omitted or empty
+// mode is defaulted, an invalid mode is rejected and the grace period
+// pointer keeps its absent/zero/set tri-state.
+func (s *RotationSuite) TestRotateRequestJSON(c *C) {
+	roundTrip := func(data string) (*RotateRequest, error) {
+		var req RotateRequest
+		c.Assert(json.Unmarshal([]byte(data), &req), IsNil)
+		// requests are re-serialized when forwarded between auth
+		// servers, make sure nothing is lost on the way
+		remarshalled, err := json.Marshal(req)
+		c.Assert(err, IsNil)
+		var out RotateRequest
+		c.Assert(json.Unmarshal(remarshalled, &out), IsNil)
+		c.Assert(out, DeepEquals, req)
+		err = out.CheckAndSetDefaults(s.clock)
+		return &out, err
+	}
+
+	// an omitted mode is defaulted to manual
+	req, err := roundTrip(`{"type": "host", "target_phase": "update_clients"}`)
+	c.Assert(err, IsNil)
+	c.Assert(req.Mode, Equals, services.RotationModeManual)
+
+	// an explicitly empty mode gets the same treatment
+	req, err = roundTrip(`{"type": "host", "target_phase": "update_clients", "mode": ""}`)
+	c.Assert(err, IsNil)
+	c.Assert(req.Mode, Equals, services.RotationModeManual)
+
+	// an invalid mode is rejected
+	_, err = roundTrip(`{"type": "host", "target_phase": "update_clients", "mode": "semiauto"}`)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, `.*unsupported rotation mode "semiauto".*`)
+
+	// an absent grace period is defaulted
+	req, err = roundTrip(`{"type": "host", "target_phase": "update_clients"}`)
+	c.Assert(err, IsNil)
+	c.Assert(req.GracePeriod, NotNil)
+	c.Assert(*req.GracePeriod, Equals, defaults.RotationGracePeriod)
+
+	// an explicit zero grace period is kept and requires the force
+	// acknowledgement instead of being silently defaulted
+	_, err = roundTrip(`{"type": "host", "target_phase": "update_clients", "grace_period": 0}`)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*AcknowledgeForce.*")
+
+	// a set grace period is kept as is
+	req, err = roundTrip(fmt.Sprintf(`{"type": "host", "target_phase": "update_clients", "grace_period": %v}`, int64(time.Hour)))
+	c.Assert(err, IsNil)
+	c.Assert(req.GracePeriod, NotNil)
+	c.Assert(*req.GracePeriod, Equals, time.Hour)
+}
+
+// TestRotationID verifies that an externally supplied rotation ID is
+// used as the CurrentID of the new rotation for correlation with
+// external workflow engines, and that its format is validated.
+func (s *RotationSuite) TestRotationID(c *C) {
+	gracePeriod := time.Hour
+
+	// an overlong rotation ID is rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		RotationID:  strings.Repeat("x", maxRotationIDLength+1),
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// a rotation ID only makes sense when starting a rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+		RotationID:  "workflow-1234",
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		RotationID:  "workflow-1234",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().CurrentID, Equals, "workflow-1234")
+
+	// the correlation ID is visible in the rotation status
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses[0].CurrentID, Equals, "workflow-1234")
+
+	// complete the rotation and start a new one without an explicit
+	// ID, a UUID is generated as before
+	for _, phase := range []string{services.RotationPhaseUpdateServers, services.RotationPhaseStandby, services.RotationPhaseUpdateClients} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	currentID := s.ca(c, services.HostCA).GetRotation().CurrentID
+	c.Assert(currentID, Not(Equals), "")
+	c.Assert(currentID, Not(Equals), "workflow-1234")
+}
+
+// TestKeyMaterialZeroization verifies that the private key buffers of
+// keys discarded by rotation transitions are wiped rather than left
+// for the garbage collector.
+func (s *RotationSuite) TestKeyMaterialZeroization(c *C) {
+	gracePeriod := time.Hour
+	allZero := func(buf []byte) bool {
+		if len(buf) == 0 {
+			return false
+		}
+		for _, b := range buf {
+			if b != 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// completing a rotation drops and wipes the old private keys
+	for _, phase := range []string{services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	ca := s.ca(c, services.HostCA)
+	oldSigningKey := ca.GetSigningKeys()[1]
+	oldTLSKey := ca.GetTLSKeyPairs()[1].Key
+	c.Assert(allZero(oldSigningKey), Equals, false)
+	c.Assert(completeRotation(s.clock, ca), IsNil)
+	c.Assert(allZero(oldSigningKey), Equals, true)
+	c.Assert(allZero(oldTLSKey), Equals, true)
+	// the keys the certificate authority keeps are intact
+	c.Assert(allZero(ca.GetSigningKeys()[0]), Equals, false)
+	c.Assert(allZero(ca.GetTLSKeyPairs()[0].Key), Equals, false)
+
+	// rolling back drops the new signing key right away and wipes
+	// the new TLS key when the rollback completes
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.UserCA)
+	newSigningKey := ca.GetSigningKeys()[0]
+	newTLSKey := ca.GetTLSKeyPairs()[0].Key
+	c.Assert(startRollingBackRotation(ca, ""), IsNil)
+	c.Assert(allZero(newSigningKey), Equals, true)
+	c.Assert(allZero(newTLSKey), Equals, false)
+	c.Assert(completeRollingBackRotation(s.clock, ca), IsNil)
+	c.Assert(allZero(newTLSKey), Equals, true)
+	c.Assert(allZero(ca.GetSigningKeys()[0]), Equals, false)
+	c.Assert(allZero(ca.GetTLSKeyPairs()[0].Key), Equals, false)
+}
+
+// recordingKeyStore is a RotationKeyStore delegating to the default
+// in-process implementation while recording its invocations, standing
+// in for an HSM or KMS backed key store.
+type recordingKeyStore struct {
+	inner       RotationKeyStore
+	sshCalls    int
+	tlsCalls    int
+	lastSSHPriv []byte
+}
+
+func (r *recordingKeyStore) GenerateSSHKeyPair(algorithm string) ([]byte, []byte, error) {
+	r.sshCalls++
+	priv, pub, err := r.inner.GenerateSSHKeyPair(algorithm)
+	r.lastSSHPriv = priv
+	return priv, pub, err
+}
+
+func (r *recordingKeyStore) GenerateTLSCA(algorithm string, entity pkix.Name, ttl time.Duration) ([]byte, []byte, error) {
+	r.tlsCalls++
+	return r.inner.GenerateTLSCA(algorithm, entity, ttl)
+}
+
+// TestRotationKeyStore verifies that a pluggable key store replaces
+// the in-process key generation of rotated certificate authorities.
+func (s *RotationSuite) TestRotationKeyStore(c *C) {
+	gracePeriod := time.Hour
+	keyStore := &recordingKeyStore{inner: s.a.getRotationKeyStore()}
+	s.a.SetRotationKeyStore(keyStore)
+	defer s.a.SetRotationKeyStore(nil)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keyStore.sshCalls, Equals, 1)
+	c.Assert(keyStore.tlsCalls, Equals, 1)
+
+	// the key material produced by the key store is what ends up
+	// on the certificate authority
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetSigningKeys()[0], DeepEquals, keyStore.lastSSHPriv)
+
+	// phase transitions generate no new keys
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keyStore.sshCalls, Equals, 1)
+	c.Assert(keyStore.tlsCalls, Equals, 1)
+
+	// clearing the key store falls back to the in-process default
+	s.a.SetRotationKeyStore(nil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keyStore.sshCalls, Equals, 1)
+	c.Assert(keyStore.tlsCalls, Equals, 1)
+}
+
+// TestManualModeSchedule verifies that a user supplied schedule is
+// rejected in manual mode, where phase transitions are driven by
+// explicit requests and a schedule would be silently ignored.
+func (s *RotationSuite) TestManualModeSchedule(c *C) {
+	gracePeriod := time.Hour
+	schedule, err := services.GenerateSchedule(s.clock, gracePeriod)
+	c.Assert(err, IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Schedule:    schedule,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// the mode defaults to manual, so an implicit manual request
+	// with a schedule is rejected as well
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:            services.HostCA,
+		GracePeriod:     &gracePeriod,
+		TargetPhase:     services.RotationPhaseUpdateClients,
+		ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: 10 * time.Minute, Standby: 30 * time.Minute},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// the same schedule is accepted in auto mode
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		Schedule:    schedule,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestAutoRotationSimulation drives a full automatic rotation to
+// completion with the runAutoRotation helper and verifies the phases
+// are visited in order.
+func (s *RotationSuite) TestAutoRotationSimulation(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	phases := s.runAutoRotation(c, services.HostCA)
+	c.Assert(phases, DeepEquals, []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	})
+
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+}
+
+// TestZeroValuedRotation verifies that a brand-new certificate authority
+// with no rotation metadata behaves like one in standby: phase requests
+// are normalized instead of producing confusing state errors.
+func (s *RotationSuite) TestZeroValuedRotation(c *C) {
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation(), DeepEquals, services.Rotation{})
+
+	gracePeriod := time.Hour
+
+	// re-requesting standby on a never-rotated authority is a no-op
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation(), DeepEquals, services.Rotation{})
+
+	// phases that need a rotation in progress report the standby
+	// phase instead of an empty string
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, `.*current phase is standby.*`)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, `.*no rotation is in progress.*`)
+
+	// starting a rotation from the zero-valued state works as from standby
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateInProgress)
+}
+
+// TestRotationLock verifies that the advisory rotation lock serializes
+// concurrent operator transitions of the same certificate authority type
+// and expires on its own should the holder crash.
+func (s *RotationSuite) TestRotationLock(c *C) {
+	gracePeriod := time.Hour
+
+	// simulate another operator holding the lock
+	c.Assert(s.a.acquireRotationLock(services.HostCA, "alice"), IsNil)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(trace.IsAlreadyExists(err), Equals, true, Commentf("%v", err))
+	c.Assert(err, ErrorMatches, `.*"alice".*`)
+
+	// the lock is per certificate authority type, other types rotate freely
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// once released, the rotation goes through
+	s.a.releaseRotationLock(services.HostCA)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// the lock is released at the end of the transition, the next
+	// phase request does not trip over it
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestTrustedKeyCount verifies that the rotation status reports how many
+// checking keys and TLS key pairs are trusted, two of each during the
+// rotation overlap window and one of each after completion.
+func (s *RotationSuite) TestTrustedKeyCount(c *C) {
+	checkingKeys, tlsKeyPairs := TrustedKeyCount(s.ca(c, services.HostCA))
+	c.Assert(checkingKeys, Equals, 1)
+	c.Assert(tlsKeyPairs, Equals, 1)
+
+	gracePeriod := time.Hour
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+	} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+
+		statuses, err := s.a.GetRotationStatus(services.HostCA)
+		c.Assert(err, IsNil)
+		c.Assert(statuses, HasLen, 1)
+		c.Assert(statuses[0].CheckingKeyCount, Equals, 2, Commentf("phase %q", phase))
+		c.Assert(statuses[0].TLSKeyPairCount, Equals, 2, Commentf("phase %q", phase))
+	}
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].CheckingKeyCount, Equals, 1)
+	c.Assert(statuses[0].TLSKeyPairCount, Equals, 1)
+}
+
+// TestRotationIDReuse verifies that the ID of the immediately preceding
+// rotation can not be reused, reusing an ID would make correlation and
+// audit ambiguous.
+func (s *RotationSuite) TestRotationIDReuse(c *C) {
+	gracePeriod := time.Hour
+	runRotation := func(rotationID string) error {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: services.RotationPhaseUpdateClients,
+			Mode:        services.RotationModeManual,
+			RotationID:  rotationID,
+		})
+		if err != nil {
+			return err
+		}
+		for _, phase := range []string{services.RotationPhaseUpdateServers, services.RotationPhaseStandby} {
+			err := s.a.RotateCertAuthority(RotateRequest{
+				Type:        services.HostCA,
+				GracePeriod: &gracePeriod,
+				TargetPhase: phase,
+				Mode:        services.RotationModeManual,
+			})
+			c.Assert(err, IsNil)
+		}
+		return nil
+	}
+
+	c.Assert(runRotation("ticket-1234"), IsNil)
+
+	// the ID of the previous rotation is refused
+	err := runRotation("ticket-1234")
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, `.*"ticket-1234".*already used.*`)
+
+	// a fresh ID starts the next rotation
+	c.Assert(runRotation("ticket-5678"), IsNil)
+}
+
+// TestRetainGenerations verifies that rotation can keep the public key
+// material of multiple old certificate authority generations trusted,
+// while private keys never outlive the overlap window.
+func (s *RotationSuite) TestRetainGenerations(c *C) {
+	gracePeriod := time.Hour
+
+	// negative retention is rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:              services.HostCA,
+		GracePeriod:       &gracePeriod,
+		TargetPhase:       services.RotationPhaseUpdateClients,
+		Mode:              services.RotationModeManual,
+		RetainGenerations: -1,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// retention beyond the default can only be set when starting
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:              services.HostCA,
+		GracePeriod:       &gracePeriod,
+		TargetPhase:       services.RotationPhaseUpdateServers,
+		Mode:              services.RotationModeManual,
+		RetainGenerations: 2,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	runRotation := func(retain int) {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:              services.HostCA,
+			GracePeriod:       &gracePeriod,
+			TargetPhase:       services.RotationPhaseUpdateClients,
+			Mode:              services.RotationModeManual,
+			RetainGenerations: retain,
+		})
+		c.Assert(err, IsNil)
+		for _, phase := range []string{services.RotationPhaseUpdateServers, services.RotationPhaseStandby} {
+			err := s.a.RotateCertAuthority(RotateRequest{
+				Type:        services.HostCA,
+				GracePeriod: &gracePeriod,
+				TargetPhase: phase,
+				Mode:        services.RotationModeManual,
+			})
+			c.Assert(err, IsNil)
+		}
+	}
+
+	// a rotation retaining two generations keeps the previous
+	// certificate authority trusted after completion
+	runRotation(2)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 2)
+	// only the current generation keeps its private keys
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(ca.GetTLSKeyPairs()[1].Key, IsNil)
+
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].KeySetHealthy, Equals, true)
+
+	// the default retention trims back to a single trusted generation
+	runRotation(0)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+}
+
+// TestRotationSkewMargin verifies that automatic rotation honors the
+// configured clock skew safety margin and that skew between auth servers
+// is surfaced in the rotation status.
+func (s *RotationSuite) TestRotationSkewMargin(c *C) {
+	gracePeriod := time.Hour
+	s.a.SetRotationSkewMargin(10 * time.Minute)
+	defer s.a.SetRotationSkewMargin(0)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// just past the schedule boundary is within the margin, the phase
+	// does not advance yet
+	boundary := s.ca(c, services.HostCA).GetRotation().Schedule.UpdateServers
+	s.clock.Advance(boundary.Sub(s.clock.Now()) + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// once the margin has passed as well the phase advances
+	s.clock.Advance(10 * time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+
+	// a rotation started in the local future indicates skewed clocks
+	// and is surfaced in the status
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	rotation.Started = s.clock.Now().UTC().Add(5 * time.Minute)
+	ca.SetRotation(rotation)
+	c.Assert(s.a.UpsertCertAuthority(ca), IsNil)
+
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].DetectedClockSkew, Equals, 5*time.Minute)
+}
+
+// TestExtendRotationGracePeriod verifies that the grace period of an
+// in-progress automatic rotation can be extended, pushing the upcoming
+// schedule boundaries out without touching the ones already passed.
+func (s *RotationSuite) TestExtendRotationGracePeriod(c *C) {
+	gracePeriod := time.Hour
+
+	// only an in-progress automatic rotation can be extended
+	err := s.a.ExtendRotationGracePeriod(services.HostCA, time.Hour)
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// the grace period can only grow
+	err = s.a.ExtendRotationGracePeriod(services.HostCA, -time.Hour)
+	fixtures.ExpectBadParameter(c, err)
+
+	before := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(s.a.ExtendRotationGracePeriod(services.HostCA, time.Hour), IsNil)
+
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.GracePeriod, Equals, services.NewDuration(2*time.Hour))
+	c.Assert(rotation.Schedule.UpdateServers, Equals, before.Schedule.UpdateServers.Add(time.Hour))
+	c.Assert(rotation.Schedule.Standby, Equals, before.Schedule.Standby.Add(time.Hour))
+
+	// the original boundary no longer triggers the transition
+	s.clock.Advance(before.Schedule.UpdateServers.Sub(s.clock.Now()) + time.Minute)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// past update_servers only the standby boundary moves
+	s.clock.Advance(time.Hour)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	before = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(before.Phase, Equals, services.RotationPhaseUpdateServers)
+	c.Assert(s.a.ExtendRotationGracePeriod(services.HostCA, 30*time.Minute), IsNil)
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.Schedule.UpdateServers, Equals, before.Schedule.UpdateServers)
+	c.Assert(rotation.Schedule.Standby, Equals, before.Schedule.Standby.Add(30*time.Minute))
+}
+
+// TestTypedTransitionErrors verifies that illegal transition requests
+// return typed errors automation can branch on while keeping their
+// BadParameter classification and messages.
+func (s *RotationSuite) TestTypedTransitionErrors(c *C) {
+	gracePeriod := time.Hour
+
+	// update_servers while on standby is an illegal transition
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(IsIllegalPhaseTransition(err), Equals, true, Commentf("%v", err))
+	c.Assert(IsRotationInProgress(err), Equals, false)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// starting again mid-rotation reports the rotation in progress,
+	// an automation can wait for it to finish and retry
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeAuto,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(IsRotationInProgress(err), Equals, true, Commentf("%v", err))
+	c.Assert(IsIllegalPhaseTransition(err), Equals, false)
+
+	// an unknown phase bypassing request validation is reported as such
+	_, err = processRotationRequest(rotationReq{
+		clock:       s.clock,
+		ca:          s.ca(c, services.HostCA),
+		targetPhase: "banana",
+		mode:        services.RotationModeManual,
+		gracePeriod: gracePeriod,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(IsUnsupportedPhase(err), Equals, true, Commentf("%v", err))
+}
+
+// TestOrphanedNodePreflight verifies that completing a rotation is
+// refused while registered nodes have not been seen online since the
+// rotation started, as dropping the old certificate authority would
+// lock them out, and that SkipPreflight overrides the check.
+func (s *RotationSuite) TestOrphanedNodePreflight(c *C) {
+	gracePeriod := time.Hour
+	upsertNode := func(name string) {
+		server := &services.ServerV2{
+			Kind:    services.KindNode,
+			Version: services.V2,
+			Metadata: services.Metadata{
+				Name:      name,
+				Namespace: defaults.Namespace,
+			},
+			Spec: services.ServerSpecV2{
+				Addr:     "127.0.0.1:3022",
+				Hostname: name,
+			},
+		}
+		server.SetTTL(s.clock, defaults.ServerHeartbeatTTL)
+		c.Assert(s.a.Presence.UpsertNode(server), IsNil)
+	}
+	// the node heartbeats, then goes offline for an hour before the
+	// rotation starts
+	upsertNode("sleeper")
+	s.clock.Advance(time.Hour)
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// completing would orphan the sleeping node
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, `.*would be orphaned: sleeper.*`)
+
+	// once the node has heartbeat during the rotation, completion goes
+	// through
+	upsertNode("sleeper")
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// SkipPreflight overrides the check, e.g. for decommissioned nodes
+	upsertNode("sleeper")
+	s.clock.Advance(time.Hour)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:          services.HostCA,
+		GracePeriod:   &gracePeriod,
+		TargetPhase:   services.RotationPhaseUpdateServers,
+		Mode:          services.RotationModeManual,
+		SkipPreflight: true,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:          services.HostCA,
+		GracePeriod:   &gracePeriod,
+		TargetPhase:   services.RotationPhaseStandby,
+		Mode:          services.RotationModeManual,
+		SkipPreflight: true,
+	})
+	c.Assert(err, IsNil)
+}
+
+// TestPerTypeOverrides verifies that a single request rotating all
+// certificate authority types can override the grace period and
+// schedule per type, with the other types using the top-level fields.
+func (s *RotationSuite) TestPerTypeOverrides(c *C) {
+	gracePeriod := time.Hour
+	hostGracePeriod := 4 * time.Hour
+
+	// overrides require rotating all types
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		PerType: map[services.CertAuthType]RotateRequest{
+			services.HostCA: {GracePeriod: &hostGracePeriod},
+		},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// overrides must not set a type themselves
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		PerType: map[services.CertAuthType]RotateRequest{
+			services.HostCA: {Type: services.HostCA},
+		},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// the host certificate authority gets its own slower schedule,
+	// the user one falls back to the top-level grace period
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		PerType: map[services.CertAuthType]RotateRequest{
+			services.HostCA: {
+				GracePeriod:     &hostGracePeriod,
+				ScheduleOffsets: &services.RotationScheduleOffsets{UpdateServers: time.Hour, Standby: 3 * time.Hour},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	started := s.clock.Now().UTC()
+	hostRotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(hostRotation.GracePeriod, Equals, services.NewDuration(hostGracePeriod))
+	c.Assert(hostRotation.Schedule.UpdateServers, Equals, started.Add(time.Hour))
+	c.Assert(hostRotation.Schedule.Standby, Equals, started.Add(3*time.Hour))
+
+	userRotation := s.ca(c, services.UserCA).GetRotation()
+	c.Assert(userRotation.GracePeriod, Equals, services.NewDuration(gracePeriod))
+	c.Assert(userRotation.Schedule.Standby, Equals, started.Add(gracePeriod))
+}
+
+// TestRotationSubject tests customizing the subject of the newly
+// generated TLS certificate authority beyond the common name.
+func (s *RotationSuite) TestRotationSubject(c *C) {
+	gracePeriod := time.Hour
+
+	// empty subject entries are rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Subject:     &RotationSubject{OrganizationalUnit: []string{""}},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Subject: &RotationSubject{
+			Organization:       []string{"Example Org"},
+			OrganizationalUnit: []string{"infra"},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	cert, err := tlsca.ParseCertificatePEM(ca.GetTLSKeyPairs()[0].Cert)
+	c.Assert(err, IsNil)
+	// the common name and the default organization entry are kept, the
+	// customization only adds to the subject, note that parsing does
+	// not preserve the order of multi-valued attributes
+	c.Assert(cert.Subject.CommonName, Equals, "me.localhost")
+	org := append([]string(nil), cert.Subject.Organization...)
+	sort.Strings(org)
+	c.Assert(org, DeepEquals, []string{"Example Org", "me.localhost"})
+	c.Assert(cert.Subject.OrganizationalUnit, DeepEquals, []string{"infra"})
+}
+
+// TestVerifyCertAuthorityConsistency tests the static consistency check
+// of the certificate authority key material that guards rotation writes.
+func (s *RotationSuite) TestVerifyCertAuthorityConsistency(c *C) {
+	gracePeriod := time.Hour
+
+	// a freshly initialized certificate authority is consistent
+	ca := s.ca(c, services.HostCA)
+	c.Assert(VerifyCertAuthorityConsistency(ca), IsNil)
+
+	// the certificate authority stays consistent through every phase
+	// of a completed rotation and of a rolled back one
+	phases := []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseRollback,
+		services.RotationPhaseStandby,
+	}
+	for _, phase := range phases {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+		ca = s.ca(c, services.HostCA)
+		c.Assert(VerifyCertAuthorityConsistency(ca), IsNil, Commentf("phase %q", phase))
+	}
+
+	// set up an overlap window, the corruption cases below mutate
+	// local copies of the mid-rotation certificate authority
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// swapped checking keys no longer match the signing keys
+	ca = s.ca(c, services.HostCA)
+	checkingKeys := ca.GetCheckingKeys()
+	c.Assert(checkingKeys, HasLen, 2)
+	c.Assert(ca.SetCheckingKeys([][]byte{checkingKeys[1], checkingKeys[0]}), IsNil)
+	err = VerifyCertAuthorityConsistency(ca)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*signing key at position 0 does not match.*")
+
+	// a TLS private key of the wrong generation does not match its
+	// certificate
+	ca = s.ca(c, services.HostCA)
+	keyPairs := ca.GetTLSKeyPairs()
+	c.Assert(keyPairs, HasLen, 2)
+	keyPairs[0].Key, keyPairs[1].Key = keyPairs[1].Key, keyPairs[0].Key
+	ca.SetTLSKeyPairs(keyPairs)
+	err = VerifyCertAuthorityConsistency(ca)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*TLS key at position 0 does not match.*")
+
+	// more trusted keys than the phase allows are rejected, guarding
+	// against transitions that fail to trim the old generations
+	ca = s.ca(c, services.HostCA)
+	checkingKeys = ca.GetCheckingKeys()
+	c.Assert(ca.SetCheckingKeys(append(checkingKeys, checkingKeys[0])), IsNil)
+	err = VerifyCertAuthorityConsistency(ca)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*checking keys.*")
+}
+
+// TestRotateAndWait tests the blocking helper that starts an automatic
+// rotation and waits for it to settle back on standby.
+func (s *RotationSuite) TestRotateAndWait(c *C) {
+	gracePeriod := time.Hour
+
+	// manual mode has nothing to wait for and is rejected
+	_, err := s.a.RotateAndWait(context.TODO(), RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeManual,
+	}, nil)
+	fixtures.ExpectBadParameter(c, err)
+
+	// a canceled context stops the wait right away, the helper does not
+	// get a chance to start the rotation loop past the first poll
+	canceled, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = s.a.RotateAndWait(canceled, RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+	}, nil)
+	c.Assert(err, NotNil)
+	// the rotation itself keeps running after the canceled wait, roll it
+	// back to start the happy path from standby
+	for _, phase := range []string{services.RotationPhaseRollback, services.RotationPhaseStandby} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+
+	// drive a full automatic rotation while the helper waits in the
+	// background, phases observed by the callback are collected in the
+	// goroutine and only read after its result is received
+	type waitResult struct {
+		states map[services.CertAuthType]services.Rotation
+		err    error
+	}
+	resultC := make(chan waitResult, 1)
+	var phases []string
+	go func() {
+		states, err := s.a.RotateAndWait(context.TODO(), RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+		}, func(caType services.CertAuthType, rotation services.Rotation) {
+			phases = append(phases, rotation.Phase)
+		})
+		resultC <- waitResult{states: states, err: err}
+	}()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		select {
+		case result := <-resultC:
+			c.Assert(result.err, IsNil)
+			c.Assert(result.states, HasLen, 1)
+			rotation := result.states[services.HostCA]
+			c.Assert(rotation.State, Equals, services.RotationStateStandby)
+			c.Assert(rotation.LastRotated.IsZero(), Equals, false)
+			// the exact phases observed depend on the poll timing, but
+			// the rotation always ends on standby
+			c.Assert(len(phases) > 0, Equals, true)
+			c.Assert(phases[len(phases)-1], Equals, services.RotationPhaseStandby)
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			c.Fatalf("timed out waiting for RotateAndWait to settle")
+		}
+		// each advance crosses at least one schedule boundary and wakes
+		// the poll timer of the waiting helper
+		s.clock.Advance(gracePeriod + time.Second)
+		c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestRotateAndWaitAllTypes verifies that waiting on an implicit
+// rotation of all certificate authority types skips the optional types
+// the cluster does not have set up, e.g. the database one, instead of
+// failing the wait on the first poll.
+func (s *RotationSuite) TestRotateAndWaitAllTypes(c *C) {
+	gracePeriod := time.Hour
+	// the cluster has no database certificate authority
+	_, err := s.a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       services.DatabaseCA,
+		DomainName: s.a.clusterName.GetClusterName(),
+	}, false)
+	fixtures.ExpectNotFound(c, err)
+
+	type waitResult struct {
+		states map[services.CertAuthType]services.Rotation
+		err    error
+	}
+	resultC := make(chan waitResult, 1)
+	go func() {
+		states, err := s.a.RotateAndWait(context.TODO(), RotateRequest{
+			GracePeriod: &gracePeriod,
+		}, nil)
+		resultC <- waitResult{states: states, err: err}
+	}()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		select {
+		case result := <-resultC:
+			c.Assert(result.err, IsNil)
+			// only the types the cluster actually has are waited on
+			// and reported
+			c.Assert(result.states, HasLen, 2)
+			for _, caType := range []services.CertAuthType{services.HostCA, services.UserCA} {
+				rotation := result.states[caType]
+				c.Assert(rotation.State, Equals, services.RotationStateStandby, Commentf("type %v", caType))
+				c.Assert(rotation.LastRotated.IsZero(), Equals, false, Commentf("type %v", caType))
+			}
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			c.Fatalf("timed out waiting for RotateAndWait to settle")
+		}
+		s.clock.Advance(gracePeriod + time.Second)
+		c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// flappingTrust wraps the trust service and rewrites the rotation ID on
+// every read, so every compare-and-swap of a rotation transition fails
+// as if another writer kept changing the certificate authority.
+type flappingTrust struct {
+	services.Trust
+	reads int
+}
+
+func (t *flappingTrust) GetCertAuthority(id services.CertAuthID, loadSigningKeys bool) (services.CertAuthority, error) {
+	ca, err := t.Trust.GetCertAuthority(id, loadSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	rotation := ca.GetRotation()
+	t.reads++
+	rotation.CurrentID = fmt.Sprintf("flap-%v", t.reads)
+	ca.SetRotation(rotation)
+	return ca, nil
+}
+
+// TestAutoRotateFallbackToManual tests that automatic rotation switches
+// to manual mode and stops advancing when its transitions keep failing
+// on concurrent writes.
+func (s *RotationSuite) TestAutoRotateFallbackToManual(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// every read now disagrees with the previous one, so the retries of
+	// the next transition all fail their compare-and-swap
+	realTrust := s.a.Trust
+	s.a.Trust = &flappingTrust{Trust: realTrust}
+	s.clock.Advance(gracePeriod + time.Second)
+	err = s.a.autoRotateCertAuthorities(context.TODO())
+	c.Assert(err, NotNil)
+	c.Assert(trace.IsCompareFailed(err), Equals, true, Commentf("%v", err))
+	c.Assert(err, ErrorMatches, ".*switched to manual mode.*")
+	s.a.Trust = realTrust
+
+	// the rotation fell back to manual mode in place
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.Mode, Equals, services.RotationModeManual)
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// once manual, further ticks no longer advance the rotation
+	s.clock.Advance(gracePeriod)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+
+	// a human completes the rotation with explicit phase transitions
+	for _, phase := range []string{services.RotationPhaseUpdateServers, services.RotationPhaseStandby} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Assert(s.ca(c, services.HostCA).GetRotation().State, Equals, services.RotationStateStandby)
+}
+
+// TestRotationPercentComplete tests the rotation progress estimate
+// surfaced in the rotation status.
+func (s *RotationSuite) TestRotationPercentComplete(c *C) {
+	gracePeriod := time.Hour
+
+	status := func() RotationStatus {
+		statuses, err := s.a.GetRotationStatus(services.HostCA)
+		c.Assert(err, IsNil)
+		c.Assert(statuses, HasLen, 1)
+		return statuses[0]
+	}
+
+	// a certificate authority on standby is fully complete
+	c.Assert(status().PercentComplete, Equals, 100)
+
+	// a manual rotation advances in discrete steps per completed phase
+	expected := map[string]int{
+		services.RotationPhaseUpdateClients: 33,
+		services.RotationPhaseUpdateServers: 66,
+		services.RotationPhaseStandby:       100,
+	}
+	for _, phase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+		c.Assert(status().PercentComplete, Equals, expected[phase], Commentf("phase %q", phase))
+	}
+
+	// an automatic rotation progresses on the clock and exposes the
+	// absolute time of the next transition for an ETA display
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+	st := status()
+	c.Assert(st.PercentComplete, Equals, 0)
+	c.Assert(st.NextTransition, DeepEquals, st.Schedule.UpdateServers)
+	s.clock.Advance(gracePeriod / 2)
+	c.Assert(status().PercentComplete, Equals, 50)
+
+	// a rollback reports zero until it completes back on standby
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(status().PercentComplete, Equals, 0)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(status().PercentComplete, Equals, 100)
+}
+
+// TestRotationFromEmptyKeys tests that a rotation refuses to start from
+// a certificate authority without any existing keys instead of panicking
+// while building the overlap trust set.
+func (s *RotationSuite) TestRotationFromEmptyKeys(c *C) {
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.SetSigningKeys(nil), IsNil)
+	c.Assert(ca.SetCheckingKeys(nil), IsNil)
+	ca.SetTLSKeyPairs(nil)
+	// the guard fires before any new key material is generated, so the
+	// request does not need a key store
+	err := startNewRotation(rotationReq{
+		clock:       s.clock,
+		ca:          ca,
+		gracePeriod: time.Hour,
+		targetPhase: services.RotationPhaseUpdateClients,
+		mode:        services.RotationModeManual,
+	}, ca)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*no existing keys to rotate from.*")
+}
+
+// TestImportedKeyMaterial tests installing externally generated key
+// material via rotation instead of generating it on the auth server.
+func (s *RotationSuite) TestImportedKeyMaterial(c *C) {
+	gracePeriod := time.Hour
+
+	sshPriv, sshPub, err := s.a.Authority.GenerateKeyPair("")
+	c.Assert(err, IsNil)
+	tlsKey, tlsCert, err := tlsca.GenerateSelfSignedCA(pkix.Name{
+		CommonName:   "me.localhost",
+		Organization: []string{"me.localhost"},
+	}, nil, defaults.CATTL)
+	c.Assert(err, IsNil)
+
+	// a mismatched SSH key pair is rejected before the rotation starts
+	otherPriv, _, err := s.a.Authority.GenerateKeyPair("")
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		Mode:               services.RotationModeManual,
+		ImportedSSHKeyPair: &SSHKeyPair{PrivateKey: otherPriv, PublicKey: sshPub},
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*does not match.*")
+
+	// a TLS private key of a different certificate authority does not
+	// match the imported certificate
+	otherTLSKey, _, err := tlsca.GenerateSelfSignedCA(pkix.Name{
+		CommonName: "me.localhost",
+	}, nil, defaults.CATTL)
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		Mode:               services.RotationModeManual,
+		ImportedTLSKeyPair: &services.TLSKeyPair{Cert: tlsCert, Key: otherTLSKey},
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*does not match.*")
+
+	// the imported key material is installed verbatim as the new
+	// certificate authority
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		Mode:               services.RotationModeManual,
+		ImportedSSHKeyPair: &SSHKeyPair{PrivateKey: sshPriv, PublicKey: sshPub},
+		ImportedTLSKeyPair: &services.TLSKeyPair{Cert: tlsCert, Key: tlsKey},
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetSigningKeys()[0], DeepEquals, sshPriv)
+	c.Assert(ca.GetCheckingKeys()[0], DeepEquals, sshPub)
+	c.Assert(ca.GetTLSKeyPairs()[0], DeepEquals, services.TLSKeyPair{Cert: tlsCert, Key: tlsKey})
+
+	// imports only make sense when starting a rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &gracePeriod,
+		TargetPhase:        services.RotationPhaseUpdateServers,
+		Mode:               services.RotationModeManual,
+		ImportedSSHKeyPair: &SSHKeyPair{PrivateKey: sshPriv, PublicKey: sshPub},
+	})
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestCancelRotation tests canceling a rotation that is still in the
+// update_clients phase, restoring the exact pre-rotation trust set.
+func (s *RotationSuite) TestCancelRotation(c *C) {
+	gracePeriod := time.Hour
+
+	// there is nothing to cancel on standby
+	err := s.a.CancelRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+
+	original := s.ca(c, services.HostCA)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.ca(c, services.HostCA).GetCheckingKeys(), HasLen, 2)
+
+	c.Assert(s.a.CancelRotation(services.HostCA), IsNil)
+	restored := s.ca(c, services.HostCA)
+	rotation := restored.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseStandby)
+	// the surviving keys are byte for byte the pre-rotation ones
+	c.Assert(restored.GetSigningKeys(), DeepEquals, original.GetSigningKeys())
+	c.Assert(restored.GetCheckingKeys(), DeepEquals, original.GetCheckingKeys())
+	c.Assert(restored.GetTLSKeyPairs(), DeepEquals, original.GetTLSKeyPairs())
+
+	// once the rotation has reached update_servers the new certificate
+	// authority is in active use and only rollback is available
+	for _, phase := range []string{services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers} {
+		err := s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: phase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	err = s.a.CancelRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*use rollback instead.*")
+}
+
+// TestRotationWebhook tests the HTTP callout fired when a rotation
+// completes or finishes rolling back.
+func (s *RotationSuite) TestRotationWebhook(c *C) {
+	gracePeriod := time.Hour
+
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	deliveries := make(chan delivery, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		deliveries <- delivery{
+			body:      body,
+			signature: r.Header.Get(RotationWebhookSignatureHeader),
+		}
+	}))
+	defer server.Close()
+	s.a.SetRotationWebhook(&RotationWebhookConfig{
+		URL:    server.URL,
+		Secret: "webhook-secret",
+	})
+
+	receive := func() delivery {
+		select {
+		case d := <-deliveries:
+			return d
+		case <-time.After(10 * time.Second):
+			c.Fatalf("timed out waiting for the webhook delivery")
+		}
+		return delivery{}
+	}
+	rotate := func(phases ...string) {
+		for _, phase := range phases {
+			err := s.a.RotateCertAuthority(RotateRequest{
+				Type:        services.HostCA,
+				GracePeriod: &gracePeriod,
+				TargetPhase: phase,
+				Mode:        services.RotationModeManual,
+			})
+			c.Assert(err, IsNil)
+		}
+	}
+
+	// a completed rotation reports "completed" with a signed payload
+	rotate(services.RotationPhaseUpdateClients, services.RotationPhaseUpdateServers, services.RotationPhaseStandby)
+	d := receive()
+	var payload rotationWebhookPayload
+	c.Assert(json.Unmarshal(d.body, &payload), IsNil)
+	c.Assert(payload.Result, Equals, rotationWebhookCompleted)
+	c.Assert(payload.Type, Equals, string(services.HostCA))
+	c.Assert(payload.ClusterName, Equals, "me.localhost")
+	c.Assert(payload.RotationID, Not(Equals), "")
+	c.Assert(payload.LastRotated.IsZero(), Equals, false)
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(d.body)
+	c.Assert(d.signature, Equals, hex.EncodeToString(mac.Sum(nil)))
+
+	// a rolled back rotation reports "rolled_back"
+	rotate(services.RotationPhaseUpdateClients, services.RotationPhaseRollback, services.RotationPhaseStandby)
+	d = receive()
+	c.Assert(json.Unmarshal(d.body, &payload), IsNil)
+	c.Assert(payload.Result, Equals, rotationWebhookRolledBack)
+
+	// the intermediate phase transitions did not fire the webhook
+	c.Assert(deliveries, HasLen, 0)
+}
+
+// TestMinPhaseDuration verifies that schedule generation and rotation
+// request validation reject grace periods that would spread the phases
+// thinner than the minimum phase duration, while a zero grace period
+// keeps its special meaning of forcing the rotation through in one step.
+func (s *RotationSuite) TestMinPhaseDuration(c *C) {
+	// exactly three minimum phases is the shortest accepted grace period
+	minGrace := 3 * defaults.MinPhaseDuration
+	schedule, err := services.GenerateSchedule(s.clock, minGrace)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, s.clock.Now().UTC().Add(defaults.MinPhaseDuration))
+	c.Assert(schedule.Standby, Equals, s.clock.Now().UTC().Add(minGrace))
+
+	// just below the boundary the schedule is rejected
+	_, err = services.GenerateSchedule(s.clock, minGrace-time.Nanosecond)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*shorter than the minimum.*")
+
+	// request validation rejects short grace periods before anything starts
+	shortGrace := minGrace - time.Second
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &shortGrace,
+		Mode:        services.RotationModeAuto,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*shorter than the minimum.*")
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Not(Equals), services.RotationStateInProgress)
+
+	// the force path is unaffected by the minimum
+	zeroGracePeriod := time.Duration(0)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:             services.HostCA,
+		GracePeriod:      &zeroGracePeriod,
+		AcknowledgeForce: true,
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().State, Equals, services.RotationStateStandby)
+	c.Assert(ca.GetRotation().LastRotated.IsZero(), Equals, false)
+}
+
+// TestRotationKeyOrderGuard verifies that rollback, cancel and completion
+// refuse to touch a certificate authority whose key ordering convention
+// has been violated by an external write, instead of silently keeping the
+// wrong key.
+func (s *RotationSuite) TestRotationKeyOrderGuard(c *C) {
+	gracePeriod := time.Hour
+
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+
+	// an external write swaps the signing keys without touching the
+	// checking keys, leaving the old key in the active slot
+	ca := s.ca(c, services.HostCA)
+	signingKeys := ca.GetSigningKeys()
+	err = ca.SetSigningKeys([][]byte{signingKeys[1], signingKeys[0]})
+	c.Assert(err, IsNil)
+	c.Assert(s.a.Trust.UpsertCertAuthority(ca), IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*active signing key does not match.*")
+
+	err = s.a.CancelRotation(services.HostCA)
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*active signing key does not match.*")
+
+	// the refused transitions left the certificate authority untouched
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(ca.GetSigningKeys(), HasLen, 2)
+
+	// restore the signing key order and corrupt the TLS side instead,
+	// as if a generation retained with its certificate only had been
+	// moved to the active slot
+	signingKeys = ca.GetSigningKeys()
+	err = ca.SetSigningKeys([][]byte{signingKeys[1], signingKeys[0]})
+	c.Assert(err, IsNil)
+	keyPairs := ca.GetTLSKeyPairs()
+	keyPairs[0].Key = nil
+	ca.SetTLSKeyPairs(keyPairs)
+	c.Assert(s.a.Trust.UpsertCertAuthority(ca), IsNil)
+
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*has no private key.*")
+
+	// completion is guarded the same way
+	err = completeRotation(s.clock, s.ca(c, services.HostCA))
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*has no private key.*")
+}
+
+// countingTrust wraps the trust service and counts certificate authority
+// reads, split by whether the private keys were requested.
+type countingTrust struct {
+	services.Trust
+	keyReads  int
+	bareReads int
+	upserts   int
+}
+
+func (t *countingTrust) GetCertAuthority(id services.CertAuthID, loadSigningKeys bool) (services.CertAuthority, error) {
+	if loadSigningKeys {
+		t.keyReads++
+	} else {
+		t.bareReads++
+	}
+	return t.Trust.GetCertAuthority(id, loadSigningKeys)
+}
+
+func (t *countingTrust) UpsertCertAuthority(ca services.CertAuthority) error {
+	t.upserts++
+	return t.Trust.UpsertCertAuthority(ca)
+}
+
+// TestAutoRotateKeyLoading verifies that the periodic rotation checks do
+// not load certificate authority private keys unless a transition is
+// actually due.
+func (s *RotationSuite) TestAutoRotateKeyLoading(c *C) {
+	realTrust := s.a.Trust
+	counting := &countingTrust{Trust: realTrust}
+	s.a.Trust = counting
+	defer func() { s.a.Trust = realTrust }()
+
+	// an idle tick with no rotation in progress stays on keyless reads
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(counting.keyReads, Equals, 0)
+	c.Assert(counting.bareReads, Not(Equals), 0)
+
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+
+	// ticks before the schedule boundary do not load the keys either
+	counting.keyReads, counting.bareReads = 0, 0
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(counting.keyReads, Equals, 0)
+
+	// once the boundary passes the keys are loaded and the phase advances
+	counting.keyReads, counting.bareReads = 0, 0
+	s.clock.Advance(gracePeriod/3 + time.Second)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(counting.keyReads, Not(Equals), 0)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Phase, Equals, services.RotationPhaseUpdateServers)
+}
+
+// TestMaintenanceWindows tests snapping the generated rotation schedule
+// to recurring maintenance windows.
+func (s *RotationSuite) TestMaintenanceWindows(c *C) {
+	// anchor on a known Wednesday noon for deterministic snapping
+	clock := clockwork.NewFakeClockAt(time.Date(2018, 3, 7, 12, 0, 0, 0, time.UTC))
+	gracePeriod := 3 * time.Hour
+
+	// invalid windows are rejected
+	for _, window := range []services.MaintenanceWindow{
+		{StartHour: -1, EndHour: 5},
+		{StartHour: 5, EndHour: 25},
+		{StartHour: 5, EndHour: 5},
+		{StartHour: 1, EndHour: 2, Days: []time.Weekday{time.Weekday(9)}},
+		{StartHour: 1, EndHour: 2, Timezone: "Pluto/Cydonia"},
+	} {
+		_, err := services.GenerateScheduleInWindows(clock, gracePeriod, []services.MaintenanceWindow{window})
+		fixtures.ExpectBadParameter(c, err)
+	}
+	_, err := services.GenerateScheduleInWindows(clock, gracePeriod, nil)
+	fixtures.ExpectBadParameter(c, err)
+
+	// boundaries already inside an open window stay where they are
+	schedule, err := services.GenerateScheduleInWindows(clock, gracePeriod, []services.MaintenanceWindow{
+		{StartHour: 12, EndHour: 16},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, clock.Now().UTC().Add(gracePeriod/3))
+	c.Assert(schedule.Standby, Equals, clock.Now().UTC().Add(gracePeriod))
+
+	// boundaries outside the window snap forward to its next opening,
+	// keeping the phases in order even when both land past the original
+	// completion time
+	schedule, err = services.GenerateScheduleInWindows(clock, gracePeriod, []services.MaintenanceWindow{
+		{StartHour: 20, EndHour: 22},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, time.Date(2018, 3, 7, 20, 0, 0, 0, time.UTC))
+	c.Assert(schedule.Standby, Equals, time.Date(2018, 3, 7, 21, 0, 0, 0, time.UTC))
+	c.Assert(schedule.UpdateServers.Before(schedule.Standby), Equals, true)
+
+	// day of the week restrictions can push the boundary several days out
+	schedule, err = services.GenerateScheduleInWindows(clock, gracePeriod, []services.MaintenanceWindow{
+		{Days: []time.Weekday{time.Saturday}, StartHour: 1, EndHour: 4},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, time.Date(2018, 3, 10, 1, 0, 0, 0, time.UTC))
+	c.Assert(schedule.Standby, Equals, time.Date(2018, 3, 10, 2, 0, 0, 0, time.UTC))
+
+	// window hours are interpreted in the configured timezone,
+	// 20:00-22:00 in New York is 01:00-03:00 UTC the next day in March
+	schedule, err = services.GenerateScheduleInWindows(clock, gracePeriod, []services.MaintenanceWindow{
+		{StartHour: 20, EndHour: 22, Timezone: "America/New_York"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, time.Date(2018, 3, 8, 1, 0, 0, 0, time.UTC))
+
+	// maintenance windows shape the generated schedule only, they
+	// conflict with an explicit schedule and with manual mode
+	hour := time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &hour,
+		Mode:               services.RotationModeAuto,
+		MaintenanceWindows: []services.MaintenanceWindow{{StartHour: 0, EndHour: 24}},
+		ScheduleOffsets:    &services.RotationScheduleOffsets{UpdateServers: 10 * time.Minute, Standby: 30 * time.Minute},
+	})
+	fixtures.ExpectBadParameter(c, err)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &hour,
+		Mode:               services.RotationModeManual,
+		TargetPhase:        services.RotationPhaseUpdateClients,
+		MaintenanceWindows: []services.MaintenanceWindow{{StartHour: 0, EndHour: 24}},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// an always-open window leaves the generated schedule untouched
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:               services.HostCA,
+		GracePeriod:        &hour,
+		Mode:               services.RotationModeAuto,
+		MaintenanceWindows: []services.MaintenanceWindow{{StartHour: 0, EndHour: 24}},
+	})
+	c.Assert(err, IsNil)
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.Schedule.UpdateServers, Equals, s.clock.Now().UTC().Add(hour/3))
+	c.Assert(rotation.Schedule.Standby, Equals, s.clock.Now().UTC().Add(hour))
+}
+
+// TestKeepOldTrustOnForce tests that a forced rotation can keep the old
+// checking keys and certificates trusted, without the private keys, so
+// verification of previously issued credentials does not break instantly.
+func (s *RotationSuite) TestKeepOldTrustOnForce(c *C) {
+	// the flag has no meaning for graceful rotation
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:                services.HostCA,
+		GracePeriod:         &gracePeriod,
+		KeepOldTrustOnForce: true,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	oldCA := s.ca(c, services.HostCA)
+	zeroGracePeriod := time.Duration(0)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:                services.HostCA,
+		GracePeriod:         &zeroGracePeriod,
+		AcknowledgeForce:    true,
+		KeepOldTrustOnForce: true,
+	})
+	c.Assert(err, IsNil)
+
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateStandby)
+	// the lingering old trust counts as a retained generation
+	c.Assert(rotation.RetainGenerations, Equals, 2)
+
+	// the new key is the only signer
+	c.Assert(ca.GetSigningKeys(), HasLen, 1)
+	c.Assert(string(ca.GetSigningKeys()[0]), Not(Equals), string(oldCA.GetSigningKeys()[0]))
+
+	// the old checking key and certificate stay trusted, the old
+	// private keys are gone
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+	c.Assert(string(ca.GetCheckingKeys()[1]), Equals, string(oldCA.GetCheckingKeys()[0]))
+	keyPairs := ca.GetTLSKeyPairs()
+	c.Assert(keyPairs, HasLen, 2)
+	c.Assert(keyPairs[1].Cert, DeepEquals, oldCA.GetTLSKeyPairs()[0].Cert)
+	c.Assert(keyPairs[1].Key, HasLen, 0)
+
+	// the next full rotation trims the lingering old trust
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetCheckingKeys(), HasLen, 1)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+}
+
+// TestEffectiveGracePeriod pins the tri-state semantics of the grace
+// period pointer: nil means the default, an explicit zero forces the
+// rotation and any other value is used as is.
+func (s *RotationSuite) TestEffectiveGracePeriod(c *C) {
+	req := RotateRequest{}
+	period, isForce := req.EffectiveGracePeriod()
+	c.Assert(period, Equals, defaults.RotationGracePeriod)
+	c.Assert(isForce, Equals, false)
+
+	zero := time.Duration(0)
+	req = RotateRequest{GracePeriod: &zero}
+	period, isForce = req.EffectiveGracePeriod()
+	c.Assert(period, Equals, time.Duration(0))
+	c.Assert(isForce, Equals, true)
+
+	hour := time.Hour
+	req = RotateRequest{GracePeriod: &hour}
+	period, isForce = req.EffectiveGracePeriod()
+	c.Assert(period, Equals, time.Hour)
+	c.Assert(isForce, Equals, false)
+
+	// a request without a grace period rotates gracefully with the
+	// default period, it never turns into a forced rotation
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type: services.HostCA,
+		Mode: services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotation.GracePeriod.Duration, Equals, defaults.RotationGracePeriod)
+
+	// an explicit zero is refused without the acknowledgement instead
+	// of silently forcing
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &zero,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(s.ca(c, services.UserCA).GetRotation().CurrentID, Equals, "")
+}
+
+// TestIsTrustedByCA tests querying whether a certificate is still trusted
+// by the current trust set of the certificate authority, including which
+// generation it matched during the rotation overlap.
+func (s *RotationSuite) TestIsTrustedByCA(c *C) {
+	gracePeriod := time.Hour
+	oldCA := s.ca(c, services.HostCA)
+
+	// issue an SSH and a TLS certificate with the pre-rotation keys
+	_, pub, err := s.a.Authority.GenerateKeyPair("")
+	c.Assert(err, IsNil)
+	sshCert, err := s.a.Authority.GenerateHostCert(services.HostCertParams{
+		PrivateCASigningKey: oldCA.GetSigningKeys()[0],
+		PublicHostKey:       pub,
+		HostID:              "node-1",
+		NodeName:            "node-1",
+		ClusterName:         "me.localhost",
+		Roles:               teleport.Roles{teleport.RoleNode},
+		TTL:                 time.Hour,
+	})
+	c.Assert(err, IsNil)
+	oldPair := oldCA.GetTLSKeyPairs()[0]
+	tlsAuthority, err := tlsca.New(oldPair.Cert, oldPair.Key)
+	c.Assert(err, IsNil)
+	privateKeyPEM, err := tlsca.GenerateRSAPrivateKeyPEM()
+	c.Assert(err, IsNil)
+	signer, err := tlsca.ParsePrivateKeyPEM(privateKeyPEM)
+	c.Assert(err, IsNil)
+	identity := tlsca.Identity{Username: "node-1", Groups: []string{"nodes"}}
+	tlsCert, err := tlsAuthority.GenerateCertificate(tlsca.CertificateRequest{
+		Clock:     clockwork.NewRealClock(),
+		PublicKey: signer.Public(),
+		Subject:   identity.Subject(),
+		NotAfter:  time.Now().UTC().Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	// before the rotation both certificates match the active generation
+	generation, trusted, err := IsSSHCertTrustedByCA(oldCA, sshCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, true)
+	c.Assert(generation, Equals, 0)
+	generation, trusted, err = IsCertTrustedByCA(oldCA, tlsCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, true)
+	c.Assert(generation, Equals, 0)
+
+	// during the rotation overlap the old credentials match the old
+	// generation at position 1
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	generation, trusted, err = IsSSHCertTrustedByCA(ca, sshCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, true)
+	c.Assert(generation, Equals, 1)
+	generation, trusted, err = IsCertTrustedByCA(ca, tlsCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, true)
+	c.Assert(generation, Equals, 1)
+
+	// a plain public key is not an SSH certificate
+	_, _, err = IsSSHCertTrustedByCA(ca, pub)
+	fixtures.ExpectBadParameter(c, err)
+
+	// completing the rotation drops the old generation from the trusted set
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil)
+	}
+	ca = s.ca(c, services.HostCA)
+	_, trusted, err = IsSSHCertTrustedByCA(ca, sshCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, false)
+	_, trusted, err = IsCertTrustedByCA(ca, tlsCert)
+	c.Assert(err, IsNil)
+	c.Assert(trusted, Equals, false)
+}
+
+// TestPhaseTimes tests that the rotation records the time it entered
+// every phase and that the record survives until the next rotation
+// resets it.
+func (s *RotationSuite) TestPhaseTimes(c *C) {
+	gracePeriod := time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	startedAt := s.clock.Now().UTC()
+
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.PhaseTimes, HasLen, 1)
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseUpdateClients], Equals, startedAt)
+
+	// retrying the same phase does not overwrite the recorded time
+	s.clock.Advance(10 * time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseUpdateClients], Equals, startedAt)
+
+	// each later phase is stamped with the time it was entered
+	s.clock.Advance(50 * time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	serversAt := s.clock.Now().UTC()
+
+	s.clock.Advance(time.Hour)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseStandby,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	completedAt := s.clock.Now().UTC()
+
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.PhaseTimes, HasLen, 3)
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseUpdateClients], Equals, startedAt)
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseUpdateServers], Equals, serversAt)
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseStandby], Equals, completedAt)
+
+	// the phase times show up in the rotation status
+	status, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status[0].PhaseTimes, DeepEquals, rotation.PhaseTimes)
+
+	// a rollback is stamped too
+	s.clock.Advance(time.Hour)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	s.clock.Advance(10 * time.Minute)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	rolledBackAt := s.clock.Now().UTC()
+	rotation = s.ca(c, services.HostCA).GetRotation()
+	// the new rotation started with a clean record
+	c.Assert(rotation.PhaseTimes, HasLen, 2)
+	c.Assert(rotation.PhaseTimes[services.RotationPhaseRollback], Equals, rolledBackAt)
+}
+
+// TestPreviewRotationPlan tests the rotation plan preview against the
+// schedule an actually started rotation ends up with.
+func (s *RotationSuite) TestPreviewRotationPlan(c *C) {
+	gracePeriod := 6 * time.Hour
+	now := s.clock.Now().UTC()
+
+	plan, err := PreviewRotationPlan(s.clock, gracePeriod, services.RotationModeAuto, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, DeepEquals, []RotationPlanEntry{
+		{Phase: services.RotationPhaseUpdateClients, Time: now},
+		{Phase: services.RotationPhaseUpdateServers, Time: now.Add(gracePeriod / 3)},
+		{Phase: services.RotationPhaseStandby, Time: now.Add(gracePeriod)},
+	})
+
+	// the previewed times match the schedule of the started rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+	})
+	c.Assert(err, IsNil)
+	rotation := s.ca(c, services.HostCA).GetRotation()
+	c.Assert(rotation.Schedule.UpdateServers, Equals, plan[1].Time)
+	c.Assert(rotation.Schedule.Standby, Equals, plan[2].Time)
+
+	// a forced rotation completes in one step
+	plan, err = PreviewRotationPlan(s.clock, 0, services.RotationModeManual, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, DeepEquals, []RotationPlanEntry{
+		{Phase: services.RotationPhaseStandby, Time: now},
+	})
+
+	// an explicit schedule is previewed as given
+	schedule := &services.RotationSchedule{
+		UpdateServers: now.Add(time.Hour),
+		Standby:       now.Add(2 * time.Hour),
+	}
+	plan, err = PreviewRotationPlan(s.clock, gracePeriod, services.RotationModeAuto, schedule)
+	c.Assert(err, IsNil)
+	c.Assert(plan[1].Time, Equals, schedule.UpdateServers)
+	c.Assert(plan[2].Time, Equals, schedule.Standby)
+
+	// invalid requests are rejected the same way starting them would be
+	_, err = PreviewRotationPlan(s.clock, gracePeriod, services.RotationModeManual, schedule)
+	fixtures.ExpectBadParameter(c, err)
+	_, err = PreviewRotationPlan(s.clock, -time.Hour, services.RotationModeAuto, nil)
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestPinnedCerts tests pinning an old TLS certificate so it stays
+// trusted past the completion of the rotation until it is unpinned.
+func (s *RotationSuite) TestPinnedCerts(c *C) {
+	gracePeriod := time.Hour
+	oldCA := s.ca(c, services.HostCA)
+	oldCert := oldCA.GetTLSKeyPairs()[0].Cert
+	fingerprint, err := tlsCertFingerprint(oldCert)
+	c.Assert(err, IsNil)
+
+	// a fingerprint with no matching certificate is refused
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		PinnedCerts: []string{"sha256:0000"},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// pins can only be set when starting a rotation
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		PinnedCerts: []string{fingerprint},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// rotate all the way through with the old certificate pinned
+	for i, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		req := RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		}
+		if i == 0 {
+			req.PinnedCerts = []string{fingerprint}
+		}
+		err = s.a.RotateCertAuthority(req)
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+
+	// the pinned certificate stays trusted past standby, certificate only
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().PinnedCerts, DeepEquals, []string{fingerprint})
+	keyPairs := ca.GetTLSKeyPairs()
+	c.Assert(keyPairs, HasLen, 2)
+	c.Assert(keyPairs[1].Cert, DeepEquals, oldCert)
+	c.Assert(keyPairs[1].Key, HasLen, 0)
+
+	// the pin survives another full rotation
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+	ca = s.ca(c, services.HostCA)
+	keyPairs = ca.GetTLSKeyPairs()
+	c.Assert(keyPairs, HasLen, 2)
+	c.Assert(keyPairs[1].Cert, DeepEquals, oldCert)
+
+	// unpinning an unknown fingerprint is an error
+	fixtures.ExpectNotFound(c, s.a.UnpinCert(services.HostCA, "sha256:0000"))
+
+	// unpinning drops the certificate-only entry
+	c.Assert(s.a.UnpinCert(services.HostCA, fingerprint), IsNil)
+	ca = s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().PinnedCerts, HasLen, 0)
+	c.Assert(ca.GetTLSKeyPairs(), HasLen, 1)
+}
+
+// TestAutoRotateEmptyRotation verifies the automatic rotation checks
+// ignore a freshly initialized certificate authority carrying a
+// zero-valued rotation without error and without writing anything to
+// the backend.
+func (s *RotationSuite) TestAutoRotateEmptyRotation(c *C) {
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation(), DeepEquals, services.Rotation{})
+
+	realTrust := s.a.Trust
+	counting := &countingTrust{Trust: realTrust}
+	s.a.Trust = counting
+	defer func() { s.a.Trust = realTrust }()
+
+	c.Assert(s.a.pendingRotationTransition(services.HostCA, ca.GetRotation()), Equals, false)
+	c.Assert(s.a.autoRotate(ca), IsNil)
+	c.Assert(s.a.autoRotateCertAuthorities(context.TODO()), IsNil)
+	c.Assert(counting.upserts, Equals, 0)
+
+	// the certificate authority is untouched
+	c.Assert(s.ca(c, services.HostCA).GetRotation(), DeepEquals, services.Rotation{})
+}
+
+// TestTypeOffset tests staggering the update_servers transition of the
+// certificate authority types rotated together, so their server reload
+// waves do not coincide.
+func (s *RotationSuite) TestTypeOffset(c *C) {
+	gracePeriod := 6 * time.Hour
+	offsets := map[services.CertAuthType]time.Duration{
+		services.UserCA: time.Hour,
+	}
+
+	// offsets shape the per-type schedule and only make sense when
+	// rotating all types automatically
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		TypeOffset:  offsets,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeManual,
+		TypeOffset:  offsets,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		TypeOffset:  map[services.CertAuthType]time.Duration{services.UserCA: -time.Hour},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	// an offset pushing update_servers past the end of the grace period
+	// leaves no room for the update_servers phase
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		TypeOffset:  map[services.CertAuthType]time.Duration{services.UserCA: 4 * time.Hour},
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	now := s.clock.Now().UTC()
+	err = s.a.RotateCertAuthority(RotateRequest{
+		GracePeriod: &gracePeriod,
+		Mode:        services.RotationModeAuto,
+		TypeOffset:  offsets,
+	})
+	c.Assert(err, IsNil)
+
+	// the user certificate authority flips servers an hour after the
+	// host one, both rotations complete at the end of the grace period
+	hostRotation := s.ca(c, services.HostCA).GetRotation()
+	userRotation := s.ca(c, services.UserCA).GetRotation()
+	c.Assert(hostRotation.Schedule.UpdateServers, Equals, now.Add(gracePeriod/3))
+	c.Assert(userRotation.Schedule.UpdateServers, Equals, now.Add(gracePeriod/3+time.Hour))
+	c.Assert(hostRotation.Schedule.Standby, Equals, now.Add(gracePeriod))
+	c.Assert(userRotation.Schedule.Standby, Equals, now.Add(gracePeriod))
+	c.Assert(userRotation.Schedule.UpdateServers.Before(userRotation.Schedule.Standby), Equals, true)
+}
+
+// TestShortGracePeriodWarning tests that starting a rotation with a grace
+// period shorter than the expected node reconnect time emits a warning
+// event without blocking the rotation.
+func (s *RotationSuite) TestShortGracePeriodWarning(c *C) {
+	alog := &testAuditLog{IAuditLog: s.a.IAuditLog}
+	s.a.IAuditLog = alog
+	s.a.SetExpectedReconnectDuration(2 * time.Hour)
+
+	warnings := func() []testAuditEvent {
+		out := []testAuditEvent{}
+		for _, event := range alog.emitted {
+			if event.eventType == events.CertAuthorityRotationWarningEvent {
+				out = append(out, event)
+			}
+		}
+		return out
+	}
+
+	// a grace period covering the reconnect window does not warn
+	gracePeriod := 3 * time.Hour
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(warnings(), HasLen, 0)
+
+	// a short grace period warns but the rotation still proceeds
+	shortGracePeriod := time.Hour
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &shortGracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	emitted := warnings()
+	c.Assert(emitted, HasLen, 1)
+	c.Assert(emitted[0].fields.GetString(events.RotationCertType), Equals, string(services.UserCA))
+	c.Assert(emitted[0].fields.GetString(events.RotationGracePeriod), Equals, shortGracePeriod.String())
+	c.Assert(emitted[0].fields.GetString(events.RotationWarning), Matches, ".*shorter than the expected node reconnect time.*")
+	rotation := s.ca(c, services.UserCA).GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+
+	// later phase transitions of the short rotation do not warn again
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &shortGracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(warnings(), HasLen, 1)
+}
+
+// TestRestartRotation tests abandoning an in-progress rotation and
+// starting a fresh one with new keys in a single operation.
+func (s *RotationSuite) TestRestartRotation(c *C) {
+	gracePeriod := time.Hour
+	restartReq := RotateRequest{GracePeriod: &gracePeriod, Mode: services.RotationModeManual}
+
+	// only an in-progress rotation can be restarted
+	err := s.a.RestartRotation(services.HostCA, restartReq)
+	fixtures.ExpectBadParameter(c, err)
+
+	// the request must not target a different certificate authority type
+	err = s.a.RestartRotation(services.HostCA, RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+	})
+	fixtures.ExpectBadParameter(c, err)
+
+	preRotationCA := s.ca(c, services.HostCA)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	abandonedCA := s.ca(c, services.HostCA)
+	abandonedID := abandonedCA.GetRotation().CurrentID
+
+	alog := &testAuditLog{IAuditLog: s.a.IAuditLog}
+	s.a.IAuditLog = alog
+	c.Assert(s.a.RestartRotation(services.HostCA, restartReq), IsNil)
+
+	// the restarted rotation is in progress under a brand-new ID with
+	// freshly generated keys, the abandoned keys are gone and the
+	// pre-rotation certificate authority is the trusted old generation
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotation.CurrentID, Not(Equals), abandonedID)
+	c.Assert(string(ca.GetSigningKeys()[0]), Not(Equals), string(abandonedCA.GetSigningKeys()[0]))
+	c.Assert(ca.GetCheckingKeys(), HasLen, 2)
+	c.Assert(string(ca.GetCheckingKeys()[1]), Equals, string(preRotationCA.GetCheckingKeys()[0]))
+
+	// both the abandonment and the restart left an audit trail
+	rotationEvents := []testAuditEvent{}
+	for _, event := range alog.emitted {
+		if event.eventType == events.CertAuthorityRotationEvent {
+			rotationEvents = append(rotationEvents, event)
+		}
+	}
+	c.Assert(rotationEvents, HasLen, 2)
+	c.Assert(rotationEvents[0].fields.GetString(events.RotationOldPhase), Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotationEvents[0].fields.GetString(events.RotationNewPhase), Equals, services.RotationPhaseStandby)
+	c.Assert(rotationEvents[1].fields.GetString(events.RotationOldPhase), Equals, services.RotationPhaseStandby)
+	c.Assert(rotationEvents[1].fields.GetString(events.RotationNewPhase), Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotationEvents[1].fields.GetString(events.RotationID), Equals, rotation.CurrentID)
+
+	// a rotation already rolling back has to complete the rollback
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RestartRotation(services.HostCA, restartReq)
+	fixtures.ExpectBadParameter(c, err)
+}
+
+// TestRollbackFromStandby tests that requesting a rollback while no
+// rotation is in progress is rejected with a clear message, both on a
+// never-rotated certificate authority and after a completed rotation.
+func (s *RotationSuite) TestRollbackFromStandby(c *C) {
+	gracePeriod := time.Hour
+	rollbackReq := RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseRollback,
+		Mode:        services.RotationModeManual,
+	}
+
+	// a never-rotated certificate authority carries an empty phase
+	err := s.a.RotateCertAuthority(rollbackReq)
+	c.Assert(err, NotNil)
+	c.Assert(err, ErrorMatches, ".*can not roll back: no rotation is in progress.*")
+
+	// same after a completed rotation back in standby
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateClients,
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+	err = s.a.RotateCertAuthority(rollbackReq)
+	c.Assert(err, NotNil)
+	c.Assert(err, ErrorMatches, ".*can not roll back: no rotation is in progress.*")
+}
+
+// TestKeyComment tests that the public key of a newly rotated SSH key
+// pair carries a comment identifying the cluster and the rotation, or a
+// caller supplied one.
+func (s *RotationSuite) TestKeyComment(c *C) {
+	gracePeriod := time.Hour
+
+	// the default comment names the cluster and the rotation ID
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	rotation := ca.GetRotation()
+	comment := fmt.Sprintf("%v-rotation-%v", ca.GetClusterName(), rotation.CurrentID)
+	c.Assert(strings.TrimRight(string(ca.GetCheckingKeys()[0]), "\n"), Matches, ".* "+comment)
+
+	// the commented key still parses in the authorized-keys format and
+	// matches the private key
+	pub, commentOut, _, _, err := ssh.ParseAuthorizedKey(ca.GetCheckingKeys()[0])
+	c.Assert(err, IsNil)
+	c.Assert(commentOut, Equals, comment)
+	signer, err := ssh.ParsePrivateKey(ca.GetSigningKeys()[0])
+	c.Assert(err, IsNil)
+	c.Assert(signer.PublicKey().Marshal(), DeepEquals, pub.Marshal())
+
+	// a caller supplied comment overrides the default
+	for _, targetPhase := range []string{
+		services.RotationPhaseRollback,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		KeyComment:  "CHANGE-1234",
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.HostCA)
+	_, commentOut, _, _, err = ssh.ParseAuthorizedKey(ca.GetCheckingKeys()[0])
+	c.Assert(err, IsNil)
+	c.Assert(commentOut, Equals, "CHANGE-1234")
+
+	// multi-line comments would corrupt the authorized-keys format
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateServers,
+		Mode:        services.RotationModeManual,
+		KeyComment:  "line one\nline two",
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*single line.*")
+}
+
+// TestListRotatingCertAuthorities tests listing the certificate
+// authorities whose rotation is currently in progress.
+func (s *RotationSuite) TestListRotatingCertAuthorities(c *C) {
+	gracePeriod := time.Hour
+
+	// everything starts on standby, the list is empty but not nil
+	cas, err := s.a.ListRotatingCertAuthorities()
+	c.Assert(err, IsNil)
+	c.Assert(cas, NotNil)
+	c.Assert(cas, HasLen, 0)
+
+	// a started rotation shows up with its phase and mode
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	cas, err = s.a.ListRotatingCertAuthorities()
+	c.Assert(err, IsNil)
+	c.Assert(cas, HasLen, 1)
+	c.Assert(cas[0].GetType(), Equals, services.HostCA)
+	rotation := cas[0].GetRotation()
+	c.Assert(rotation.State, Equals, services.RotationStateInProgress)
+	c.Assert(rotation.Phase, Equals, services.RotationPhaseUpdateClients)
+	c.Assert(rotation.Mode, Equals, services.RotationModeManual)
+	// the list is loaded without private keys
+	c.Assert(cas[0].GetSigningKeys(), HasLen, 0)
+
+	// both certificate authorities are listed when both are rotating
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	c.Assert(err, IsNil)
+	cas, err = s.a.ListRotatingCertAuthorities()
+	c.Assert(err, IsNil)
+	c.Assert(cas, HasLen, 2)
+
+	// completed rotations drop off the list
+	for _, caType := range []services.CertAuthType{services.HostCA, services.UserCA} {
+		for _, targetPhase := range []string{
+			services.RotationPhaseUpdateServers,
+			services.RotationPhaseStandby,
+		} {
+			err = s.a.RotateCertAuthority(RotateRequest{
+				Type:        caType,
+				GracePeriod: &gracePeriod,
+				TargetPhase: targetPhase,
+				Mode:        services.RotationModeManual,
+			})
+			c.Assert(err, IsNil, Commentf("type %v phase %v", caType, targetPhase))
+		}
+	}
+	cas, err = s.a.ListRotatingCertAuthorities()
+	c.Assert(err, IsNil)
+	c.Assert(cas, HasLen, 0)
+}
+
+// TestRotationReason tests that the operator supplied rotation reason is
+// persisted on the rotation object, surfaced in the rotation status and
+// audit events throughout the lifecycle, and can be made mandatory by
+// the rotation policy.
+func (s *RotationSuite) TestRotationReason(c *C) {
+	gracePeriod := time.Hour
+	alog := &testAuditLog{IAuditLog: s.a.IAuditLog}
+	s.a.IAuditLog = alog
+
+	// an overlong reason is rejected
+	err := s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Reason:      strings.Repeat("r", maxRotationReasonLength+1),
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*exceeds the maximum.*")
+
+	// the reason is recorded on the rotation object and in the status
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.HostCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Initiator:   "admin",
+		Reason:      "ops-1234",
+	})
+	c.Assert(err, IsNil)
+	ca := s.ca(c, services.HostCA)
+	c.Assert(ca.GetRotation().Reason, Equals, "ops-1234")
+	statuses, err := s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].Reason, Equals, "ops-1234")
+	c.Assert(alog.emitted, HasLen, 1)
+	c.Assert(alog.emitted[0].fields.GetString(events.RotationReason), Equals, "ops-1234")
+
+	// later phase transitions carry the reason the rotation was
+	// started with, through audit events and all the way to standby
+	for _, targetPhase := range []string{
+		services.RotationPhaseUpdateServers,
+		services.RotationPhaseStandby,
+	} {
+		err = s.a.RotateCertAuthority(RotateRequest{
+			Type:        services.HostCA,
+			GracePeriod: &gracePeriod,
+			TargetPhase: targetPhase,
+			Mode:        services.RotationModeManual,
+		})
+		c.Assert(err, IsNil, Commentf("phase %v", targetPhase))
+	}
+	c.Assert(alog.emitted, HasLen, 3)
+	c.Assert(alog.emitted[1].fields.GetString(events.RotationReason), Equals, "ops-1234")
+	c.Assert(alog.emitted[2].fields.GetString(events.RotationReason), Equals, "ops-1234")
+	transitions, err := s.a.getRotationTransitions(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(transitions[len(transitions)-1].Reason, Equals, "ops-1234")
+
+	// the reason of the completed rotation stays visible on standby
+	statuses, err = s.a.GetRotationStatus(services.HostCA)
+	c.Assert(err, IsNil)
+	c.Assert(statuses[0].Reason, Equals, "ops-1234")
+
+	// a policy can make the reason mandatory
+	err = s.a.SetRotationPolicy(RotationPolicy{
+		Type:          services.UserCA,
+		RequireReason: true,
+	})
+	c.Assert(err, IsNil)
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+	})
+	fixtures.ExpectBadParameter(c, err)
+	c.Assert(err, ErrorMatches, ".*requires a reason.*")
+	err = s.a.RotateCertAuthority(RotateRequest{
+		Type:        services.UserCA,
+		GracePeriod: &gracePeriod,
+		TargetPhase: services.RotationPhaseUpdateClients,
+		Mode:        services.RotationModeManual,
+		Reason:      "compliance audit",
+	})
+	c.Assert(err, IsNil)
+	ca = s.ca(c, services.UserCA)
+	c.Assert(ca.GetRotation().Reason, Equals, "compliance audit")
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"sort"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// rotationAcksBucket is a backend bucket keeping rotation acknowledgements
+// of nodes per certificate authority type, each key is a node ID and the
+// value is the rotation ID the node has acknowledged.
+func rotationAcksBucket(caType services.CertAuthType) []string {
+	return []string{"rotation_acks", string(caType)}
+}
+
+// AcknowledgeRotation records that the node has adopted the new certificate
+// authority of the given rotation, reported by nodes via heartbeat during
+// rotation.
+func (a *AuthServer) AcknowledgeRotation(caType services.CertAuthType, rotationID string, nodeID string) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if rotationID == "" {
+		return trace.BadParameter("missing parameter rotationID")
+	}
+	if nodeID == "" {
+		return trace.BadParameter("missing parameter nodeID")
+	}
+	return trace.Wrap(a.bk.UpsertVal(
+		rotationAcksBucket(caType), nodeID, []byte(rotationID), backend.Forever))
+}
+
+// RotationAckStatus reports the propagation of the current rotation across
+// the fleet - how many nodes have acknowledged adopting the new certificate
+// authority, the total number of registered nodes and the names of the
+// nodes that are still lagging.
+func (a *AuthServer) RotationAckStatus(caType services.CertAuthType) (acked, total int, pending []string, err error) {
+	if err := caType.Check(); err != nil {
+		return 0, 0, nil, trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, false)
+	if err != nil {
+		return 0, 0, nil, trace.Wrap(err)
+	}
+	rotation := ca.GetRotation()
+	if rotation.CurrentID == "" {
+		return 0, 0, nil, trace.NotFound(
+			"%q certificate authority has no rotation to acknowledge", caType)
+	}
+	nodes, err := a.Presence.GetNodes(defaults.Namespace)
+	if err != nil {
+		return 0, 0, nil, trace.Wrap(err)
+	}
+	bucket := rotationAcksBucket(caType)
+	for _, node := range nodes {
+		ackedID, err := a.bk.GetVal(bucket, node.GetName())
+		if err != nil && !trace.IsNotFound(err) {
+			return 0, 0, nil, trace.Wrap(err)
+		}
+		// only acknowledgements of the current rotation count,
+		// acks recorded for previous rotations are stale
+		if err == nil && string(ackedID) == rotation.CurrentID {
+			acked++
+			continue
+		}
+		pending = append(pending, node.GetName())
+	}
+	sort.Strings(pending)
+	return acked, len(nodes), pending, nil
+}
+
+// resetRotationAcks drops recorded rotation acknowledgements, called when
+// a new rotation starts so stale acks of the previous rotation do not
+// count towards the new one.
+func (a *AuthServer) resetRotationAcks(caType services.CertAuthType) {
+	err := a.bk.DeleteBucket([]string{"rotation_acks"}, string(caType))
+	if err != nil && !trace.IsNotFound(err) {
+		log.Warningf("Failed to reset rotation acknowledgements: %v.", err)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+
+	"github.com/gravitational/trace"
+)
+
+// The typed errors below let automation branch on why a rotation request
+// was refused, e.g. wait and retry on RotationInProgressError but abort
+// on IllegalPhaseTransitionError, instead of matching error strings.
+// They all embed trace.BadParameterError, so the HTTP status code and the
+// human readable messages are exactly as before.
+
+// RotationInProgressError is returned when a rotation can not start
+// because the certificate authority is already rotating or rolling back.
+type RotationInProgressError struct {
+	trace.BadParameterError
+}
+
+// newRotationInProgressError returns a new RotationInProgressError with
+// a formatted message.
+func newRotationInProgressError(format string, args ...interface{}) error {
+	return &RotationInProgressError{
+		BadParameterError: trace.BadParameterError{Message: fmt.Sprintf(format, args...)},
+	}
+}
+
+// IsRotationInProgress returns whether the error reports that a rotation
+// is already in progress, unwrapping trace wrappers first.
+func IsRotationInProgress(err error) bool {
+	_, ok := trace.Unwrap(err).(*RotationInProgressError)
+	return ok
+}
+
+// IllegalPhaseTransitionError is returned when the requested target phase
+// can not be reached from the phase the rotation is currently in, e.g.
+// update_servers requested while the rotation is on standby.
+type IllegalPhaseTransitionError struct {
+	trace.BadParameterError
+}
+
+// newIllegalPhaseTransitionError returns a new IllegalPhaseTransitionError
+// with a formatted message.
+func newIllegalPhaseTransitionError(format string, args ...interface{}) error {
+	return &IllegalPhaseTransitionError{
+		BadParameterError: trace.BadParameterError{Message: fmt.Sprintf(format, args...)},
+	}
+}
+
+// IsIllegalPhaseTransition returns whether the error reports a phase
+// transition the rotation state machine does not allow, unwrapping trace
+// wrappers first.
+func IsIllegalPhaseTransition(err error) bool {
+	_, ok := trace.Unwrap(err).(*IllegalPhaseTransitionError)
+	return ok
+}
+
+// UnsupportedPhaseError is returned when the requested target phase is
+// not a phase the rotation state machine knows about.
+type UnsupportedPhaseError struct {
+	trace.BadParameterError
+}
+
+// newUnsupportedPhaseError returns a new UnsupportedPhaseError with a
+// formatted message.
+func newUnsupportedPhaseError(format string, args ...interface{}) error {
+	return &UnsupportedPhaseError{
+		BadParameterError: trace.BadParameterError{Message: fmt.Sprintf(format, args...)},
+	}
+}
+
+// IsUnsupportedPhase returns whether the error reports an unknown target
+// phase, unwrapping trace wrappers first.
+func IsUnsupportedPhase(err error) bool {
+	_, ok := trace.Unwrap(err).(*UnsupportedPhaseError)
+	return ok
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// rotationHistoryLimit caps how many finished rotations are kept per
+// certificate authority, the oldest entries are evicted first.
+const rotationHistoryLimit = 20
+
+// rotationHistoryBucket is a backend bucket keeping the history of
+// finished rotations, separate from the certificate authority object
+// so reads on the hot path do not pay for it.
+var rotationHistoryBucket = []string{"rotation_history"}
+
+// RotationHistoryEntry describes a single finished rotation of
+// a certificate authority, completed normally or via rollback.
+type RotationHistoryEntry struct {
+	// CurrentID is the ID of the finished rotation.
+	CurrentID string `json:"current_id"`
+	// Started is the time the rotation started, zero for forced
+	// rotations that complete in one step.
+	Started time.Time `json:"started,omitempty"`
+	// FinishedAt is the time the rotation returned to standby.
+	FinishedAt time.Time `json:"finished_at"`
+	// GracePeriod is the grace period the rotation ran with.
+	GracePeriod services.Duration `json:"grace_period"`
+	// Mode is the rotation mode, manual or auto.
+	Mode string `json:"mode,omitempty"`
+	// RolledBack is true when the rotation ended via rollback
+	// instead of completing.
+	RolledBack bool `json:"rolled_back"`
+}
+
+// GetRotationHistory returns the finished rotations of the certificate
+// authority in chronological order, capped at rotationHistoryLimit
+// entries with the oldest evicted first.
+func (a *AuthServer) GetRotationHistory(caType services.CertAuthType) ([]RotationHistoryEntry, error) {
+	if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := a.bk.GetVal(rotationHistoryBucket, string(caType))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var entries []RotationHistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return entries, nil
+}
+
+// recordRotationHistory appends an entry to the rotation history when
+// a transition finishes a rotation, called from finishRotationTransition
+// after the transition has been persisted. History write failures are
+// logged and do not fail the rotation itself.
+func (a *AuthServer) recordRotationHistory(caType services.CertAuthType, prev, current services.Rotation) {
+	if current.State != services.RotationStateStandby {
+		return
+	}
+	var entry RotationHistoryEntry
+	switch {
+	case prev.State == services.RotationStateInProgress:
+		// a rotation in progress returned to standby, either by
+		// completing or by finishing a rollback
+		entry = RotationHistoryEntry{
+			CurrentID:   current.CurrentID,
+			Started:     prev.Started,
+			FinishedAt:  a.clock.Now().UTC(),
+			GracePeriod: current.GracePeriod,
+			Mode:        prev.Mode,
+			RolledBack:  prev.Phase == services.RotationPhaseRollback,
+		}
+	case current.CurrentID != "" && current.CurrentID != prev.CurrentID && !current.LastRotated.IsZero():
+		// a forced rotation with zero grace period started and
+		// completed in a single transition
+		entry = RotationHistoryEntry{
+			CurrentID:   current.CurrentID,
+			FinishedAt:  a.clock.Now().UTC(),
+			GracePeriod: current.GracePeriod,
+		}
+	default:
+		return
+	}
+	entries, err := a.GetRotationHistory(caType)
+	if err != nil {
+		log.Warningf("Failed to read rotation history: %v.", err)
+		return
+	}
+	entries = append(entries, entry)
+	if len(entries) > rotationHistoryLimit {
+		entries = entries[len(entries)-rotationHistoryLimit:]
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		log.Warningf("Failed to marshal rotation history: %v.", err)
+		return
+	}
+	if err := a.bk.UpsertVal(rotationHistoryBucket, string(caType), data, backend.Forever); err != nil {
+		log.Warningf("Failed to record rotation history: %v.", err)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/sshca"
+	"github.com/gravitational/teleport/lib/tlsca"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// RotationKeyAlgorithmRSA generates RSA key pairs, the default.
+	RotationKeyAlgorithmRSA = "rsa"
+	// RotationKeyAlgorithmECDSAP256 generates ECDSA key pairs
+	// on the NIST P-256 curve.
+	RotationKeyAlgorithmECDSAP256 = "ecdsa-p256"
+)
+
+// checkRotationKeyAlgorithm verifies the key algorithm is supported,
+// an empty value keeps the default RSA behavior.
+func checkRotationKeyAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", RotationKeyAlgorithmRSA, RotationKeyAlgorithmECDSAP256:
+		return nil
+	}
+	return trace.BadParameter("unsupported key algorithm %q, supported values are %q and %q",
+		algorithm, RotationKeyAlgorithmRSA, RotationKeyAlgorithmECDSAP256)
+}
+
+// sshPublicKeyWithComment returns the public key in the authorized-keys
+// format with the comment appended, identifying which cluster and
+// rotation the key was generated by, see RotateRequest.KeyComment.
+func sshPublicKeyWithComment(pubPEM []byte, comment string) []byte {
+	return []byte(fmt.Sprintf("%s %s\n", bytes.TrimRight(pubPEM, "\n"), comment))
+}
+
+// generateRotationKeyPair generates an SSH key pair with the requested
+// algorithm for a newly rotated certificate authority, an empty algorithm
+// defaults to the RSA key generator.
+func generateRotationKeyPair(keygen sshca.Authority, algorithm string) (privPEM, pubPEM []byte, err error) {
+	switch algorithm {
+	case "", RotationKeyAlgorithmRSA:
+		return keygen.GenerateKeyPair("")
+	case RotationKeyAlgorithmECDSAP256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		privPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		pub, err := ssh.NewPublicKey(&key.PublicKey)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		return privPEM, ssh.MarshalAuthorizedKey(pub), nil
+	}
+	return nil, nil, trace.Wrap(checkRotationKeyAlgorithm(algorithm))
+}
+
+// generateRotationTLSCA generates a self-signed TLS certificate authority
+// with the requested algorithm for a newly rotated certificate authority,
+// an empty algorithm defaults to RSA.
+func generateRotationTLSCA(algorithm string, entity pkix.Name, ttl time.Duration) (keyPEM, certPEM []byte, err error) {
+	switch algorithm {
+	case "", RotationKeyAlgorithmRSA:
+		return tlsca.GenerateSelfSignedCA(entity, nil, ttl)
+	case RotationKeyAlgorithmECDSAP256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		certPEM, err = tlsca.GenerateSelfSignedCAWithSigner(key, entity, nil, ttl)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		return keyPEM, certPEM, nil
+	}
+	return nil, nil, trace.Wrap(checkRotationKeyAlgorithm(algorithm))
+}
+
+// SSHKeyPair is an externally generated SSH key pair of a certificate
+// authority, see RotateRequest.ImportedSSHKeyPair.
+type SSHKeyPair struct {
+	// PrivateKey is a PEM encoded SSH private key.
+	PrivateKey []byte `json:"private_key"`
+	// PublicKey is the matching SSH public key in authorized keys format.
+	PublicKey []byte `json:"public_key"`
+}
+
+// Check verifies that the imported SSH key pair parses and that the
+// public key matches the private key, so a mismatched import can not
+// produce a certificate authority that signs with a key nobody trusts.
+func (k *SSHKeyPair) Check() error {
+	signer, err := ssh.ParsePrivateKey(k.PrivateKey)
+	if err != nil {
+		return trace.BadParameter("failed to parse imported SSH private key: %v", err)
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(k.PublicKey)
+	if err != nil {
+		return trace.BadParameter("failed to parse imported SSH public key: %v", err)
+	}
+	if !bytes.Equal(signer.PublicKey().Marshal(), public.Marshal()) {
+		return trace.BadParameter("imported SSH public key does not match the imported private key")
+	}
+	return nil
+}
+
+// checkImportedTLSKeyPair verifies that the imported TLS key pair parses,
+// that the certificate is a certificate authority certificate and that
+// the private key matches it, see RotateRequest.ImportedTLSKeyPair.
+func checkImportedTLSKeyPair(keyPair *services.TLSKeyPair) error {
+	cert, err := tlsca.ParseCertificatePEM(keyPair.Cert)
+	if err != nil {
+		return trace.BadParameter("failed to parse imported TLS certificate: %v", err)
+	}
+	if !cert.IsCA {
+		return trace.BadParameter("imported TLS certificate is not a certificate authority certificate")
+	}
+	signer, err := tlsca.ParsePrivateKeyPEM(keyPair.Key)
+	if err != nil {
+		return trace.BadParameter("failed to parse imported TLS private key: %v", err)
+	}
+	certPublic, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	keyPublic, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !bytes.Equal(certPublic, keyPublic) {
+		return trace.BadParameter("imported TLS private key does not match the imported certificate")
+	}
+	return nil
+}
+
+// zeroKeyMaterial overwrites the given private key buffers with zeros.
+// It is called on key material being discarded by rotation transitions,
+// so dropped private keys do not linger in memory until the garbage
+// collector gets to them.
+func zeroKeyMaterial(keys ...[]byte) {
+	for _, key := range keys {
+		for i := range key {
+			key[i] = 0
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/x509/pkix"
+	"time"
+
+	"github.com/gravitational/teleport/lib/sshca"
+)
+
+// RotationKeyStore generates the key material of newly rotated
+// certificate authorities. The default implementation generates keys
+// in process; deployments requiring that certificate authority private
+// keys never leave an HSM or a cloud KMS can plug in their own
+// implementation with SetRotationKeyStore without touching the
+// rotation state machine.
+type RotationKeyStore interface {
+	// GenerateSSHKeyPair returns a new SSH key pair with the requested
+	// algorithm, the private key in PEM format and the public key in
+	// authorized-keys format, an empty algorithm defaults to RSA.
+	GenerateSSHKeyPair(algorithm string) (privPEM, pubPEM []byte, err error)
+	// GenerateTLSCA returns a new self-signed TLS certificate authority
+	// with the requested algorithm, subject and certificate lifetime.
+	GenerateTLSCA(algorithm string, entity pkix.Name, ttl time.Duration) (keyPEM, certPEM []byte, err error)
+}
+
+// nativeRotationKeyStore is the default rotation key store generating
+// keys in process, see generateRotationKeyPair and generateRotationTLSCA.
+type nativeRotationKeyStore struct {
+	keygen sshca.Authority
+}
+
+// GenerateSSHKeyPair generates an SSH key pair in process.
+func (s *nativeRotationKeyStore) GenerateSSHKeyPair(algorithm string) ([]byte, []byte, error) {
+	return generateRotationKeyPair(s.keygen, algorithm)
+}
+
+// GenerateTLSCA generates a self-signed TLS certificate authority
+// in process.
+func (s *nativeRotationKeyStore) GenerateTLSCA(algorithm string, entity pkix.Name, ttl time.Duration) ([]byte, []byte, error) {
+	return generateRotationTLSCA(algorithm, entity, ttl)
+}
+
+// SetRotationKeyStore replaces the key store used to generate the key
+// material of newly rotated certificate authorities, e.g. with an HSM
+// or KMS backed implementation.
+func (a *AuthServer) SetRotationKeyStore(keyStore RotationKeyStore) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationKeyStore = keyStore
+}
+
+// getRotationKeyStore returns the configured rotation key store,
+// falling back to the default in-process one.
+func (a *AuthServer) getRotationKeyStore() RotationKeyStore {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.rotationKeyStore != nil {
+		return a.rotationKeyStore
+	}
+	return &nativeRotationKeyStore{keygen: a.Authority}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+const (
+	// rotationAdvisoryLockTTL bounds how long a rotation transition can
+	// hold the advisory lock, so an auth server that crashed mid-transition
+	// can not block rotations of its certificate authority type forever.
+	rotationAdvisoryLockTTL = time.Minute
+	// rotationLockHolderUnknown is recorded as the lock holder when the
+	// initiator of the transition is not known, e.g. a direct API call
+	// bypassing the authorization layer.
+	rotationLockHolderUnknown = "unknown"
+)
+
+// rotationLocksBucket is a backend bucket keeping the advisory rotation
+// locks, one key per certificate authority type with the name of the
+// holder as the value.
+var rotationLocksBucket = []string{"rotation_locks"}
+
+// acquireRotationLock takes the advisory lock serializing rotation
+// transitions of the given certificate authority type across operators
+// and auth servers. The caller sees concurrent transitions as clean
+// AlreadyExists errors naming the holder instead of confusing partial
+// CompareFailed failures. The lock expires on its own should the holder
+// crash before releasing it.
+func (a *AuthServer) acquireRotationLock(caType services.CertAuthType, holder string) error {
+	if holder == "" {
+		holder = rotationLockHolderUnknown
+	}
+	err := a.bk.CreateVal(rotationLocksBucket, string(caType), []byte(holder), rotationAdvisoryLockTTL)
+	if err == nil {
+		return nil
+	}
+	if !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	current, err := a.bk.GetVal(rotationLocksBucket, string(caType))
+	if err != nil {
+		// the lock was released or expired between the attempts
+		return trace.AlreadyExists(
+			"another rotation of the %q certificate authority is in flight, retry when it completes", caType)
+	}
+	return trace.AlreadyExists(
+		"rotation of the %q certificate authority is already being driven by %q, retry when it completes", caType, current)
+}
+
+// releaseRotationLock releases the advisory rotation lock of the given
+// certificate authority type, a missing lock is not an error as the
+// lock expires on its own.
+func (a *AuthServer) releaseRotationLock(caType services.CertAuthType) {
+	err := a.bk.DeleteKey(rotationLocksBucket, string(caType))
+	if err != nil && !trace.IsNotFound(err) {
+		log.Warningf("Failed to release rotation lock of %q certificate authority: %v.", caType, err)
+	}
+}
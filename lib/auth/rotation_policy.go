@@ -0,0 +1,181 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// RotationPolicy is a per certificate authority rotation policy that
+// provides the baseline for rotation requests, a request can override
+// individual fields, but unset fields default to the policy values.
+type RotationPolicy struct {
+	// Type is the certificate authority type this policy applies to.
+	Type services.CertAuthType `json:"type"`
+	// GracePeriod is the default grace period used by rotations
+	// that do not specify one.
+	GracePeriod services.Duration `json:"grace_period,omitempty"`
+	// Mode is the default rotation mode, "manual" or "auto".
+	Mode string `json:"mode,omitempty"`
+	// Algorithm is the key algorithm used for newly generated
+	// certificate authorities, "rsa" or "ecdsa-p256".
+	Algorithm string `json:"algorithm,omitempty"`
+	// Windows is an optional list of time windows during which
+	// rotations are allowed to start, no windows means rotations
+	// can start at any time.
+	Windows []RotationWindow `json:"windows,omitempty"`
+	// Cooldown is the minimum period that has to pass after
+	// a completed rotation before a new one can start.
+	Cooldown services.Duration `json:"cooldown,omitempty"`
+	// RequireReason requires rotation requests to carry a non-empty
+	// reason, so rotations in regulated clusters can not start without
+	// a documented justification, see RotateRequest.Reason.
+	RequireReason bool `json:"require_reason,omitempty"`
+}
+
+// RotationWindow is a daily time window in UTC hours during which
+// rotations are allowed to start.
+type RotationWindow struct {
+	// Start is the hour of the day (0-23) the window opens.
+	Start int `json:"start"`
+	// Stop is the hour of the day (0-23) the window closes,
+	// a window with Stop before Start wraps around midnight.
+	Stop int `json:"stop"`
+}
+
+// Contains returns true if the given UTC hour falls within the window.
+func (w *RotationWindow) Contains(hour int) bool {
+	if w.Start <= w.Stop {
+		return hour >= w.Start && hour < w.Stop
+	}
+	// the window wraps around midnight
+	return hour >= w.Start || hour < w.Stop
+}
+
+// CheckAndSetDefaults checks and sets default values of the rotation policy.
+func (p *RotationPolicy) CheckAndSetDefaults() error {
+	if err := p.Type.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	switch p.Mode {
+	case "", services.RotationModeManual, services.RotationModeAuto:
+	default:
+		return trace.BadParameter("unsupported rotation mode: %q", p.Mode)
+	}
+	if err := checkRotationKeyAlgorithm(p.Algorithm); err != nil {
+		return trace.Wrap(err)
+	}
+	if p.GracePeriod.Duration < 0 {
+		return trace.BadParameter("grace period can not be negative")
+	}
+	if p.GracePeriod.Duration > defaults.MaxRotationGracePeriod {
+		return trace.BadParameter("grace period %v exceeds the maximum of %v",
+			p.GracePeriod.Duration, defaults.MaxRotationGracePeriod)
+	}
+	if p.Cooldown.Duration < 0 {
+		return trace.BadParameter("cooldown can not be negative")
+	}
+	for _, window := range p.Windows {
+		if window.Start < 0 || window.Start > 23 || window.Stop < 0 || window.Stop > 23 {
+			return trace.BadParameter("rotation window hours have to be within 0-23")
+		}
+	}
+	return nil
+}
+
+// applyToRequest fills the unset fields of the rotation request
+// from the policy baseline.
+func (p *RotationPolicy) applyToRequest(req *RotateRequest) {
+	if req.GracePeriod == nil && p.GracePeriod.Duration != 0 {
+		period := p.GracePeriod.Duration
+		req.GracePeriod = &period
+	}
+	if req.Mode == "" {
+		req.Mode = p.Mode
+	}
+	if req.KeyAlgorithm == "" {
+		req.KeyAlgorithm = p.Algorithm
+	}
+}
+
+// checkCanStart verifies that a new rotation is allowed to start now
+// per the policy cooldown and allowed time windows.
+func (p *RotationPolicy) checkCanStart(now time.Time, rotation services.Rotation) error {
+	if p.Cooldown.Duration != 0 && !rotation.LastRotated.IsZero() {
+		deadline := rotation.LastRotated.Add(p.Cooldown.Duration)
+		if now.Before(deadline) {
+			return trace.BadParameter(
+				"rotation cooldown is in effect until %v", deadline)
+		}
+	}
+	if len(p.Windows) == 0 {
+		return nil
+	}
+	hour := now.UTC().Hour()
+	for _, window := range p.Windows {
+		if window.Contains(hour) {
+			return nil
+		}
+	}
+	return trace.BadParameter(
+		"rotation is not allowed to start outside of the configured time windows")
+}
+
+// rotationPoliciesBucket is a backend bucket keeping rotation policies
+// per certificate authority type.
+var rotationPoliciesBucket = []string{"rotation_policies"}
+
+// SetRotationPolicy sets the rotation policy for the certificate authority
+// type specified in the policy.
+func (a *AuthServer) SetRotationPolicy(policy RotationPolicy) error {
+	if err := policy.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.bk.UpsertVal(
+		rotationPoliciesBucket, string(policy.Type), data, backend.Forever))
+}
+
+// GetRotationPolicy returns the rotation policy of the certificate authority
+// of the given type, returns NotFound error if no policy has been set.
+func (a *AuthServer) GetRotationPolicy(caType services.CertAuthType) (*RotationPolicy, error) {
+	if err := caType.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := a.bk.GetVal(rotationPoliciesBucket, string(caType))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("rotation policy for %q is not set", caType)
+		}
+		return nil, trace.Wrap(err)
+	}
+	var policy RotationPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, trace.BadParameter("failed to unmarshal rotation policy: %v", err)
+	}
+	return &policy, nil
+}
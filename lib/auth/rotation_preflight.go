@@ -0,0 +1,100 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// checkRotationPreflight verifies that all registered auth servers and
+// proxies have acknowledged observing the given rotation before the
+// rotation is allowed to advance to the update_servers phase. Advancing
+// while a peer is still serving the old trust set causes it to reject
+// clients that already re-issued their credentials, so the preflight
+// fails with an error naming the lagging peers instead. Peers report
+// observation via AcknowledgeRotation, same as nodes.
+func (a *AuthServer) checkRotationPreflight(caType services.CertAuthType, rotationID string) error {
+	authServers, err := a.Presence.GetAuthServers()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	proxies, err := a.Presence.GetProxies()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	bucket := rotationAcksBucket(caType)
+	var lagging []string
+	for _, peer := range append(authServers, proxies...) {
+		ackedID, err := a.bk.GetVal(bucket, peer.GetName())
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		if err == nil && string(ackedID) == rotationID {
+			continue
+		}
+		lagging = append(lagging, peer.GetName())
+	}
+	if len(lagging) != 0 {
+		sort.Strings(lagging)
+		return trace.BadParameter(
+			"can not advance rotation of %q certificate authority to phase %q, peers have not observed rotation %v yet: %v, retry once they catch up or set SkipPreflight to override",
+			caType, services.RotationPhaseUpdateServers, rotationID, strings.Join(lagging, ", "))
+	}
+	return nil
+}
+
+// checkOrphanedNodes verifies that every registered node has been seen
+// online since the rotation started before the rotation is allowed to
+// complete. Completing drops the old certificate authority from the
+// trusted set, so a node that slept through the whole rotation, e.g. in
+// maintenance mode, would be locked out with credentials nobody trusts
+// anymore. The check fails with an error naming the potentially orphaned
+// nodes instead. A node entry without an expiry has no heartbeat to
+// judge staleness by and is skipped.
+func (a *AuthServer) checkOrphanedNodes(caType services.CertAuthType, rotation services.Rotation) error {
+	nodes, err := a.Presence.GetNodes(defaults.Namespace)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var stale []string
+	for _, node := range nodes {
+		expiry := node.Expiry()
+		if expiry.IsZero() {
+			continue
+		}
+		// the node entry expires one heartbeat TTL after the last
+		// heartbeat, so the last time the node was seen online is the
+		// expiry less the TTL
+		lastSeen := expiry.Add(-defaults.ServerHeartbeatTTL)
+		if lastSeen.Before(rotation.Started) {
+			stale = append(stale, node.GetName())
+		}
+	}
+	if len(stale) != 0 {
+		sort.Strings(stale)
+		return trace.BadParameter(
+			"can not complete rotation of %q certificate authority, nodes have not been seen online since the rotation started and would be orphaned: %v, bring them online or set SkipPreflight to override",
+			caType, strings.Join(stale, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/x509/pkix"
+	"encoding/json"
+	"sort"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/tlsca"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+)
+
+// stockpiledKey is a pre-generated, inactive certificate authority key set
+// kept in the backend so rotations can proceed even when the key generation
+// subsystem, e.g. a rate limited HSM, is temporarily unavailable.
+type stockpiledKey struct {
+	// ID identifies the stockpiled key set.
+	ID string `json:"id"`
+	// SSHPrivPEM is the SSH private key.
+	SSHPrivPEM []byte `json:"ssh_priv"`
+	// SSHPubPEM is the SSH public key in authorized keys format.
+	SSHPubPEM []byte `json:"ssh_pub"`
+	// TLSKeyPEM is the TLS certificate authority private key.
+	TLSKeyPEM []byte `json:"tls_key"`
+	// TLSCertPEM is the self signed TLS certificate authority certificate.
+	TLSCertPEM []byte `json:"tls_cert"`
+}
+
+// rotationStockpileBucket is a backend bucket keeping pre-generated
+// certificate authority keys per certificate authority type.
+func rotationStockpileBucket(caType services.CertAuthType) []string {
+	return []string{"rotation_stockpile", string(caType)}
+}
+
+// StockpileCAKeys pre-generates and stores n certificate authority key sets
+// to be consumed by future rotations of the certificate authority of the
+// given type. The key material is passed to the key escrow hook, if one is
+// registered, at generation time.
+func (a *AuthServer) StockpileCAKeys(caType services.CertAuthType, n int) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if n <= 0 {
+		return trace.BadParameter("number of keys to stockpile has to be positive, got %v", n)
+	}
+	clusterName := a.clusterName.GetClusterName()
+	hook := a.getKeyEscrowHook()
+	for i := 0; i < n; i++ {
+		sshPrivPEM, sshPubPEM, err := a.Authority.GenerateKeyPair("")
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		tlsKeyPEM, tlsCertPEM, err := tlsca.GenerateSelfSignedCA(pkix.Name{
+			CommonName:   clusterName,
+			Organization: []string{clusterName},
+		}, nil, defaults.CATTL)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if hook != nil {
+			if err := hook(caType, sshPrivPEM, tlsKeyPEM); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		key := stockpiledKey{
+			ID:         uuid.New(),
+			SSHPrivPEM: sshPrivPEM,
+			SSHPubPEM:  sshPubPEM,
+			TLSKeyPEM:  tlsKeyPEM,
+			TLSCertPEM: tlsCertPEM,
+		}
+		data, err := json.Marshal(key)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = a.bk.UpsertVal(rotationStockpileBucket(caType), key.ID, data, backend.Forever)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// StockpileDepth returns the number of pre-generated key sets currently
+// available for rotations of the certificate authority of the given type.
+func (a *AuthServer) StockpileDepth(caType services.CertAuthType) (int, error) {
+	if err := caType.Check(); err != nil {
+		return 0, trace.Wrap(err)
+	}
+	keys, err := a.bk.GetKeys(rotationStockpileBucket(caType))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, trace.Wrap(err)
+	}
+	return len(keys), nil
+}
+
+// peekStockpiledKey returns one of the stockpiled key sets without removing
+// it, returns nil if the stockpile is empty. The key is removed with
+// consumeStockpiledKey only after the rotation using it has been persisted.
+func (a *AuthServer) peekStockpiledKey(caType services.CertAuthType) (*stockpiledKey, error) {
+	bucket := rotationStockpileBucket(caType)
+	keys, err := a.bk.GetKeys(bucket)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+	data, err := a.bk.GetVal(bucket, keys[0])
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var key stockpiledKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, trace.BadParameter("failed to unmarshal stockpiled key: %v", err)
+	}
+	return &key, nil
+}
+
+// consumeStockpiledKey removes the stockpiled key set that has been used
+// by a rotation, the removal is best-effort.
+func (a *AuthServer) consumeStockpiledKey(caType services.CertAuthType, id string) {
+	err := a.bk.DeleteKey(rotationStockpileBucket(caType), id)
+	if err != nil && !trace.IsNotFound(err) {
+		log.Warningf("Failed to remove consumed stockpiled key: %v.", err)
+	}
+}
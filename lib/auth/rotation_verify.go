@@ -0,0 +1,363 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto/x509"
+	"time"
+
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/tlsca"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"golang.org/x/crypto/ssh"
+)
+
+// rotationVerifyTTL is the TTL of the throwaway certificates issued
+// by the post-rotation self test, they are never handed out and only
+// have to stay valid for the duration of the test.
+const rotationVerifyTTL = time.Minute
+
+// rotationVerifyPrincipal is the principal the throwaway certificates
+// are issued for.
+const rotationVerifyPrincipal = "rotation-verify"
+
+// VerifyRotatedCA runs a post-rotation self test of the certificate
+// authority: it issues a short-lived throwaway certificate signed by the
+// active key set and verifies it against the checking keys, confirming
+// the rotated certificate authority can actually issue valid credentials.
+// It catches the case where a rotation completed but left behind a key
+// set that can not sign, before users discover it the hard way.
+func (a *AuthServer) VerifyRotatedCA(caType services.CertAuthType) error {
+	if err := caType.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	if len(signingKeys) == 0 || len(checkingKeys) == 0 || len(keyPairs) == 0 {
+		return trace.BadParameter("certificate authority %v has no keys to verify", ca.GetID())
+	}
+	// the active key set is always the first one, see startNewRotation;
+	// the database certificate authority only ever issues TLS credentials,
+	// so its SSH key pair is carried for schema consistency and the SSH
+	// half of the self test is skipped
+	if caType != services.DatabaseCA {
+		if err := a.verifySSHKeyPair(ca, signingKeys[0], checkingKeys[0]); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if err := a.verifyTLSKeyPair(keyPairs[0]); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// VerifyCertAuthorityConsistency checks that the key material of the
+// certificate authority is internally consistent: every SSH signing key
+// matches the checking key at the same position, the private key of every
+// TLS key pair matches its certificate, and the number of trusted keys is
+// within the bounds the current rotation phase allows. Unlike the self
+// test of VerifyRotatedCA it does not issue any certificates, so it is
+// cheap enough to run on every rotation write and catches key ordering
+// bugs in the rotation state machine before they reach the backend.
+func VerifyCertAuthorityConsistency(ca services.CertAuthority) error {
+	rotation := ca.GetRotation()
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	// during the overlap window of a rotation two generations are trusted,
+	// plus any extra generations kept by the retention setting; a scoped
+	// rotation only doubles one side of the key material, so each side is
+	// checked against the bounds independently
+	maxTrusted := expectedTLSKeyPairCount(rotation.Phase)
+	if rotation.RetainGenerations > 1 {
+		maxTrusted += rotation.RetainGenerations - 1
+	}
+	if len(checkingKeys) == 0 || len(checkingKeys) > maxTrusted {
+		return trace.BadParameter(
+			"certificate authority %v has %v checking keys, expected between 1 and %v in phase %q",
+			ca.GetID(), len(checkingKeys), maxTrusted, rotation.Phase)
+	}
+	// pinned certificates add certificate-only entries on the TLS side,
+	// see RotateRequest.PinnedCerts
+	maxPairs := maxTrusted + len(rotation.PinnedCerts)
+	if len(keyPairs) == 0 || len(keyPairs) > maxPairs {
+		return trace.BadParameter(
+			"certificate authority %v has %v TLS key pairs, expected between 1 and %v in phase %q",
+			ca.GetID(), len(keyPairs), maxPairs, rotation.Phase)
+	}
+	if len(signingKeys) == 0 || len(signingKeys) > len(checkingKeys) {
+		return trace.BadParameter(
+			"certificate authority %v has %v signing keys for %v checking keys",
+			ca.GetID(), len(signingKeys), len(checkingKeys))
+	}
+	// signing and checking keys are ordered the same way, see
+	// startNewRotation and startRollingBackRotation, generations retained
+	// with their checking key only trail the list
+	for i, signingKey := range signingKeys {
+		signer, err := ssh.ParsePrivateKey(signingKey)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable signing key at position %v: %v",
+				ca.GetID(), i, err)
+		}
+		authority, _, _, _, err := ssh.ParseAuthorizedKey(checkingKeys[i])
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable checking key at position %v: %v",
+				ca.GetID(), i, err)
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), authority.Marshal()) {
+			return trace.BadParameter(
+				"certificate authority %v signing key at position %v does not match its checking key",
+				ca.GetID(), i)
+		}
+	}
+	for i, keyPair := range keyPairs {
+		cert, err := tlsca.ParseCertificatePEM(keyPair.Cert)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable TLS certificate at position %v: %v",
+				ca.GetID(), i, err)
+		}
+		// retained old generations keep their certificate only
+		if len(keyPair.Key) == 0 {
+			continue
+		}
+		signer, err := tlsca.ParsePrivateKeyPEM(keyPair.Key)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable TLS key at position %v: %v",
+				ca.GetID(), i, err)
+		}
+		certPublic, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		keyPublic, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !bytes.Equal(certPublic, keyPublic) {
+			return trace.BadParameter(
+				"certificate authority %v TLS key at position %v does not match its certificate",
+				ca.GetID(), i)
+		}
+	}
+	return nil
+}
+
+// checkRotationKeyOrder checks the ordering convention the rotation state
+// machine relies on before a destructive transition: during the overlap
+// window the new certificate authority is at position 0 and the old one at
+// position 1, see startNewRotation. A certificate authority modified by an
+// external write may still be internally consistent but ordered the wrong
+// way around, and rolling back or completing a rotation would then keep
+// the wrong key, so the ordering is verified explicitly and a violation
+// has to be resolved by inspecting the certificate authority manually.
+func checkRotationKeyOrder(ca services.CertAuthority) error {
+	signingKeys := ca.GetSigningKeys()
+	checkingKeys := ca.GetCheckingKeys()
+	keyPairs := ca.GetTLSKeyPairs()
+	if len(signingKeys) >= 2 && len(checkingKeys) >= 2 {
+		signer, err := ssh.ParsePrivateKey(signingKeys[0])
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable active signing key: %v, inspect the certificate authority manually",
+				ca.GetID(), err)
+		}
+		authority, _, _, _, err := ssh.ParseAuthorizedKey(checkingKeys[0])
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable active checking key: %v, inspect the certificate authority manually",
+				ca.GetID(), err)
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), authority.Marshal()) {
+			return trace.BadParameter(
+				"certificate authority %v key ordering is inconsistent: the active signing key does not match the active checking key, the certificate authority appears to have been modified outside of the rotation, inspect it manually before proceeding",
+				ca.GetID())
+		}
+	}
+	if len(keyPairs) >= 2 {
+		if len(keyPairs[0].Key) == 0 {
+			return trace.BadParameter(
+				"certificate authority %v key ordering is inconsistent: the active TLS key pair has no private key, the certificate authority appears to have been modified outside of the rotation, inspect it manually before proceeding",
+				ca.GetID())
+		}
+		newCert, err := tlsca.ParseCertificatePEM(keyPairs[0].Cert)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable active TLS certificate: %v, inspect the certificate authority manually",
+				ca.GetID(), err)
+		}
+		signer, err := tlsca.ParsePrivateKeyPEM(keyPairs[0].Key)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable active TLS key: %v, inspect the certificate authority manually",
+				ca.GetID(), err)
+		}
+		certPublic, err := x509.MarshalPKIXPublicKey(newCert.PublicKey)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		keyPublic, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !bytes.Equal(certPublic, keyPublic) {
+			return trace.BadParameter(
+				"certificate authority %v key ordering is inconsistent: the active TLS key does not match the active TLS certificate, the certificate authority appears to have been modified outside of the rotation, inspect it manually before proceeding",
+				ca.GetID())
+		}
+		oldCert, err := tlsca.ParseCertificatePEM(keyPairs[1].Cert)
+		if err != nil {
+			return trace.BadParameter(
+				"certificate authority %v has an unparseable old TLS certificate: %v, inspect the certificate authority manually",
+				ca.GetID(), err)
+		}
+		// the certificate issued by the rotation can not predate the one
+		// it replaces, an older certificate in the active slot means the
+		// generations have been swapped
+		if newCert.NotBefore.Before(oldCert.NotBefore) {
+			return trace.BadParameter(
+				"certificate authority %v key ordering is inconsistent: the active TLS certificate predates the old one, the certificate authority appears to have been modified outside of the rotation, inspect it manually before proceeding",
+				ca.GetID())
+		}
+	}
+	return nil
+}
+
+// verifySSHKeyPair issues a throwaway SSH certificate with the signing key
+// and verifies it against the checking key.
+func (a *AuthServer) verifySSHKeyPair(ca services.CertAuthority, signingKey, checkingKey []byte) error {
+	_, pubKey, err := a.Authority.GenerateKeyPair("")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var certBytes []byte
+	switch ca.GetType() {
+	case services.HostCA:
+		certBytes, err = a.Authority.GenerateHostCert(services.HostCertParams{
+			PrivateCASigningKey: signingKey,
+			PublicHostKey:       pubKey,
+			HostID:              rotationVerifyPrincipal,
+			NodeName:            rotationVerifyPrincipal,
+			ClusterName:         ca.GetClusterName(),
+			Roles:               teleport.Roles{teleport.RoleNode},
+			TTL:                 rotationVerifyTTL,
+		})
+	case services.UserCA:
+		certBytes, err = a.Authority.GenerateUserCert(services.UserCertParams{
+			PrivateCASigningKey: signingKey,
+			PublicUserKey:       pubKey,
+			Username:            rotationVerifyPrincipal,
+			AllowedLogins:       []string{rotationVerifyPrincipal},
+			TTL:                 rotationVerifyTTL,
+			CertificateFormat:   teleport.CertificateFormatStandard,
+		})
+	default:
+		return trace.BadParameter("unsupported certificate authority type: %q", ca.GetType())
+	}
+	if err != nil {
+		return trace.BadParameter(
+			"%q certificate authority failed to issue an SSH certificate: %v", ca.GetType(), err)
+	}
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return trace.BadParameter(
+			"%q certificate authority issued %T instead of a certificate", ca.GetType(), parsed)
+	}
+	authority, _, _, _, err := ssh.ParseAuthorizedKey(checkingKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// the certificate has to be signed by the checking key clients trust,
+	// a mismatch means the signing and checking keys have diverged
+	if !bytes.Equal(cert.SignatureKey.Marshal(), authority.Marshal()) {
+		return trace.BadParameter(
+			"%q certificate authority issued a certificate that does not chain to its checking key", ca.GetType())
+	}
+	checker := ssh.CertChecker{}
+	if err := checker.CheckCert(rotationVerifyPrincipal, cert); err != nil {
+		return trace.BadParameter(
+			"%q certificate authority failed the SSH self test: %v", ca.GetType(), err)
+	}
+	return nil
+}
+
+// verifyTLSKeyPair issues a throwaway TLS certificate with the key pair
+// and verifies it against the certificate of the pair. The TLS subsystem
+// runs on wall clock time, see tlsca.GenerateSelfSignedCA, so the self
+// test does as well.
+func (a *AuthServer) verifyTLSKeyPair(keyPair services.TLSKeyPair) error {
+	tlsAuthority, err := tlsca.New(keyPair.Cert, keyPair.Key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	privateKeyPEM, err := tlsca.GenerateRSAPrivateKeyPEM()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	signer, err := tlsca.ParsePrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	identity := tlsca.Identity{
+		Username: rotationVerifyPrincipal,
+		Groups:   []string{rotationVerifyPrincipal},
+	}
+	certPEM, err := tlsAuthority.GenerateCertificate(tlsca.CertificateRequest{
+		Clock:     clockwork.NewRealClock(),
+		PublicKey: signer.Public(),
+		Subject:   identity.Subject(),
+		NotAfter:  time.Now().UTC().Add(rotationVerifyTTL),
+	})
+	if err != nil {
+		return trace.BadParameter(
+			"certificate authority failed to issue a TLS certificate: %v", err)
+	}
+	issued, err := tlsca.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsAuthority.Cert)
+	_, err = issued.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return trace.BadParameter(
+			"certificate authority failed the TLS self test: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// rotateAndWaitPollInterval is how often RotateAndWait polls the rotation
+// state of the certificate authorities it is waiting on.
+const rotateAndWaitPollInterval = time.Second
+
+// RotationTransitionFunc is called by RotateAndWait once per observed
+// phase transition of a certificate authority, so callers can report
+// rotation progress.
+type RotationTransitionFunc func(caType services.CertAuthType, rotation services.Rotation)
+
+// RotateAndWait starts an automatic rotation and blocks until every
+// rotated certificate authority has settled back on standby, either by
+// completing the rotation or by finishing a rollback. The rotation state
+// is polled once a second and the optional onTransition callback is
+// invoked for every phase transition observed along the way. The final
+// rotation state of every rotated certificate authority type is
+// returned. Canceling the context stops the wait, not the rotation -
+// the rotation keeps advancing on its schedule and can be picked up
+// again with GetRotationStatus.
+func (a *AuthServer) RotateAndWait(ctx context.Context, req RotateRequest, onTransition RotationTransitionFunc) (map[services.CertAuthType]services.Rotation, error) {
+	if req.Mode == services.RotationModeManual {
+		return nil, trace.BadParameter(
+			"manual rotations complete their phase transitions immediately and have nothing to wait for, use RotateCertAuthority or mode %q", services.RotationModeAuto)
+	}
+	if req.Mode == "" {
+		req.Mode = services.RotationModeAuto
+	}
+	if err := a.RotateCertAuthority(req); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	implicit := req.Type == ""
+	lastPhase := make(map[services.CertAuthType]string, len(req.Types()))
+	final := make(map[services.CertAuthType]services.Rotation, len(req.Types()))
+	for {
+		settled := true
+		for _, caType := range req.Types() {
+			ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+				Type:       caType,
+				DomainName: a.clusterName.GetClusterName(),
+			}, false)
+			if err != nil {
+				// not every cluster has all certificate authority types
+				// set up, e.g. the database one is optional, skip the
+				// missing ones when waiting on all types implicitly,
+				// matching the rotation itself
+				if implicit && trace.IsNotFound(err) {
+					continue
+				}
+				return nil, trace.Wrap(err)
+			}
+			rotation := ca.GetRotation()
+			if rotation.Phase != lastPhase[caType] {
+				lastPhase[caType] = rotation.Phase
+				if onTransition != nil {
+					onTransition(caType, rotation)
+				}
+			}
+			if rotation.State != services.RotationStateStandby {
+				settled = false
+			}
+			final[caType] = rotation
+		}
+		if settled {
+			return final, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, trace.Wrap(ctx.Err())
+		case <-a.clock.After(rotateAndWaitPollInterval):
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/gravitational/trace"
+)
+
+// rotationWatcherBufferSize is the size of the event buffer of a single
+// rotation watcher, a subscriber falling this many events behind starts
+// losing events, see RotationWatcher.
+const rotationWatcherBufferSize = 64
+
+// RotationStateChange describes a single observed change of the rotation
+// state of a certificate authority, delivered to rotation watchers. It
+// carries the phase before and after the change, so subscribers do not
+// have to keep their own copy of the previous state to tell what moved.
+type RotationStateChange struct {
+	// Type is the certificate authority type whose rotation changed.
+	Type services.CertAuthType `json:"type"`
+	// OldState is the rotation state before the change.
+	OldState string `json:"old_state"`
+	// NewState is the rotation state after the change.
+	NewState string `json:"new_state"`
+	// OldPhase is the rotation phase before the change.
+	OldPhase string `json:"old_phase"`
+	// NewPhase is the rotation phase after the change.
+	NewPhase string `json:"new_phase"`
+	// CurrentID is the ID of the rotation operation after the change.
+	CurrentID string `json:"current_id,omitempty"`
+	// Time is the time of the change.
+	Time time.Time `json:"time"`
+}
+
+// RotationWatcher is a subscription to rotation state changes of
+// certificate authorities, created with AuthServer.WatchRotation.
+// Unlike RotationNotifier it is pull based - changes are delivered
+// on a buffered channel, so a dashboard can consume them at its own
+// pace without polling GetRotationStatus. A watcher that stops reading
+// loses events once its buffer fills up, consumers that cannot afford
+// that should resynchronize with GetRotationStatus after a gap.
+type RotationWatcher struct {
+	caType  services.CertAuthType
+	eventsC chan RotationStateChange
+	doneC   chan struct{}
+	auth    *AuthServer
+}
+
+// Events returns the channel the rotation state changes are
+// delivered on.
+func (w *RotationWatcher) Events() <-chan RotationStateChange {
+	return w.eventsC
+}
+
+// Done returns the channel closed when the watcher is closed.
+func (w *RotationWatcher) Done() <-chan struct{} {
+	return w.doneC
+}
+
+// Close unsubscribes the watcher, no more events are delivered
+// after it returns. Close is safe to call multiple times.
+func (w *RotationWatcher) Close() error {
+	w.auth.closeRotationWatcher(w)
+	return nil
+}
+
+// WatchRotation subscribes to rotation state changes of the certificate
+// authorities of the given type, or of all certificate authorities if
+// the type is empty. The caller has to close the returned watcher when
+// done with it.
+func (a *AuthServer) WatchRotation(caType services.CertAuthType) (*RotationWatcher, error) {
+	if caType != "" {
+		if err := caType.Check(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	watcher := &RotationWatcher{
+		caType:  caType,
+		eventsC: make(chan RotationStateChange, rotationWatcherBufferSize),
+		doneC:   make(chan struct{}),
+		auth:    a,
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationWatchers = append(a.rotationWatchers, watcher)
+	return watcher, nil
+}
+
+// closeRotationWatcher unsubscribes the watcher, see
+// RotationWatcher.Close.
+func (a *AuthServer) closeRotationWatcher(watcher *RotationWatcher) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for i, registered := range a.rotationWatchers {
+		if registered == watcher {
+			a.rotationWatchers = append(a.rotationWatchers[:i], a.rotationWatchers[i+1:]...)
+			close(watcher.doneC)
+			return
+		}
+	}
+}
+
+// broadcastRotationChange delivers the rotation state change to every
+// subscribed watcher, dropping the event for watchers whose buffer is
+// full so a stuck subscriber never blocks the rotation.
+func (a *AuthServer) broadcastRotationChange(caType services.CertAuthType, prev, current services.Rotation) {
+	// transitions are only recorded when the rotation actually moved,
+	// but guard against no-op deltas anyway so watchers only see
+	// changes of the fields they filter on
+	if prev.State == current.State && prev.Phase == current.Phase && prev.CurrentID == current.CurrentID {
+		return
+	}
+	change := RotationStateChange{
+		Type:      caType,
+		OldState:  prev.State,
+		NewState:  current.State,
+		OldPhase:  prev.Phase,
+		NewPhase:  current.Phase,
+		CurrentID: current.CurrentID,
+		Time:      a.clock.Now().UTC(),
+	}
+	a.lock.Lock()
+	watchers := make([]*RotationWatcher, len(a.rotationWatchers))
+	copy(watchers, a.rotationWatchers)
+	a.lock.Unlock()
+	for _, watcher := range watchers {
+		if watcher.caType != "" && watcher.caType != caType {
+			continue
+		}
+		select {
+		case watcher.eventsC <- change:
+		default:
+			log.Warningf("Rotation watcher for %q is not keeping up, dropping event.", watcher.caType)
+		}
+	}
+}
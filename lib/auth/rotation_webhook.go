@@ -0,0 +1,175 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+)
+
+const (
+	// rotationWebhookTimeout bounds a single webhook delivery attempt.
+	rotationWebhookTimeout = 5 * time.Second
+	// rotationWebhookAttempts is how many times a failed webhook delivery
+	// is attempted before giving up.
+	rotationWebhookAttempts = 3
+	// rotationWebhookRetryPause is the pause between webhook delivery
+	// attempts.
+	rotationWebhookRetryPause = time.Second
+	// RotationWebhookSignatureHeader carries the hex encoded HMAC-SHA256
+	// signature of the webhook payload when a signing secret is
+	// configured.
+	RotationWebhookSignatureHeader = "X-Teleport-Signature"
+)
+
+// rotationWebhookResult values reported in the webhook payload.
+const (
+	rotationWebhookCompleted  = "completed"
+	rotationWebhookRolledBack = "rolled_back"
+)
+
+// RotationWebhookConfig configures an HTTP callout fired whenever a
+// rotation finishes, so external systems, e.g. change management, learn
+// about completed and rolled back rotations without polling.
+type RotationWebhookConfig struct {
+	// URL is the endpoint receiving a POST with the JSON payload
+	// described by rotationWebhookPayload.
+	URL string
+	// Secret, when set, signs the payload with HMAC-SHA256, the hex
+	// encoded signature is sent in the RotationWebhookSignatureHeader
+	// header so the receiver can authenticate the callout.
+	Secret string
+}
+
+// SetRotationWebhook configures the rotation completion webhook, passing
+// nil removes it. Webhook delivery is asynchronous and best-effort, a
+// failing endpoint never blocks or fails a rotation.
+func (a *AuthServer) SetRotationWebhook(config *RotationWebhookConfig) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rotationWebhook = config
+}
+
+// getRotationWebhook returns the configured rotation webhook if any.
+func (a *AuthServer) getRotationWebhook() *RotationWebhookConfig {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.rotationWebhook
+}
+
+// rotationWebhookPayload is the JSON body of the rotation webhook.
+type rotationWebhookPayload struct {
+	// Type is the certificate authority type that finished rotating.
+	Type string `json:"type"`
+	// ClusterName is the name of the cluster.
+	ClusterName string `json:"cluster_name"`
+	// RotationID is the ID of the finished rotation.
+	RotationID string `json:"rotation_id"`
+	// Result is "completed" when the rotation went through, or
+	// "rolled_back" when it was aborted and the rollback finished.
+	Result string `json:"result"`
+	// LastRotated is the completion time of the last completed rotation,
+	// unchanged by a rollback.
+	LastRotated time.Time `json:"last_rotated,omitempty"`
+}
+
+// notifyRotationWebhook fires the configured webhook when the observed
+// transition finished a rotation, either by completing it or by
+// finishing its rollback. Delivery happens in a background goroutine -
+// rotation transitions never wait for, or fail on, the webhook.
+func (a *AuthServer) notifyRotationWebhook(caType services.CertAuthType, prev, current services.Rotation) {
+	hook := a.getRotationWebhook()
+	if hook == nil {
+		return
+	}
+	var result string
+	switch {
+	case current.State == services.RotationStateStandby && prev.Phase == services.RotationPhaseRollback:
+		result = rotationWebhookRolledBack
+	case current.State == services.RotationStateStandby &&
+		!current.LastRotated.IsZero() && !current.LastRotated.Equal(prev.LastRotated):
+		// covers both gradual rotations and forced rotations that start
+		// and complete in one step
+		result = rotationWebhookCompleted
+	default:
+		return
+	}
+	payload, err := json.Marshal(rotationWebhookPayload{
+		Type:        string(caType),
+		ClusterName: a.clusterName.GetClusterName(),
+		RotationID:  current.CurrentID,
+		Result:      result,
+		LastRotated: current.LastRotated,
+	})
+	if err != nil {
+		log.Warningf("Failed to marshal rotation webhook payload: %v.", err)
+		return
+	}
+	go deliverRotationWebhook(*hook, payload)
+}
+
+// deliverRotationWebhook posts the payload to the webhook endpoint,
+// retrying failed attempts a bounded number of times. Failures are
+// logged and otherwise dropped.
+func deliverRotationWebhook(hook RotationWebhookConfig, payload []byte) {
+	client := &http.Client{Timeout: rotationWebhookTimeout}
+	for attempt := 1; attempt <= rotationWebhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(rotationWebhookRetryPause)
+		}
+		err := postRotationWebhook(client, hook, payload)
+		if err == nil {
+			return
+		}
+		log.Warningf("Rotation webhook delivery attempt %v of %v failed: %v.",
+			attempt, rotationWebhookAttempts, err)
+	}
+}
+
+// postRotationWebhook performs a single delivery attempt.
+func postRotationWebhook(client *http.Client, hook RotationWebhookConfig, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(payload)
+		req.Header.Set(RotationWebhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook endpoint returned status %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"sort"
+
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/sshutils"
+	"github.com/gravitational/teleport/lib/tlsca"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/crypto/ssh"
+)
+
+// GetTrustSetFingerprints returns fingerprints of all keys currently trusted
+// by the certificate authority of the given type: SSH checking keys are
+// reported in RFC4716 SHA256 format, TLS certificates as hex encoded
+// SHA256 sums of the certificate body.
+func (a *AuthServer) GetTrustSetFingerprints(caType services.CertAuthType) ([]string, error) {
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return TrustSetFingerprints(ca)
+}
+
+// TrustSetFingerprints returns fingerprints of all keys trusted by the
+// certificate authority - both SSH checking keys and TLS certificates.
+func TrustSetFingerprints(ca services.CertAuthority) ([]string, error) {
+	out := make([]string, 0, len(ca.GetCheckingKeys())+len(ca.GetTLSKeyPairs()))
+	for _, keyBytes := range ca.GetCheckingKeys() {
+		fingerprint, err := sshutils.AuthorizedKeyFingerprint(keyBytes)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, fingerprint)
+	}
+	for _, keyPair := range ca.GetTLSKeyPairs() {
+		fingerprint, err := tlsCertFingerprint(keyPair.Cert)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, fingerprint)
+	}
+	return out, nil
+}
+
+// tlsCertFingerprint returns hex encoded SHA256 fingerprint
+// of the PEM encoded certificate.
+func tlsCertFingerprint(certPEM []byte) (string, error) {
+	cert, err := tlsca.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// TrustBundleForPhase returns the set of TLS CA certificates a newly
+// provisioned node has to trust given the current rotation phase of the
+// certificate authority, along with the fingerprint of the certificate
+// that is actively signing. Outside of rotation only the active
+// certificate is returned, during rotation both the new and the old
+// certificates have to be trusted to avoid intermittent handshake
+// failures while the fleet converges.
+func (a *AuthServer) TrustBundleForPhase(caType services.CertAuthType) (certsToTrust [][]byte, activeSigningFingerprint string, err error) {
+	ca, err := a.Trust.GetCertAuthority(services.CertAuthID{
+		Type:       caType,
+		DomainName: a.clusterName.GetClusterName(),
+	}, false)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	keyPairs := ca.GetTLSKeyPairs()
+	if len(keyPairs) == 0 {
+		return nil, "", trace.NotFound("certificate authority %v has no TLS key pairs", ca.GetID())
+	}
+	switch ca.GetRotation().Phase {
+	case "", services.RotationPhaseStandby:
+		certsToTrust = [][]byte{keyPairs[0].Cert}
+	default:
+		// during rotation certificates issued by both the new and
+		// the old certificate authority are in circulation
+		for _, keyPair := range keyPairs {
+			certsToTrust = append(certsToTrust, keyPair.Cert)
+		}
+	}
+	// the first key pair is always the one actively signing,
+	// see startNewRotation and startRollingBackRotation
+	activeSigningFingerprint, err = tlsCertFingerprint(keyPairs[0].Cert)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	return certsToTrust, activeSigningFingerprint, nil
+}
+
+// CheckTrustSetUnique verifies that no two entries of the trust set of the
+// certificate authority contain identical key material. Identical entries
+// defeat the purpose of rotation - e.g. rolling back to an old key that is
+// the same as the new one is meaningless - and indicate an import or key
+// generation bug.
+func CheckTrustSetUnique(ca services.CertAuthority) error {
+	fingerprints, err := TrustSetFingerprints(ca)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	seen := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		if seen[fingerprint] {
+			return trace.BadParameter(
+				"certificate authority %v trusts duplicate key %v", ca.GetID(), fingerprint)
+		}
+		seen[fingerprint] = true
+	}
+	return nil
+}
+
+// IsCertTrustedByCA reports whether the PEM encoded TLS certificate chains
+// to any generation of the certificate authority currently in the trusted
+// set. The returned generation is the position of the matching trusted
+// certificate: 0 is the actively signing generation, higher positions are
+// older generations still trusted during a rotation overlap or by
+// retention, see startNewRotation for the ordering. Operators can use this
+// to confirm that an old node's certificate is still accepted before
+// completing a rotation.
+func IsCertTrustedByCA(ca services.CertAuthority, certPEM []byte) (generation int, trusted bool, err error) {
+	cert, err := tlsca.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return 0, false, trace.Wrap(err)
+	}
+	for i, keyPair := range ca.GetTLSKeyPairs() {
+		trustedCert, err := tlsca.ParseCertificatePEM(keyPair.Cert)
+		if err != nil {
+			return 0, false, trace.Wrap(err)
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(trustedCert)
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err == nil {
+			return i, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// IsSSHCertTrustedByCA reports whether the SSH certificate in
+// authorized_keys format is signed by any generation of the checking keys
+// currently trusted by the certificate authority, the SSH counterpart of
+// IsCertTrustedByCA.
+func IsSSHCertTrustedByCA(ca services.CertAuthority, certBytes []byte) (generation int, trusted bool, err error) {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return 0, false, trace.Wrap(err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return 0, false, trace.BadParameter("expected an SSH certificate, got %T", parsed)
+	}
+	for i, keyBytes := range ca.GetCheckingKeys() {
+		authority, _, _, _, err := ssh.ParseAuthorizedKey(keyBytes)
+		if err != nil {
+			return 0, false, trace.Wrap(err)
+		}
+		if bytes.Equal(cert.SignatureKey.Marshal(), authority.Marshal()) {
+			return i, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// CompareTrustSet compares the trust set of the certificate authority of the
+// given type against a known-good baseline of fingerprints. It returns
+// fingerprints present in the baseline but missing on the certificate
+// authority and fingerprints trusted by the certificate authority but not
+// listed in the baseline, so operators can detect trust set drift, e.g.
+// after an interrupted rotation.
+func (a *AuthServer) CompareTrustSet(caType services.CertAuthType, baseline []string) (missing []string, unexpected []string, err error) {
+	fingerprints, err := a.GetTrustSetFingerprints(caType)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	current := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		current[fingerprint] = true
+	}
+	expected := make(map[string]bool, len(baseline))
+	for _, fingerprint := range baseline {
+		expected[fingerprint] = true
+		if !current[fingerprint] {
+			missing = append(missing, fingerprint)
+		}
+	}
+	for _, fingerprint := range fingerprints {
+		if !expected[fingerprint] {
+			unexpected = append(unexpected, fingerprint)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected, nil
+}
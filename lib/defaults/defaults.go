@@ -385,4 +385,25 @@ const (
 
 	// CATTL is a default lifetime of a CA certificate
 	CATTL = time.Hour * 24 * 365 * 10
+
+	// RotationGracePeriod is a default grace period of the certificate
+	// authority rotation - the period when both old and new certificate
+	// authorities are trusted by the cluster
+	RotationGracePeriod = time.Hour * 48
+
+	// MaxRotationGracePeriod is the upper bound on the certificate
+	// authority rotation grace period, long enough for any realistic
+	// credential propagation and short enough to catch typos
+	MaxRotationGracePeriod = time.Hour * 24 * 30
+
+	// MinPhaseDuration is the lower bound on the duration of a single
+	// rotation phase produced by schedule generation, grace periods
+	// spreading thinner than this leave no time to react to a phase
+	// and are rejected, grace period 0 keeps its special meaning of
+	// forcing the rotation through in one step
+	MinPhaseDuration = 10 * time.Second
+
+	// CARotationCheckPeriod defines how frequently the auth server checks
+	// whether an automatic rotation has to switch to the next phase
+	CARotationCheckPeriod = 10 * time.Second
 )
@@ -123,6 +123,25 @@ const (
 	// ResizeEvent means that some user resized PTY on the client
 	ResizeEvent  = "resize"
 	TerminalSize = "size" // expressed as 'W:H'
+
+	// CertAuthorityRotationEvent indicates that a certificate authority
+	// moved between rotation phases
+	CertAuthorityRotationEvent = "ca.rotation"
+	RotationCertType           = "type"
+	RotationClusterName        = "cluster_name"
+	RotationOldPhase           = "old_phase"
+	RotationNewPhase           = "new_phase"
+	RotationMode               = "mode"
+	RotationID                 = "id"
+	RotationReason             = "reason"
+
+	// CertAuthorityRotationWarningEvent indicates that a certificate
+	// authority rotation was started with risky parameters, e.g. a grace
+	// period shorter than nodes need to reconnect, the rotation itself
+	// proceeds
+	CertAuthorityRotationWarningEvent = "ca.rotation.warning"
+	RotationWarning                   = "warning"
+	RotationGracePeriod               = "grace_period"
 )
 
 const (
@@ -702,6 +702,13 @@ func (process *TeleportProcess) initAuthService() error {
 
 	closeContext, signalClose := context.WithCancel(context.TODO())
 
+	// advances certificate authority rotations set to automatic mode
+	process.RegisterFunc("auth.rotate", func() error {
+		authServer.RunPeriodicOperations(closeContext)
+		log.Infof("Cert authority rotation checks exited.")
+		return nil
+	})
+
 	process.RegisterFunc("auth.heartbeat", func() error {
 		srv := services.ServerV2{
 			Kind:    services.KindAuthServer,
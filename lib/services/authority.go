@@ -4,6 +4,8 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/gravitational/teleport"
@@ -187,6 +189,12 @@ type CertAuthority interface {
 	SetTLSKeyPairs(keyPairs []TLSKeyPair)
 	// GetTLSKeyPairs returns first PEM encoded TLS cert
 	GetTLSKeyPairs() []TLSKeyPair
+	// SetCheckingKeys sets signature checking keys
+	SetCheckingKeys([][]byte) error
+	// GetRotation returns rotation state
+	GetRotation() Rotation
+	// SetRotation sets rotation state
+	SetRotation(Rotation)
 }
 
 // CertPoolFromCertAuthorities returns certificate pools from TLS certificates
@@ -375,6 +383,25 @@ func (ca *CertAuthorityV2) SetSigningKeys(keys [][]byte) error {
 	return nil
 }
 
+// SetCheckingKeys sets signature checking keys
+func (ca *CertAuthorityV2) SetCheckingKeys(keys [][]byte) error {
+	ca.Spec.CheckingKeys = keys
+	return nil
+}
+
+// GetRotation returns rotation state
+func (ca *CertAuthorityV2) GetRotation() Rotation {
+	if ca.Spec.Rotation == nil {
+		return Rotation{}
+	}
+	return *ca.Spec.Rotation
+}
+
+// SetRotation sets rotation state
+func (ca *CertAuthorityV2) SetRotation(r Rotation) {
+	ca.Spec.Rotation = &r
+}
+
 // GetID returns certificate authority ID -
 // combined type and name
 func (ca *CertAuthorityV2) GetID() CertAuthID {
@@ -540,8 +567,580 @@ type CertAuthoritySpecV2 struct {
 	RoleMap RoleMap `json:"role_map,omitempty"`
 	// TLS is a list of TLS key pairs
 	TLSKeyPairs []TLSKeyPair `json:"tls_key_pairs,omitempty"`
+	// Rotation is a status of the certificate authority rotation
+	Rotation *Rotation `json:"rotation,omitempty"`
+}
+
+const (
+	// RotationStateStandby is initial status of the rotation -
+	// nothing is being rotated.
+	RotationStateStandby = "standby"
+	// RotationStateInProgress - that rotation is in progress.
+	RotationStateInProgress = "in_progress"
+	// RotationStateScheduled means that rotation has been scheduled
+	// to start at a future time, no keys have been generated yet.
+	RotationStateScheduled = "scheduled"
+	// RotationPhaseStandby is the initial phase of the rotation
+	// it means no operations have started.
+	RotationPhaseStandby = "standby"
+	// RotationPhaseUpdateClients is a phase of the rotation
+	// when client credentials will have to be updated and reloaded
+	// but servers will use and respond with old credentials
+	// because clients have no idea about new credentials at first.
+	RotationPhaseUpdateClients = "update_clients"
+	// RotationPhaseUpdateServers is a phase of the rotation
+	// when servers will have to reload and should start serving
+	// TLS and SSH certificates signed by new CA.
+	RotationPhaseUpdateServers = "update_servers"
+	// RotationPhaseRollback means that rotation is rolling
+	// back to the old certificate authority.
+	RotationPhaseRollback = "rollback"
+	// RotationModeManual is a manual rotation mode when all phases
+	// are set by the operator.
+	RotationModeManual = "manual"
+	// RotationModeAuto is set to go through all phases by the schedule.
+	RotationModeAuto = "auto"
+)
+
+// RotatePhases lists all supported rotation phases
+var RotatePhases = []string{
+	RotationPhaseUpdateClients,
+	RotationPhaseUpdateServers,
+	RotationPhaseRollback,
+	RotationPhaseStandby,
+}
+
+// RotateVerb returns the verb guarding the transition to the target rotation
+// phase, e.g. "rotate:standby". Per-phase verbs make it possible to grant
+// operators permissions to start and roll back rotations without being able
+// to complete them.
+func RotateVerb(targetPhase string) string {
+	return VerbRotate + ":" + targetPhase
+}
+
+// RotateVerbs returns rotation verbs for all supported rotation phases.
+func RotateVerbs() []string {
+	verbs := make([]string, 0, len(RotatePhases)+1)
+	verbs = append(verbs, VerbRotate)
+	for _, phase := range RotatePhases {
+		verbs = append(verbs, RotateVerb(phase))
+	}
+	return verbs
+}
+
+// Rotation is a status of the rotation of the certificate authority
+type Rotation struct {
+	// State could be one of "init" or "in_progress".
+	State string `json:"state,omitempty"`
+	// Phase is the current rotation phase.
+	Phase string `json:"phase,omitempty"`
+	// Mode sets manual or automatic rotation mode.
+	Mode string `json:"mode,omitempty"`
+	// CurrentID is the ID of the rotation operation
+	// to differentiate between rotation attempts.
+	CurrentID string `json:"current_id"`
+	// Started is set to the time when rotation has been started
+	// in case if the state of the rotation is "in_progress".
+	Started time.Time `json:"started,omitempty"`
+	// GracePeriod is a period during which old and new CA
+	// are valid for checking purposes, but only new CA is
+	// used for signing.
+	GracePeriod Duration `json:"grace_period,omitempty"`
+	// LastRotated specifies the last time of the completed rotation.
+	LastRotated time.Time `json:"last_rotated,omitempty"`
+	// Schedule is a rotation schedule - used in
+	// automatic mode to switch between phases.
+	Schedule RotationSchedule `json:"schedule,omitempty"`
+	// StartAt is set to the time a scheduled rotation begins
+	// in case if the state of the rotation is "scheduled".
+	StartAt time.Time `json:"start_at,omitempty"`
+	// Paused is set when an in-progress automatic rotation has been
+	// frozen by an operator, the rotation does not advance through
+	// its schedule while paused.
+	Paused bool `json:"paused,omitempty"`
+	// PausedAt is set to the time the rotation has been paused,
+	// used to re-anchor the schedule when the rotation resumes.
+	PausedAt time.Time `json:"paused_at,omitempty"`
+	// InitiatedBy is the name of the user who started or scheduled
+	// the rotation, set to "auto" for rotations started by the
+	// rotation schedule.
+	InitiatedBy string `json:"initiated_by,omitempty"`
+	// RollbackInitiatedBy is the name of the user who triggered
+	// the rollback of this rotation, set only if the rotation
+	// has been rolled back.
+	RollbackInitiatedBy string `json:"rollback_initiated_by,omitempty"`
+	// Reason is an optional operator supplied reason the rotation
+	// was started with, e.g. a ticket reference, for change tracking
+	// in regulated environments.
+	Reason string `json:"reason,omitempty"`
+	// CanarySelector is an optional node label selector in the
+	// "key=value,key2=value2" format. When set, only the nodes matching
+	// it are instructed to switch to the new credentials while the
+	// rotation is in the update_clients phase, see MatchesCanary,
+	// and the update_servers phase is blocked until the canary has
+	// been confirmed healthy.
+	CanarySelector string `json:"canary_selector,omitempty"`
+	// CanaryConfirmed is set once the operator has confirmed the canary
+	// nodes are healthy on the new credentials, unblocking the
+	// update_servers phase.
+	CanaryConfirmed bool `json:"canary_confirmed,omitempty"`
+	// RetainGenerations is the number of certificate authority
+	// generations kept in the trusted set once this rotation completes,
+	// zero or one keeps only the newly rotated certificate authority.
+	RetainGenerations int `json:"retain_generations,omitempty"`
+	// PinnedCerts is a list of fingerprints of TLS certificates kept
+	// trusted, certificate only, past the completion of the rotation for
+	// external systems pinning a specific certificate, until removed
+	// explicitly.
+	PinnedCerts []string `json:"pinned_certs,omitempty"`
+	// PhaseTimes maps a rotation phase to the time the rotation entered
+	// it, recorded on every phase transition so operators can tell when
+	// e.g. update_servers actually began relative to the schedule. The
+	// map is reset when a new rotation starts.
+	PhaseTimes map[string]time.Time `json:"phase_times,omitempty"`
 }
 
+// Matches returns true if this state rotation matches
+// external rotation state, phase and rotation ID should match,
+// notice that matches does not behave like Equals because it does not require
+// all fields to be the same.
+func (r *Rotation) Matches(rotation Rotation) bool {
+	return r.CurrentID == rotation.CurrentID && r.State == rotation.State && r.Phase == rotation.Phase
+}
+
+// RecordPhaseTime records the time the rotation entered its current
+// phase, see PhaseTimes.
+func (r *Rotation) RecordPhaseTime(t time.Time) {
+	if r.PhaseTimes == nil {
+		r.PhaseTimes = make(map[string]time.Time)
+	}
+	r.PhaseTimes[r.Phase] = t.UTC()
+}
+
+// ParseCanarySelector parses a canary node selector in the
+// "key=value,key2=value2" format into a label map.
+func ParseCanarySelector(selector string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, trace.BadParameter(
+				"invalid canary selector %q, expected format \"key=value,key2=value2\"", selector)
+		}
+		out[parts[0]] = parts[1]
+	}
+	return out, nil
+}
+
+// MatchesCanary returns true when the given node labels match the canary
+// selector of the rotation, i.e. the node is part of the canary group and
+// should switch to the new credentials while the canary is evaluated.
+// Nodes never match when no canary selector is set.
+func (r *Rotation) MatchesCanary(labels map[string]string) bool {
+	if r.CanarySelector == "" {
+		return false
+	}
+	selector, err := ParseCanarySelector(r.CanarySelector)
+	if err != nil {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// LastRotatedDescription returns human friendly description.
+func (r *Rotation) LastRotatedDescription() string {
+	if r.LastRotated.IsZero() {
+		return "never updated"
+	}
+	return fmt.Sprintf("last rotated %v", utils.HumanTimeFormat(r.LastRotated))
+}
+
+// PhaseDescription returns human friendly description of a current rotation phase.
+func (r *Rotation) PhaseDescription() string {
+	switch r.Phase {
+	case RotationPhaseStandby, "":
+		return "on standby"
+	case RotationPhaseUpdateClients:
+		return "rotating clients"
+	case RotationPhaseUpdateServers:
+		return "rotating servers"
+	case RotationPhaseRollback:
+		return "rolling back rotation"
+	default:
+		return fmt.Sprintf("unknown phase: %q", r.Phase)
+	}
+}
+
+// String returns user friendly information about certificate authority.
+func (r *Rotation) String() string {
+	switch r.State {
+	case "", RotationStateStandby:
+		if r.LastRotated.IsZero() {
+			return "never updated"
+		}
+		return fmt.Sprintf("rotated %v", utils.HumanTimeFormat(r.LastRotated))
+	case RotationStateScheduled:
+		return fmt.Sprintf("rotation scheduled to start %v (mode: %v)",
+			utils.HumanTimeFormat(r.StartAt), r.Mode)
+	case RotationStateInProgress:
+		return fmt.Sprintf("%v (mode: %v, started: %v, ending: %v)",
+			r.PhaseDescription(),
+			r.Mode,
+			utils.HumanTimeFormat(r.Started),
+			utils.HumanTimeFormat(r.Started.Add(r.GracePeriod.Duration)),
+		)
+	default:
+		return "unknown"
+	}
+}
+
+// RotationSchedule is a rotation schedule setting time switches
+// for different phases.
+type RotationSchedule struct {
+	// UpdateClients specifies time to switch to the "update_clients" phase
+	UpdateClients time.Time `json:"update_clients,omitempty"`
+	// UpdateServers specifies time to switch to the "update_servers" phase
+	UpdateServers time.Time `json:"update_servers,omitempty"`
+	// Standby specifies time to switch to the "standby" phase
+	Standby time.Time `json:"standby,omitempty"`
+}
+
+// RotationScheduleOffsets specifies the rotation schedule as offsets
+// relative to the start of the rotation instead of absolute timestamps,
+// useful when different phases need uneven shares of the grace period.
+type RotationScheduleOffsets struct {
+	// UpdateServers is the offset from the start of the rotation
+	// at which it switches to the "update_servers" phase.
+	UpdateServers time.Duration `json:"update_servers"`
+	// Standby is the offset from the start of the rotation
+	// at which it completes and returns to the "standby" phase.
+	Standby time.Duration `json:"standby"`
+}
+
+// Schedule converts the relative offsets to an absolute rotation schedule
+// anchored at the current time, the offsets have to be monotonically
+// increasing and fit within the grace period.
+func (o *RotationScheduleOffsets) Schedule(clock clockwork.Clock, gracePeriod time.Duration) (*RotationSchedule, error) {
+	if o.UpdateServers <= 0 {
+		return nil, trace.BadParameter(
+			"schedule offset %v of %q phase has to be positive", o.UpdateServers, RotationPhaseUpdateServers)
+	}
+	if o.Standby <= o.UpdateServers {
+		return nil, trace.BadParameter(
+			"schedule offsets have to be monotonically increasing, %q offset %v is not after %q offset %v",
+			RotationPhaseStandby, o.Standby, RotationPhaseUpdateServers, o.UpdateServers)
+	}
+	if o.Standby > gracePeriod {
+		return nil, trace.BadParameter(
+			"schedule offset %v of %q phase exceeds the grace period %v", o.Standby, RotationPhaseStandby, gracePeriod)
+	}
+	now := clock.Now().UTC()
+	return &RotationSchedule{
+		UpdateServers: now.Add(o.UpdateServers),
+		Standby:       now.Add(o.Standby),
+	}, nil
+}
+
+// GenerateSchedule generates schedule based on the time period, using
+// even time periods between rotation phases.
+func GenerateSchedule(clock clockwork.Clock, gracePeriod time.Duration) (*RotationSchedule, error) {
+	if gracePeriod <= 0 {
+		return nil, trace.BadParameter("invalid grace period %q, provide value > 0", gracePeriod)
+	}
+	// guard against grace periods spreading so thin that no fleet can
+	// react within a phase, this also covers integer division collapsing
+	// the phases entirely
+	third := gracePeriod / 3
+	if third < defaults.MinPhaseDuration {
+		return nil, trace.BadParameter(
+			"grace period %v would produce rotation phases shorter than the minimum of %v, use a grace period of at least %v",
+			gracePeriod, defaults.MinPhaseDuration, 3*defaults.MinPhaseDuration)
+	}
+	now := clock.Now().UTC()
+	schedule := &RotationSchedule{
+		UpdateServers: now.Add(third),
+		Standby:       now.Add(gracePeriod),
+	}
+	if !schedule.UpdateServers.Before(schedule.Standby) {
+		return nil, trace.BadParameter(
+			"grace period %v is too short to generate the rotation schedule", gracePeriod)
+	}
+	return schedule, nil
+}
+
+// GenerateWeightedSchedule generates a rotation schedule distributing the
+// grace period according to the relative weights of the update_clients
+// window, the update_servers window and the remaining standby gap during
+// which the old certificate authority stays trusted. Weights are relative,
+// so e.g. {2, 1, 1} gives the update_clients phase half of the grace
+// period - environments with slow client re-issue can give that phase
+// more time without extending the overall rotation.
+func GenerateWeightedSchedule(clock clockwork.Clock, gracePeriod time.Duration, weights [3]float64) (*RotationSchedule, error) {
+	if gracePeriod <= 0 {
+		return nil, trace.BadParameter("invalid grace period %q, provide value > 0", gracePeriod)
+	}
+	var total float64
+	for i, weight := range weights {
+		if math.IsNaN(weight) || math.IsInf(weight, 0) || weight <= 0 {
+			return nil, trace.BadParameter(
+				"invalid weight %v at index %v, provide a positive value", weight, i)
+		}
+		total += weight
+	}
+	updateClientsWindow := time.Duration(float64(gracePeriod) * weights[0] / total)
+	updateServersWindow := time.Duration(float64(gracePeriod) * weights[1] / total)
+	now := clock.Now().UTC()
+	schedule := &RotationSchedule{
+		UpdateServers: now.Add(updateClientsWindow),
+		Standby:       now.Add(updateClientsWindow + updateServersWindow),
+	}
+	// guard against a window collapsing to zero for extremely short
+	// grace periods or extremely skewed weights
+	if !now.Before(schedule.UpdateServers) || !schedule.UpdateServers.Before(schedule.Standby) {
+		return nil, trace.BadParameter(
+			"grace period %v is too short to generate the rotation schedule with weights %v", gracePeriod, weights)
+	}
+	return schedule, nil
+}
+
+// MaintenanceWindow is a recurring window of time during which disruptive
+// rotation transitions are allowed, expressed as days of the week and an
+// hour range in a timezone, e.g. weekday nights in the cluster's locale.
+type MaintenanceWindow struct {
+	// Days are the days of the week the window is open on, an empty
+	// list opens the window on every day
+	Days []time.Weekday `json:"days,omitempty"`
+	// StartHour is the hour of the day the window opens, inclusive,
+	// in the 0-23 range
+	StartHour int `json:"start_hour"`
+	// EndHour is the hour of the day the window closes, exclusive,
+	// in the 1-24 range
+	EndHour int `json:"end_hour"`
+	// Timezone is the IANA timezone the hours are interpreted in,
+	// e.g. "America/New_York", an empty value means UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// CheckAndSetDefaults validates the maintenance window.
+func (w *MaintenanceWindow) CheckAndSetDefaults() error {
+	if w.StartHour < 0 || w.StartHour > 23 {
+		return trace.BadParameter("start hour %v is outside of the 0-23 range", w.StartHour)
+	}
+	if w.EndHour < 1 || w.EndHour > 24 {
+		return trace.BadParameter("end hour %v is outside of the 1-24 range", w.EndHour)
+	}
+	if w.StartHour >= w.EndHour {
+		return trace.BadParameter(
+			"maintenance window start hour %v has to be before its end hour %v", w.StartHour, w.EndHour)
+	}
+	for _, day := range w.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return trace.BadParameter("invalid day of the week %v in the maintenance window", int(day))
+		}
+	}
+	if _, err := w.location(); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// location returns the timezone the window hours are interpreted in.
+func (w *MaintenanceWindow) location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, trace.BadParameter("invalid maintenance window timezone %q: %v", w.Timezone, err)
+	}
+	return loc, nil
+}
+
+// dayAllowed reports whether the window is open on the given day of
+// the week.
+func (w *MaintenanceWindow) dayAllowed(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, allowed := range w.Days {
+		if allowed == day {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpen returns the earliest time not before t at which the window is
+// open, t itself if the window is open at t.
+func (w *MaintenanceWindow) nextOpen(t time.Time) (time.Time, error) {
+	loc, err := w.location()
+	if err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+	local := t.In(loc)
+	// the window repeats weekly, so scanning a week past t covers
+	// every day it can possibly open on
+	for day := 0; day <= 7; day++ {
+		date := local.AddDate(0, 0, day)
+		if !w.dayAllowed(date.Weekday()) {
+			continue
+		}
+		open := time.Date(date.Year(), date.Month(), date.Day(), w.StartHour, 0, 0, 0, loc)
+		closed := time.Date(date.Year(), date.Month(), date.Day(), w.EndHour, 0, 0, 0, loc)
+		if !local.Before(closed) {
+			// this day's window has already closed
+			continue
+		}
+		if local.Before(open) {
+			return open.UTC(), nil
+		}
+		// t falls inside the window
+		return t.UTC(), nil
+	}
+	return time.Time{}, trace.BadParameter(
+		"maintenance window does not open within a week of %v", t)
+}
+
+// nextAllowedTime snaps t forward to the earliest time any of the
+// maintenance windows is open at, t itself if one already covers it.
+func nextAllowedTime(t time.Time, windows []MaintenanceWindow) (time.Time, error) {
+	var earliest time.Time
+	for i := range windows {
+		open, err := windows[i].nextOpen(t)
+		if err != nil {
+			return time.Time{}, trace.Wrap(err)
+		}
+		if earliest.IsZero() || open.Before(earliest) {
+			earliest = open
+		}
+	}
+	if earliest.IsZero() {
+		return time.Time{}, trace.BadParameter("provide at least one maintenance window")
+	}
+	return earliest, nil
+}
+
+// GenerateScheduleInWindows generates a rotation schedule the same way
+// GenerateSchedule does and then snaps each transition boundary forward
+// to the next time one of the maintenance windows is open, so the
+// disruptive phase flips do not land at an arbitrary time of day. The
+// snapping only moves boundaries forward and keeps the phases in strictly
+// increasing order, which can stretch the rotation past the grace period.
+func GenerateScheduleInWindows(clock clockwork.Clock, gracePeriod time.Duration, windows []MaintenanceWindow) (*RotationSchedule, error) {
+	if len(windows) == 0 {
+		return nil, trace.BadParameter("provide at least one maintenance window")
+	}
+	for i := range windows {
+		if err := windows[i].CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	base, err := GenerateSchedule(clock, gracePeriod)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateServers, err := nextAllowedTime(base.UpdateServers, windows)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// snapping can push the update_servers flip past the original
+	// completion time, in that case the update_servers phase keeps its
+	// original share of the grace period before the rotation completes
+	standbyBase := base.Standby
+	if !standbyBase.After(updateServers) {
+		standbyBase = updateServers.Add(gracePeriod / 3)
+	}
+	standby, err := nextAllowedTime(standbyBase, windows)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &RotationSchedule{
+		UpdateServers: updateServers,
+		Standby:       standby,
+	}, nil
+}
+
+// CheckAndSetDefaults checks and sets default values of the rotation schedule.
+func (s *RotationSchedule) CheckAndSetDefaults(clock clockwork.Clock) error {
+	if s.UpdateServers.IsZero() {
+		return trace.BadParameter("missing parameter UpdateServers")
+	}
+	if s.Standby.IsZero() {
+		return trace.BadParameter("missing parameter Standby")
+	}
+	// phases have to switch in strictly increasing order, otherwise
+	// the rotation would skip or collapse phases
+	if !s.UpdateClients.IsZero() && !s.UpdateClients.Before(s.UpdateServers) {
+		return trace.BadParameter(
+			"rotation has to switch to %q at %v before %q at %v",
+			RotationPhaseUpdateClients, s.UpdateClients, RotationPhaseUpdateServers, s.UpdateServers)
+	}
+	if !s.UpdateServers.Before(s.Standby) {
+		return trace.BadParameter(
+			"rotation has to switch to %q at %v before %q at %v",
+			RotationPhaseUpdateServers, s.UpdateServers, RotationPhaseStandby, s.Standby)
+	}
+	// a timestamp in the past would make the rotation either advance
+	// instantly or never, name the offending phase so the schedule
+	// can be corrected
+	now := clock.Now()
+	if !s.UpdateClients.IsZero() && s.UpdateClients.Before(now) {
+		return trace.BadParameter(
+			"%q schedule time %v is in the past", RotationPhaseUpdateClients, s.UpdateClients)
+	}
+	if s.UpdateServers.Before(now) {
+		return trace.BadParameter(
+			"%q schedule time %v is in the past", RotationPhaseUpdateServers, s.UpdateServers)
+	}
+	if s.Standby.Before(now) {
+		return trace.BadParameter(
+			"%q schedule time %v is in the past", RotationPhaseStandby, s.Standby)
+	}
+	return nil
+}
+
+// RotationSchema is a JSON validation schema of the CA rotation state object.
+const RotationSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "state": {"type": "string"},
+    "phase": {"type": "string"},
+    "mode": {"type": "string"},
+    "current_id": {"type": "string"},
+    "started": {"type": "string"},
+    "grace_period": {"type": "string"},
+    "last_rotated": {"type": "string"},
+    "start_at": {"type": "string"},
+    "paused": {"type": "boolean"},
+    "paused_at": {"type": "string"},
+    "initiated_by": {"type": "string"},
+    "rollback_initiated_by": {"type": "string"},
+    "reason": {"type": "string"},
+    "canary_selector": {"type": "string"},
+    "canary_confirmed": {"type": "boolean"},
+    "retain_generations": {"type": "integer"},
+    "pinned_certs": {
+      "type": "array",
+      "items": {"type": "string"}
+    },
+    "phase_times": {"type": "object"},
+    "schedule": {
+      "type": "object",
+      "properties": {
+        "update_clients": {"type": "string"},
+        "update_servers": {"type": "string"},
+        "standby": {"type": "string"}
+      }
+    }
+  }
+}`
+
 // CertAuthoritySpecV2Schema is JSON schema for cert authority V2
 const CertAuthoritySpecV2Schema = `{
   "type": "object",
@@ -579,6 +1178,7 @@ const CertAuthoritySpecV2Schema = `{
         }
       }
     },
+    "rotation": %v,
     "role_map": %v
   }
 }`
@@ -677,7 +1277,7 @@ type CertAuthorityMarshaler interface {
 
 // GetCertAuthoritySchema returns JSON Schema for cert authorities
 func GetCertAuthoritySchema() string {
-	return fmt.Sprintf(V2SchemaTemplate, MetadataSchema, fmt.Sprintf(CertAuthoritySpecV2Schema, RoleMapSchema), DefaultDefinitions)
+	return fmt.Sprintf(V2SchemaTemplate, MetadataSchema, fmt.Sprintf(CertAuthoritySpecV2Schema, RotationSchema, RoleMapSchema), DefaultDefinitions)
 }
 
 type TeleportCertAuthorityMarshaler struct{}
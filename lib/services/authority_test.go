@@ -0,0 +1,206 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/gravitational/teleport/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	. "gopkg.in/check.v1"
+)
+
+type AuthoritySuite struct {
+	clock clockwork.FakeClock
+}
+
+var _ = Suite(&AuthoritySuite{})
+
+func (s *AuthoritySuite) SetUpSuite(c *C) {
+	utils.InitLoggerForTests()
+}
+
+func (s *AuthoritySuite) SetUpTest(c *C) {
+	s.clock = clockwork.NewFakeClock()
+}
+
+// TestScheduleValidation exercises RotationSchedule.CheckAndSetDefaults
+// with schedules violating the ordering and not-in-past requirements,
+// a malformed schedule would make automatic rotation either advance
+// instantly or never.
+func (s *AuthoritySuite) TestScheduleValidation(c *C) {
+	now := s.clock.Now()
+	testCases := []struct {
+		comment  string
+		schedule RotationSchedule
+		match    string
+	}{
+		{
+			comment: "valid schedule is accepted",
+			schedule: RotationSchedule{
+				UpdateClients: now.Add(time.Minute),
+				UpdateServers: now.Add(2 * time.Minute),
+				Standby:       now.Add(3 * time.Minute),
+			},
+		},
+		{
+			comment: "update_clients is optional",
+			schedule: RotationSchedule{
+				UpdateServers: now.Add(2 * time.Minute),
+				Standby:       now.Add(3 * time.Minute),
+			},
+		},
+		{
+			comment:  "missing update_servers",
+			schedule: RotationSchedule{Standby: now.Add(time.Minute)},
+			match:    ".*UpdateServers.*",
+		},
+		{
+			comment:  "missing standby",
+			schedule: RotationSchedule{UpdateServers: now.Add(time.Minute)},
+			match:    ".*Standby.*",
+		},
+		{
+			comment: "update_clients after update_servers",
+			schedule: RotationSchedule{
+				UpdateClients: now.Add(2 * time.Minute),
+				UpdateServers: now.Add(time.Minute),
+				Standby:       now.Add(3 * time.Minute),
+			},
+			match: `.*"update_clients".*before "update_servers".*`,
+		},
+		{
+			comment: "update_servers after standby",
+			schedule: RotationSchedule{
+				UpdateClients: now.Add(time.Minute),
+				UpdateServers: now.Add(3 * time.Minute),
+				Standby:       now.Add(2 * time.Minute),
+			},
+			match: `.*"update_servers".*before "standby".*`,
+		},
+		{
+			comment: "update_clients in the past",
+			schedule: RotationSchedule{
+				UpdateClients: now.Add(-time.Minute),
+				UpdateServers: now.Add(time.Minute),
+				Standby:       now.Add(2 * time.Minute),
+			},
+			match: `.*"update_clients".*in the past.*`,
+		},
+		{
+			comment: "update_servers in the past",
+			schedule: RotationSchedule{
+				UpdateServers: now.Add(-time.Minute),
+				Standby:       now.Add(time.Minute),
+			},
+			match: `.*"update_servers".*in the past.*`,
+		},
+		{
+			comment: "standby in the past",
+			schedule: RotationSchedule{
+				UpdateServers: now.Add(-2 * time.Minute),
+				Standby:       now.Add(-time.Minute),
+			},
+			match: `.*in the past.*`,
+		},
+	}
+	for i, tc := range testCases {
+		comment := Commentf("test case %v: %v", i, tc.comment)
+		schedule := tc.schedule
+		err := schedule.CheckAndSetDefaults(s.clock)
+		if tc.match == "" {
+			c.Assert(err, IsNil, comment)
+			continue
+		}
+		c.Assert(err, NotNil, comment)
+		c.Assert(trace.IsBadParameter(err), Equals, true, comment)
+		c.Assert(err, ErrorMatches, tc.match, comment)
+	}
+}
+
+// TestGenerateWeightedSchedule verifies that the grace period is split
+// between the rotation windows proportionally to the supplied weights
+// and that degenerate weights are rejected.
+func (s *AuthoritySuite) TestGenerateWeightedSchedule(c *C) {
+	now := s.clock.Now().UTC()
+
+	// even weights split the grace period in equal thirds
+	schedule, err := GenerateWeightedSchedule(s.clock, 3*time.Hour, [3]float64{1, 1, 1})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, now.Add(time.Hour))
+	c.Assert(schedule.Standby, Equals, now.Add(2*time.Hour))
+
+	// skewed weights give the update_clients phase half of the grace
+	// period
+	schedule, err = GenerateWeightedSchedule(s.clock, 4*time.Hour, [3]float64{2, 1, 1})
+	c.Assert(err, IsNil)
+	c.Assert(schedule.UpdateServers, Equals, now.Add(2*time.Hour))
+	c.Assert(schedule.Standby, Equals, now.Add(3*time.Hour))
+
+	// the generated schedule passes its own validation
+	c.Assert(schedule.CheckAndSetDefaults(s.clock), IsNil)
+
+	type badCase struct {
+		comment     string
+		gracePeriod time.Duration
+		weights     [3]float64
+		match       string
+	}
+	badCases := []badCase{
+		{
+			comment:     "zero grace period",
+			gracePeriod: 0,
+			weights:     [3]float64{1, 1, 1},
+			match:       ".*grace period.*",
+		},
+		{
+			comment:     "zero weight",
+			gracePeriod: time.Hour,
+			weights:     [3]float64{1, 0, 1},
+			match:       ".*weight 0 at index 1.*",
+		},
+		{
+			comment:     "negative weight",
+			gracePeriod: time.Hour,
+			weights:     [3]float64{1, 1, -1},
+			match:       ".*weight -1 at index 2.*",
+		},
+		{
+			comment:     "NaN weight",
+			gracePeriod: time.Hour,
+			weights:     [3]float64{math.NaN(), 1, 1},
+			match:       ".*weight NaN at index 0.*",
+		},
+		{
+			comment:     "grace period too short for the weights",
+			gracePeriod: 2 * time.Nanosecond,
+			weights:     [3]float64{1, 1, 1},
+			match:       ".*too short.*",
+		},
+	}
+	for i, tc := range badCases {
+		comment := Commentf("test case %v: %v", i, tc.comment)
+		schedule, err := GenerateWeightedSchedule(s.clock, tc.gracePeriod, tc.weights)
+		c.Assert(err, NotNil, comment)
+		c.Assert(trace.IsBadParameter(err), Equals, true, comment)
+		c.Assert(err, ErrorMatches, tc.match, comment)
+		c.Assert(schedule, IsNil, comment)
+	}
+}
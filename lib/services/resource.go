@@ -182,6 +182,11 @@ const (
 
 	// VerbDelete is used to remove an object.
 	VerbDelete = "delete"
+
+	// VerbRotate is used to rotate certificate authorities,
+	// it is combined with a target rotation phase to grant
+	// per-phase rotation permissions, see RotateVerb.
+	VerbRotate = "rotate"
 )
 
 func collectOptions(opts []MarshalOption) (*MarshalConfig, error) {
@@ -73,17 +73,24 @@ const (
 	HostCA CertAuthType = "host"
 	// UserCA identifies the key as a user certificate authority
 	UserCA CertAuthType = "user"
+	// DatabaseCA identifies the key as a database certificate authority
+	DatabaseCA CertAuthType = "db"
 )
 
-// CertAuthType specifies certificate authority type, user or host
+// CertAuthTypes lists all supported certificate authority types.
+var CertAuthTypes = []CertAuthType{HostCA, UserCA, DatabaseCA}
+
+// CertAuthType specifies certificate authority type, e.g. user or host
 type CertAuthType string
 
 // Check checks if certificate authority type value is correct
 func (c CertAuthType) Check() error {
-	if c != HostCA && c != UserCA {
-		return trace.BadParameter("'%v' authority type is not supported", c)
+	for _, caType := range CertAuthTypes {
+		if c == caType {
+			return nil
+		}
 	}
-	return nil
+	return trace.BadParameter("'%v' authority type is not supported", c)
 }
 
 // CertAuthID - id of certificate authority (it's type and domain name)